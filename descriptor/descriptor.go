@@ -67,6 +67,18 @@ func extractFile(gz []byte) (*protobuf.FileDescriptorProto, error) {
 	return fd, nil
 }
 
+// FileDescriptorProtoOf returns the FileDescriptorProto of the file that
+// declares m's type, decompressed from proto.RawDescriptor.
+// It returns an error if m does not provide a descriptor
+// or the descriptor is malformed.
+func FileDescriptorProtoOf(m proto.Message) (*protobuf.FileDescriptorProto, error) {
+	gz, _ := proto.RawDescriptor(m)
+	if gz == nil {
+		return nil, fmt.Errorf("%T does not have a Descriptor method", m)
+	}
+	return extractFile(gz)
+}
+
 // Message is a proto.Message with a method to return its descriptor.
 //
 // Message types generated by the protocol compiler always satisfy
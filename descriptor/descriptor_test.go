@@ -1,12 +1,22 @@
 package descriptor_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/golang/protobuf/descriptor"
+	jsonpb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	"github.com/golang/protobuf/proto"
+	proto3pb "github.com/golang/protobuf/proto/proto3_proto"
 	tpb "github.com/golang/protobuf/proto/test_proto"
 	protobuf "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	anypb "github.com/golang/protobuf/ptypes/any"
+	durpb "github.com/golang/protobuf/ptypes/duration"
 )
 
 func TestMessage(t *testing.T) {
@@ -20,6 +30,56 @@ func TestMessage(t *testing.T) {
 	}
 }
 
+type noDescriptor struct{}
+
+func (*noDescriptor) Reset()         {}
+func (*noDescriptor) String() string { return "" }
+func (*noDescriptor) ProtoMessage()  {}
+
+func TestFileDescriptorProtoOf(t *testing.T) {
+	msg := &tpb.GoTest_RequiredGroup{}
+	gz, path := proto.RawDescriptor(msg)
+	if len(gz) == 0 {
+		t.Fatalf("proto.RawDescriptor(%T) returned no descriptor", msg)
+	}
+
+	// Decompress and parse the descriptor by hand.
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := new(protobuf.FileDescriptorProto)
+	if err := proto.Unmarshal(b, want); err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := descriptor.FileDescriptorProtoOf(msg)
+	if err != nil {
+		t.Fatalf("descriptor.FileDescriptorProtoOf(%T): %v", msg, err)
+	}
+	if !proto.Equal(fd, want) {
+		t.Errorf("descriptor.FileDescriptorProtoOf(%T) differs from the decompressed proto.RawDescriptor", msg)
+	}
+	md := fd.MessageType[path[0]]
+	for _, i := range path[1:] {
+		md = md.NestedType[i]
+	}
+	if name, want := md.GetName(), "RequiredGroup"; name != want {
+		t.Errorf("message at path %v = %q; want %q", path, name, want)
+	}
+
+	if gz, path := proto.RawDescriptor(&noDescriptor{}); gz != nil || path != nil {
+		t.Errorf("proto.RawDescriptor(%T) = %v, %v; want nil, nil", &noDescriptor{}, gz, path)
+	}
+	if _, err := descriptor.FileDescriptorProtoOf(&noDescriptor{}); err == nil {
+		t.Errorf("descriptor.FileDescriptorProtoOf(%T) succeeded; want error", &noDescriptor{})
+	}
+}
+
 func Example_options() {
 	var msg *tpb.MyMessageSet
 	_, md := descriptor.ForMessage(msg)
@@ -30,3 +90,559 @@ func Example_options() {
 	// Output:
 	// MyMessageSet uses option message_set_wire_format.
 }
+
+func TestFilesFromSet(t *testing.T) {
+	file := func(msg descriptor.Message) *protobuf.FileDescriptorProto {
+		fd, _ := descriptor.ForMessage(msg)
+		return fd
+	}
+	newFile := func(name string, deps ...string) *protobuf.FileDescriptorProto {
+		return &protobuf.FileDescriptorProto{Name: proto.String(name), Dependency: deps}
+	}
+	names := func(fds []*protobuf.FileDescriptorProto) []string {
+		var s []string
+		for _, fd := range fds {
+			s = append(s, fd.GetName())
+		}
+		return s
+	}
+
+	tests := []struct {
+		desc string
+		set  []*protobuf.FileDescriptorProto
+		want []string
+		err  string
+	}{{
+		desc: "well-known types",
+		set: []*protobuf.FileDescriptorProto{
+			file(&anypb.Any{}),
+			file(&durpb.Duration{}),
+			file(&protobuf.DescriptorProto{}),
+		},
+		want: []string{
+			"google/protobuf/any.proto",
+			"google/protobuf/duration.proto",
+			"google/protobuf/descriptor.proto",
+		},
+	}, {
+		desc: "transitive dependencies",
+		set: []*protobuf.FileDescriptorProto{
+			file(&proto3pb.Message{}),
+			file(&tpb.GoTest{}),
+			file(&anypb.Any{}),
+		},
+		want: []string{
+			"google/protobuf/any.proto",
+			"test_proto/test.proto",
+			"proto3_proto/proto3.proto",
+		},
+	}, {
+		desc: "diamond",
+		set: []*protobuf.FileDescriptorProto{
+			newFile("d.proto", "b.proto", "c.proto"),
+			newFile("c.proto", "a.proto"),
+			newFile("b.proto", "a.proto"),
+			newFile("a.proto"),
+		},
+		want: []string{"a.proto", "b.proto", "c.proto", "d.proto"},
+	}, {
+		desc: "missing import",
+		set: []*protobuf.FileDescriptorProto{
+			file(&proto3pb.Message{}),
+			file(&anypb.Any{}),
+		},
+		err: `descriptor: file "proto3_proto/proto3.proto" imports "test_proto/test.proto", which is not in the set`,
+	}, {
+		desc: "import cycle",
+		set: []*protobuf.FileDescriptorProto{
+			newFile("a.proto", "b.proto"),
+			newFile("b.proto", "c.proto"),
+			newFile("c.proto", "a.proto"),
+		},
+		err: "descriptor: import cycle: a.proto -> b.proto -> c.proto -> a.proto",
+	}, {
+		desc: "self import",
+		set: []*protobuf.FileDescriptorProto{
+			newFile("a.proto", "a.proto"),
+		},
+		err: "descriptor: import cycle: a.proto -> a.proto",
+	}, {
+		desc: "duplicate file",
+		set: []*protobuf.FileDescriptorProto{
+			newFile("a.proto"),
+			newFile("a.proto"),
+		},
+		err: `descriptor: duplicate file "a.proto" in set`,
+	}}
+	for _, tt := range tests {
+		got, err := descriptor.FilesFromSet(&protobuf.FileDescriptorSet{File: tt.set})
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: FilesFromSet() error = %v, want %q", tt.desc, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: FilesFromSet() error = %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(names(got), tt.want) {
+			t.Errorf("%s: FilesFromSet() = %v, want %v", tt.desc, names(got), tt.want)
+		}
+	}
+}
+
+func TestForFiles(t *testing.T) {
+	set, err := descriptor.ForFiles("proto3_proto/proto3.proto")
+	if err != nil {
+		t.Fatalf("ForFiles() error = %v", err)
+	}
+	var got []string
+	for _, fd := range set.GetFile() {
+		got = append(got, fd.GetName())
+	}
+	want := []string{
+		"google/protobuf/any.proto",
+		"test_proto/test.proto",
+		"proto3_proto/proto3.proto",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ForFiles() = %v, want %v", got, want)
+	}
+
+	// The set survives a round-trip through the wire format
+	// and is accepted by FilesFromSet in the same order.
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+	set2 := new(protobuf.FileDescriptorSet)
+	if err := proto.Unmarshal(b, set2); err != nil {
+		t.Fatalf("proto.Unmarshal() error = %v", err)
+	}
+	files, err := descriptor.FilesFromSet(set2)
+	if err != nil {
+		t.Fatalf("FilesFromSet() error = %v", err)
+	}
+	for i, fd := range files {
+		if !proto.Equal(fd, set.File[i]) {
+			t.Errorf("file %d: got %v, want %v", i, fd.GetName(), set.File[i].GetName())
+		}
+	}
+
+	if _, err := descriptor.ForFiles("does/not/exist.proto"); err == nil {
+		t.Errorf("ForFiles(unregistered file) succeeded, want error")
+	}
+}
+
+func TestRequiredFields(t *testing.T) {
+	tests := []struct {
+		msg  descriptor.Message
+		want []string
+	}{{
+		msg:  &jsonpb.MsgWithRequired{},
+		want: []string{"str"},
+	}, {
+		msg:  &jsonpb.MsgWithIndirectRequired{},
+		want: []string{"subm.str", "map_field.str", "slice_field.str"},
+	}, {
+		msg:  &tpb.GoTestRequiredGroupField{},
+		want: []string{"group", "group.Field"},
+	}, {
+		msg: &tpb.MyMessage{},
+		want: []string{
+			"count",
+			"inner.host",
+			"others.inner.host",
+			"we_must_go_deeper.leo_finally_won_an_oscar",
+			"we_must_go_deeper.leo_finally_won_an_oscar.host",
+			"rep_inner.host",
+		},
+	}, {
+		// Recursive message type with no required fields.
+		msg:  &proto3pb.Message{},
+		want: nil,
+	}}
+	for _, tt := range tests {
+		got := descriptor.RequiredFields(tt.msg)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RequiredFields(%T) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestRequiredFieldDescriptors(t *testing.T) {
+	tests := []struct {
+		msg  descriptor.Message
+		want []string
+	}{
+		{&jsonpb.MsgWithRequired{}, []string{"str"}},
+		{&jsonpb.MsgWithIndirectRequired{}, nil},
+		{&tpb.GoTestRequiredGroupField{}, []string{"group"}},
+		{&tpb.MyMessage{}, []string{"count"}},
+		{&tpb.RequiredInnerMessage{}, []string{"leo_finally_won_an_oscar"}},
+		{&proto3pb.Message{}, nil},
+	}
+	for _, tt := range tests {
+		_, md := descriptor.ForMessage(tt.msg)
+		var got []string
+		for _, f := range descriptor.RequiredFieldDescriptors(md) {
+			if f.GetLabel() != protobuf.FieldDescriptorProto_LABEL_REQUIRED {
+				t.Errorf("RequiredFieldDescriptors(%T) includes %s field %q", tt.msg, f.GetLabel(), f.GetName())
+			}
+			got = append(got, f.GetName())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RequiredFieldDescriptors(%T) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+var (
+	eFieldNote = &proto.ExtensionDesc{
+		ExtendedType:  (*protobuf.FieldOptions)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         50001,
+		Name:          "descriptor_test.field_note",
+		Tag:           "bytes,50001,opt,name=field_note",
+	}
+	eMessageNote = &proto.ExtensionDesc{
+		ExtendedType:  (*protobuf.MessageOptions)(nil),
+		ExtensionType: (*tpb.InnerMessage)(nil),
+		Field:         50002,
+		Name:          "descriptor_test.message_note",
+		Tag:           "bytes,50002,opt,name=message_note",
+	}
+)
+
+func init() {
+	proto.RegisterExtension(eFieldNote)
+	proto.RegisterExtension(eMessageNote)
+}
+
+func TestOptions(t *testing.T) {
+	fopts := new(protobuf.FieldOptions)
+	if err := proto.SetExtension(fopts, eFieldNote, proto.String("hello")); err != nil {
+		t.Fatal(err)
+	}
+	mopts := new(protobuf.MessageOptions)
+	if err := proto.SetExtension(mopts, eMessageNote, &tpb.InnerMessage{Host: proto.String("h")}); err != nil {
+		t.Fatal(err)
+	}
+	md := &protobuf.DescriptorProto{
+		Name:    proto.String("M"),
+		Field:   []*protobuf.FieldDescriptorProto{{Name: proto.String("f"), Options: fopts}, {Name: proto.String("g")}},
+		Options: mopts,
+	}
+	// Round-trip through the wire format so that the options are
+	// decoded lazily, as they are for descriptors read from a file.
+	b, err := proto.Marshal(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md = new(protobuf.DescriptorProto)
+	if err := proto.Unmarshal(b, md); err != nil {
+		t.Fatal(err)
+	}
+	f, g := md.Field[0], md.Field[1]
+
+	if v, ok := descriptor.FieldOption(f, eFieldNote); !ok || *v.(*string) != "hello" {
+		t.Errorf("FieldOption(f, field_note) = %v, %v; want hello, true", v, ok)
+	}
+	if !descriptor.HasFieldOption(f, eFieldNote) {
+		t.Errorf("HasFieldOption(f, field_note) = false, want true")
+	}
+	if v, ok := descriptor.FieldOption(g, eFieldNote); ok {
+		t.Errorf("FieldOption(g, field_note) = %v, true; want false", v)
+	}
+	if descriptor.HasFieldOption(g, eFieldNote) {
+		t.Errorf("HasFieldOption(g, field_note) = true, want false")
+	}
+	if v, ok := descriptor.FieldOption(f, eMessageNote); ok {
+		t.Errorf("FieldOption(f, message_note) = %v, true; want false", v)
+	}
+
+	want := &tpb.InnerMessage{Host: proto.String("h")}
+	if v, ok := descriptor.MessageOption(md, eMessageNote); !ok || !proto.Equal(v.(*tpb.InnerMessage), want) {
+		t.Errorf("MessageOption(md, message_note) = %v, %v; want %v, true", v, ok, want)
+	}
+	if !descriptor.HasMessageOption(md, eMessageNote) {
+		t.Errorf("HasMessageOption(md, message_note) = false, want true")
+	}
+	if descriptor.HasMessageOption(md, eFieldNote) {
+		t.Errorf("HasMessageOption(md, field_note) = true, want false")
+	}
+	if descriptor.HasMessageOption(&protobuf.DescriptorProto{}, eMessageNote) {
+		t.Errorf("HasMessageOption on a message without options = true, want false")
+	}
+}
+
+func TestExtensionRanges(t *testing.T) {
+	tests := []struct {
+		msg  descriptor.Message
+		want [][2]int32
+		in   []int32
+		out  []int32
+	}{{
+		msg:  (*tpb.MyMessage)(nil),
+		want: [][2]int32{{100, 536870912}},
+		in:   []int32{100, 103, 536870911},
+		out:  []int32{1, 99, 536870912},
+	}, {
+		msg:  (*tpb.MyMessageSet)(nil),
+		want: [][2]int32{{100, 2147483647}},
+		in:   []int32{100, 201, 536870912},
+		out:  []int32{0, 99, 2147483647},
+	}, {
+		msg: (*tpb.InnerMessage)(nil),
+		out: []int32{1, 100},
+	}, {
+		msg:  &protobuf.FieldOptions{},
+		want: [][2]int32{{1000, 536870912}},
+		in:   []int32{1000, 50000},
+		out:  []int32{999},
+	}}
+	for _, tt := range tests {
+		_, md := descriptor.ForMessage(tt.msg)
+		if got := descriptor.ExtensionRanges(md); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExtensionRanges(%s) = %v, want %v", md.GetName(), got, tt.want)
+		}
+		for _, n := range tt.in {
+			if !descriptor.InExtensionRange(md, n) {
+				t.Errorf("InExtensionRange(%s, %d) = false, want true", md.GetName(), n)
+			}
+		}
+		for _, n := range tt.out {
+			if descriptor.InExtensionRange(md, n) {
+				t.Errorf("InExtensionRange(%s, %d) = true, want false", md.GetName(), n)
+			}
+		}
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	// Generated descriptors are valid.
+	for _, msg := range []descriptor.Message{
+		(*tpb.MyMessage)(nil),
+		(*proto3pb.Message)(nil),
+		(*protobuf.FileDescriptorProto)(nil),
+		(*jsonpb.Maps)(nil),
+	} {
+		fd, _ := descriptor.ForMessage(msg)
+		if err := descriptor.ValidateFile(fd); err != nil {
+			t.Errorf("ValidateFile(%s): %v", fd.GetName(), err)
+		}
+	}
+
+	optional := protobuf.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := protobuf.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	int32Type := protobuf.FieldDescriptorProto_TYPE_INT32.Enum()
+	field := func(name string, num int32) *protobuf.FieldDescriptorProto {
+		return &protobuf.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(num), Label: optional, Type: int32Type}
+	}
+	oneofField := func(name string, num, idx int32) *protobuf.FieldDescriptorProto {
+		f := field(name, num)
+		f.OneofIndex = proto.Int32(idx)
+		return f
+	}
+	mapEntry := func(name string, fields ...*protobuf.FieldDescriptorProto) *protobuf.DescriptorProto {
+		return &protobuf.DescriptorProto{
+			Name:    proto.String(name),
+			Field:   fields,
+			Options: &protobuf.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+	}
+	value := func(name string, num int32) *protobuf.EnumValueDescriptorProto {
+		return &protobuf.EnumValueDescriptorProto{Name: proto.String(name), Number: proto.Int32(num)}
+	}
+	file := func(msgs []*protobuf.DescriptorProto, enums ...*protobuf.EnumDescriptorProto) *protobuf.FileDescriptorProto {
+		return &protobuf.FileDescriptorProto{
+			Name:        proto.String("bad.proto"),
+			Package:     proto.String("pkg"),
+			MessageType: msgs,
+			EnumType:    enums,
+		}
+	}
+
+	tests := []struct {
+		desc string
+		fd   *protobuf.FileDescriptorProto
+		want string // error substring, or "" for success
+	}{{
+		desc: "valid",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:           proto.String("M"),
+			Field:          []*protobuf.FieldDescriptorProto{field("a", 1), oneofField("b", 2, 0), {Name: proto.String("m"), Number: proto.Int32(3), Label: repeated, Type: protobuf.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".pkg.M.MEntry")}},
+			OneofDecl:      []*protobuf.OneofDescriptorProto{{Name: proto.String("o")}},
+			NestedType:     []*protobuf.DescriptorProto{mapEntry("MEntry", field("key", 1), field("value", 2))},
+			ReservedRange:  []*protobuf.DescriptorProto_ReservedRange{{Start: proto.Int32(10), End: proto.Int32(20)}},
+			ExtensionRange: []*protobuf.DescriptorProto_ExtensionRange{{Start: proto.Int32(100), End: proto.Int32(200)}},
+		}}, &protobuf.EnumDescriptorProto{
+			Name:    proto.String("E"),
+			Value:   []*protobuf.EnumValueDescriptorProto{value("ZERO", 0), value("ONE", 1), value("UNO", 1)},
+			Options: &protobuf.EnumOptions{AllowAlias: proto.Bool(true)},
+		}),
+	}, {
+		desc: "duplicate message name",
+		fd:   file([]*protobuf.DescriptorProto{{Name: proto.String("M")}, {Name: proto.String("M")}}),
+		want: `file "bad.proto": message "pkg.M": duplicate name`,
+	}, {
+		desc: "duplicate field name",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:  proto.String("M"),
+			Field: []*protobuf.FieldDescriptorProto{field("a", 1), field("a", 2)},
+		}}),
+		want: `field "pkg.M.a": duplicate name`,
+	}, {
+		desc: "duplicate field number",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:  proto.String("M"),
+			Field: []*protobuf.FieldDescriptorProto{field("a", 1), field("b", 1)},
+		}}),
+		want: `field "pkg.M.b": field number 1 is already used by "a"`,
+	}, {
+		desc: "invalid field number",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:  proto.String("M"),
+			Field: []*protobuf.FieldDescriptorProto{field("a", 0)},
+		}}),
+		want: `field "pkg.M.a": invalid field number 0`,
+	}, {
+		desc: "implementation reserved number",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:  proto.String("M"),
+			Field: []*protobuf.FieldDescriptorProto{field("a", 19000)},
+		}}),
+		want: `field number 19000 is reserved for the protocol buffer library implementation`,
+	}, {
+		desc: "field in reserved range",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:          proto.String("M"),
+			Field:         []*protobuf.FieldDescriptorProto{field("a", 15)},
+			ReservedRange: []*protobuf.DescriptorProto_ReservedRange{{Start: proto.Int32(10), End: proto.Int32(20)}},
+		}}),
+		want: `field "pkg.M.a": field number 15 is reserved`,
+	}, {
+		desc: "field with reserved name",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:         proto.String("M"),
+			Field:        []*protobuf.FieldDescriptorProto{field("a", 1)},
+			ReservedName: []string{"a"},
+		}}),
+		want: `field "pkg.M.a": field name is reserved`,
+	}, {
+		desc: "field in extension range",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:           proto.String("M"),
+			Field:          []*protobuf.FieldDescriptorProto{field("a", 100)},
+			ExtensionRange: []*protobuf.DescriptorProto_ExtensionRange{{Start: proto.Int32(100), End: proto.Int32(200)}},
+		}}),
+		want: `field "pkg.M.a": field number 100 is in an extension range`,
+	}, {
+		desc: "overlapping reserved ranges",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name: proto.String("M"),
+			ReservedRange: []*protobuf.DescriptorProto_ReservedRange{
+				{Start: proto.Int32(10), End: proto.Int32(20)},
+				{Start: proto.Int32(19), End: proto.Int32(30)},
+			},
+		}}),
+		want: `message "pkg.M": reserved range 19 to 29 overlaps 10 to 19`,
+	}, {
+		desc: "reserved range overlaps extension range",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:           proto.String("M"),
+			ReservedRange:  []*protobuf.DescriptorProto_ReservedRange{{Start: proto.Int32(10), End: proto.Int32(20)}},
+			ExtensionRange: []*protobuf.DescriptorProto_ExtensionRange{{Start: proto.Int32(15), End: proto.Int32(100)}},
+		}}),
+		want: `reserved or extension range 15 to 99 overlaps 10 to 19`,
+	}, {
+		desc: "empty reserved range",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:          proto.String("M"),
+			ReservedRange: []*protobuf.DescriptorProto_ReservedRange{{Start: proto.Int32(10), End: proto.Int32(10)}},
+		}}),
+		want: `message "pkg.M": invalid reserved range`,
+	}, {
+		desc: "oneof index out of range",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:      proto.String("M"),
+			Field:     []*protobuf.FieldDescriptorProto{oneofField("a", 1, 1)},
+			OneofDecl: []*protobuf.OneofDescriptorProto{{Name: proto.String("o")}},
+		}}),
+		want: `field "pkg.M.a": oneof index 1 is out of range`,
+	}, {
+		desc: "map entry with wrong field name",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:       proto.String("M"),
+			NestedType: []*protobuf.DescriptorProto{mapEntry("AEntry", field("k", 1), field("value", 2))},
+		}}),
+		want: `field "pkg.M.AEntry.k": map entry field 1 must be named "key"`,
+	}, {
+		desc: "map entry with extra field",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:       proto.String("M"),
+			NestedType: []*protobuf.DescriptorProto{mapEntry("AEntry", field("key", 1), field("value", 2), field("x", 3))},
+		}}),
+		want: `message "pkg.M.AEntry": map entry must have exactly two fields, got 3`,
+	}, {
+		desc: "map entry with bad name",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name:       proto.String("M"),
+			NestedType: []*protobuf.DescriptorProto{mapEntry("A", field("key", 1), field("value", 2))},
+		}}),
+		want: `message "pkg.M.A": map entry name must end with "Entry"`,
+	}, {
+		desc: "map entry with float key",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name: proto.String("M"),
+			NestedType: []*protobuf.DescriptorProto{mapEntry("AEntry",
+				&protobuf.FieldDescriptorProto{Name: proto.String("key"), Number: proto.Int32(1), Label: optional, Type: protobuf.FieldDescriptorProto_TYPE_FLOAT.Enum()},
+				field("value", 2))},
+		}}),
+		want: `field "pkg.M.AEntry.key": invalid map key type TYPE_FLOAT`,
+	}, {
+		desc: "map entry with repeated value",
+		fd: file([]*protobuf.DescriptorProto{{
+			Name: proto.String("M"),
+			NestedType: []*protobuf.DescriptorProto{mapEntry("AEntry", field("key", 1),
+				&protobuf.FieldDescriptorProto{Name: proto.String("value"), Number: proto.Int32(2), Label: repeated, Type: int32Type})},
+		}}),
+		want: `field "pkg.M.AEntry.value": map entry field must be optional`,
+	}, {
+		desc: "enum without values",
+		fd:   file(nil, &protobuf.EnumDescriptorProto{Name: proto.String("E")}),
+		want: `enum "pkg.E": enum must have at least one value`,
+	}, {
+		desc: "enum alias without allow_alias",
+		fd: file(nil, &protobuf.EnumDescriptorProto{
+			Name:  proto.String("E"),
+			Value: []*protobuf.EnumValueDescriptorProto{value("ONE", 1), value("UNO", 1)},
+		}),
+		want: `enum value "pkg.E.UNO": number 1 is already used by "ONE"`,
+	}, {
+		desc: "enum value in reserved range",
+		fd: file(nil, &protobuf.EnumDescriptorProto{
+			Name:          proto.String("E"),
+			Value:         []*protobuf.EnumValueDescriptorProto{value("MAX", 2147483647)},
+			ReservedRange: []*protobuf.EnumDescriptorProto_EnumReservedRange{{Start: proto.Int32(100), End: proto.Int32(2147483647)}},
+		}),
+		want: `enum value "pkg.E.MAX": number 2147483647 is reserved`,
+	}, {
+		desc: "enum value names share the enclosing scope",
+		fd: file([]*protobuf.DescriptorProto{{Name: proto.String("ZERO")}},
+			&protobuf.EnumDescriptorProto{Name: proto.String("E"), Value: []*protobuf.EnumValueDescriptorProto{value("ZERO", 0)}}),
+		want: `enum value "pkg.ZERO": duplicate name`,
+	}}
+	for _, tt := range tests {
+		err := descriptor.ValidateFile(tt.fd)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: ValidateFile() = %v, want nil", tt.desc, err)
+		case tt.want != "" && err == nil:
+			t.Errorf("%s: ValidateFile() = nil, want error containing %q", tt.desc, tt.want)
+		case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+			t.Errorf("%s: ValidateFile() = %v, want error containing %q", tt.desc, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	protobuf "github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// FilesFromSet returns the files in set ordered so that every file appears
+// after all of the files it imports.
+//
+// It returns an error if a file is listed more than once, if a file imports
+// a file that is not in the set, or if the imports form a cycle.
+func FilesFromSet(set *protobuf.FileDescriptorSet) ([]*protobuf.FileDescriptorProto, error) {
+	byName := make(map[string]*protobuf.FileDescriptorProto)
+	for _, fd := range set.GetFile() {
+		name := fd.GetName()
+		if _, ok := byName[name]; ok {
+			return nil, fmt.Errorf("descriptor: duplicate file %q in set", name)
+		}
+		byName[name] = fd
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	sorted := make([]*protobuf.FileDescriptorProto, 0, len(byName))
+	var path []string // chain of imports currently being visited
+
+	var visit func(fd *protobuf.FileDescriptorProto) error
+	visit = func(fd *protobuf.FileDescriptorProto) error {
+		name := fd.GetName()
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, p := range path {
+				if p == name {
+					cycle := append(path[i:len(path):len(path)], name)
+					return fmt.Errorf("descriptor: import cycle: %s", strings.Join(cycle, " -> "))
+				}
+			}
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range fd.GetDependency() {
+			depfd, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("descriptor: file %q imports %q, which is not in the set", name, dep)
+			}
+			if err := visit(depfd); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		sorted = append(sorted, fd)
+		return nil
+	}
+	for _, fd := range set.GetFile() {
+		if err := visit(fd); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// ForFiles returns a FileDescriptorSet holding the named files, as registered
+// with proto.RegisterFile, along with every file they transitively import.
+// The files are ordered so that every file appears after all of the files
+// it imports.
+//
+// It returns an error if any of the files is not registered.
+func ForFiles(filenames ...string) (*protobuf.FileDescriptorSet, error) {
+	set := new(protobuf.FileDescriptorSet)
+	seen := make(map[string]bool)
+	for len(filenames) > 0 {
+		name := filenames[0]
+		filenames = filenames[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		gz := proto.FileDescriptor(name)
+		if gz == nil {
+			return nil, fmt.Errorf("descriptor: file %q is not registered", name)
+		}
+		fd, err := extractFile(gz)
+		if err != nil {
+			return nil, fmt.Errorf("descriptor: file %q: %v", name, err)
+		}
+		set.File = append(set.File, fd)
+		filenames = append(filenames, fd.GetDependency()...)
+	}
+
+	files, err := FilesFromSet(set)
+	if err != nil {
+		return nil, err
+	}
+	set.File = files
+	return set, nil
+}
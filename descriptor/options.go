@@ -0,0 +1,89 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package descriptor
+
+import (
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	protobuf "github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// FieldOption returns the value of the custom option ext set on the field
+// described by fd. The value has the same type as ext.ExtensionType.
+// It reports false if the option is not set or if ext does not extend
+// google.protobuf.FieldOptions.
+func FieldOption(fd *protobuf.FieldDescriptorProto, ext *proto.ExtensionDesc) (interface{}, bool) {
+	if fd == nil || fd.Options == nil {
+		return nil, false
+	}
+	return getOption(fd.Options, ext)
+}
+
+// HasFieldOption reports whether the custom option ext is set on the field
+// described by fd.
+func HasFieldOption(fd *protobuf.FieldDescriptorProto, ext *proto.ExtensionDesc) bool {
+	_, ok := FieldOption(fd, ext)
+	return ok
+}
+
+// MessageOption returns the value of the custom option ext set on the
+// message described by md. The value has the same type as ext.ExtensionType.
+// It reports false if the option is not set or if ext does not extend
+// google.protobuf.MessageOptions.
+func MessageOption(md *protobuf.DescriptorProto, ext *proto.ExtensionDesc) (interface{}, bool) {
+	if md == nil || md.Options == nil {
+		return nil, false
+	}
+	return getOption(md.Options, ext)
+}
+
+// HasMessageOption reports whether the custom option ext is set on the
+// message described by md.
+func HasMessageOption(md *protobuf.DescriptorProto, ext *proto.ExtensionDesc) bool {
+	_, ok := MessageOption(md, ext)
+	return ok
+}
+
+func getOption(opts proto.Message, ext *proto.ExtensionDesc) (interface{}, bool) {
+	if ext == nil || reflect.TypeOf(ext.ExtendedType) != reflect.TypeOf(opts) {
+		return nil, false
+	}
+	if !proto.HasExtension(opts, ext) {
+		return nil, false
+	}
+	v, err := proto.GetExtension(opts, ext)
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
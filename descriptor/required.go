@@ -0,0 +1,109 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package descriptor
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	protobuf "github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// RequiredFieldDescriptors returns the fields of md that are declared
+// required, in declaration order. Use RequiredFields to also find the
+// required fields of nested messages.
+func RequiredFieldDescriptors(md *protobuf.DescriptorProto) []*protobuf.FieldDescriptorProto {
+	var fields []*protobuf.FieldDescriptorProto
+	for _, f := range md.GetField() {
+		if f.GetLabel() == protobuf.FieldDescriptorProto_LABEL_REQUIRED {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// RequiredFields returns the dotted paths of all required fields of msg,
+// including the required fields of its message fields, repeated message
+// fields, map values, and oneof members, found recursively.
+// Path components are the field names as declared in the .proto file,
+// without list indexes or map keys. For example, a required field "host"
+// in a repeated message field "others" is reported as "others.host".
+//
+// Recursive message types are descended into only once per path.
+// Message types that are not linked into the binary are not descended into.
+func RequiredFields(msg Message) []string {
+	var paths []string
+	appendRequiredFields(&paths, "", msg, map[reflect.Type]bool{})
+	return paths
+}
+
+// appendRequiredFields appends the required field paths of msg to paths.
+// active holds the message types currently being visited.
+func appendRequiredFields(paths *[]string, prefix string, msg Message, active map[reflect.Type]bool) {
+	t := reflect.TypeOf(msg)
+	if active[t] {
+		return
+	}
+	active[t] = true
+	defer delete(active, t)
+
+	_, md := ForMessage(msg)
+
+	for _, f := range md.GetField() {
+		path := prefix + f.GetName()
+		if f.GetLabel() == protobuf.FieldDescriptorProto_LABEL_REQUIRED {
+			*paths = append(*paths, path)
+		}
+		switch f.GetType() {
+		case protobuf.FieldDescriptorProto_TYPE_MESSAGE, protobuf.FieldDescriptorProto_TYPE_GROUP:
+			if sub := messageOf(f.GetTypeName()); sub != nil {
+				appendRequiredFields(paths, path+".", sub, active)
+			}
+		}
+	}
+}
+
+// messageOf returns a zero value of the message type with the given
+// fully-qualified name, or of the value type if it names a map entry.
+// It returns nil if the type is not linked in or has no descriptor.
+func messageOf(typeName string) Message {
+	t := proto.MessageType(strings.TrimPrefix(typeName, "."))
+	if t == nil {
+		return nil
+	}
+	if t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	m, _ := reflect.Zero(t).Interface().(Message)
+	return m
+}
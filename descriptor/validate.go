@@ -0,0 +1,347 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+
+	protobuf "github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+const (
+	maxFieldNumber      = 1<<29 - 1
+	messageSetMaxNumber = 1<<31 - 1
+	firstReservedNumber = 19000
+	lastReservedNumber  = 19999
+)
+
+// ValidateFile reports the first structural problem found in fd, such as
+// duplicate names or field numbers, field numbers inside reserved or
+// extension ranges, overlapping ranges, malformed map entry messages and
+// out of range oneof indexes.
+// It does not resolve type references, so imports need not be available.
+//
+// The error names the file, the declaration and the rule it violates.
+func ValidateFile(fd *protobuf.FileDescriptorProto) error {
+	v := &validator{file: fd.GetName()}
+	prefix := fd.GetPackage()
+	if prefix != "" {
+		prefix += "."
+	}
+	scope := make(map[string]bool)
+	for _, md := range fd.GetMessageType() {
+		if err := v.checkName(scope, "message", prefix, md.GetName()); err != nil {
+			return err
+		}
+	}
+	for _, ed := range fd.GetEnumType() {
+		if err := v.checkName(scope, "enum", prefix, ed.GetName()); err != nil {
+			return err
+		}
+		for _, vd := range ed.GetValue() {
+			if err := v.checkName(scope, "enum value", prefix, vd.GetName()); err != nil {
+				return err
+			}
+		}
+	}
+	for _, xd := range fd.GetExtension() {
+		if err := v.checkName(scope, "extension", prefix, xd.GetName()); err != nil {
+			return err
+		}
+		if err := v.checkExtension(prefix, xd); err != nil {
+			return err
+		}
+	}
+	for _, sd := range fd.GetService() {
+		if err := v.checkName(scope, "service", prefix, sd.GetName()); err != nil {
+			return err
+		}
+	}
+	for _, md := range fd.GetMessageType() {
+		if err := v.checkMessage(prefix, md); err != nil {
+			return err
+		}
+	}
+	for _, ed := range fd.GetEnumType() {
+		if err := v.checkEnum(prefix, ed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type validator struct {
+	file string
+}
+
+func (v *validator) errorf(kind, name, format string, args ...interface{}) error {
+	return fmt.Errorf("descriptor: file %q: %s %q: %s", v.file, kind, name, fmt.Sprintf(format, args...))
+}
+
+// checkName records name in scope, reporting an error if it is empty
+// or has already been declared.
+func (v *validator) checkName(scope map[string]bool, kind, prefix, name string) error {
+	if name == "" {
+		return v.errorf(kind, prefix, "missing name")
+	}
+	if scope[name] {
+		return v.errorf(kind, prefix+name, "duplicate name")
+	}
+	scope[name] = true
+	return nil
+}
+
+// checkRanges reports an error if any of the ranges are empty or overlap.
+// The ranges are half-open, [start, end).
+func (v *validator) checkRanges(kind, name, what string, rs [][2]int64) error {
+	for i, r := range rs {
+		if r[0] >= r[1] {
+			return v.errorf(kind, name, "invalid %s range %d to %d", what, r[0], r[1]-1)
+		}
+		for _, r2 := range rs[:i] {
+			if r[0] < r2[1] && r2[0] < r[1] {
+				return v.errorf(kind, name, "%s range %d to %d overlaps %d to %d", what, r[0], r[1]-1, r2[0], r2[1]-1)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *validator) checkMessage(prefix string, md *protobuf.DescriptorProto) error {
+	name := prefix + md.GetName()
+	scope := make(map[string]bool)
+	for _, f := range md.GetField() {
+		if err := v.checkName(scope, "field", name+".", f.GetName()); err != nil {
+			return err
+		}
+	}
+	for _, f := range md.GetExtension() {
+		if err := v.checkName(scope, "extension", name+".", f.GetName()); err != nil {
+			return err
+		}
+	}
+	for _, od := range md.GetOneofDecl() {
+		if err := v.checkName(scope, "oneof", name+".", od.GetName()); err != nil {
+			return err
+		}
+	}
+	for _, nd := range md.GetNestedType() {
+		if err := v.checkName(scope, "message", name+".", nd.GetName()); err != nil {
+			return err
+		}
+	}
+	for _, ed := range md.GetEnumType() {
+		if err := v.checkName(scope, "enum", name+".", ed.GetName()); err != nil {
+			return err
+		}
+		for _, vd := range ed.GetValue() {
+			if err := v.checkName(scope, "enum value", name+".", vd.GetName()); err != nil {
+				return err
+			}
+		}
+	}
+
+	var reserved, extensions [][2]int64
+	for _, r := range md.GetReservedRange() {
+		reserved = append(reserved, [2]int64{int64(r.GetStart()), int64(r.GetEnd())})
+	}
+	for _, r := range md.GetExtensionRange() {
+		extensions = append(extensions, [2]int64{int64(r.GetStart()), int64(r.GetEnd())})
+	}
+	if err := v.checkRanges("message", name, "reserved", reserved); err != nil {
+		return err
+	}
+	if err := v.checkRanges("message", name, "extension", extensions); err != nil {
+		return err
+	}
+	if err := v.checkRanges("message", name, "reserved or extension", append(append([][2]int64(nil), reserved...), extensions...)); err != nil {
+		return err
+	}
+	reservedNames := make(map[string]bool)
+	for _, s := range md.GetReservedName() {
+		reservedNames[s] = true
+	}
+
+	messageSet := md.GetOptions().GetMessageSetWireFormat()
+	numbers := make(map[int32]string)
+	for _, f := range md.GetField() {
+		fname := name + "." + f.GetName()
+		n := f.GetNumber()
+		switch {
+		case n <= 0 || (n > maxFieldNumber && !(messageSet && n <= messageSetMaxNumber)):
+			return v.errorf("field", fname, "invalid field number %d", n)
+		case firstReservedNumber <= n && n <= lastReservedNumber:
+			return v.errorf("field", fname, "field number %d is reserved for the protocol buffer library implementation", n)
+		case numbers[n] != "":
+			return v.errorf("field", fname, "field number %d is already used by %q", n, numbers[n])
+		case inRanges(reserved, n):
+			return v.errorf("field", fname, "field number %d is reserved", n)
+		case inRanges(extensions, n):
+			return v.errorf("field", fname, "field number %d is in an extension range", n)
+		case reservedNames[f.GetName()]:
+			return v.errorf("field", fname, "field name is reserved")
+		}
+		numbers[n] = f.GetName()
+		if f.OneofIndex != nil {
+			if i := f.GetOneofIndex(); i < 0 || int(i) >= len(md.GetOneofDecl()) {
+				return v.errorf("field", fname, "oneof index %d is out of range", i)
+			}
+		}
+	}
+	for _, xd := range md.GetExtension() {
+		if err := v.checkExtension(name+".", xd); err != nil {
+			return err
+		}
+	}
+	if md.GetOptions().GetMapEntry() {
+		if err := v.checkMapEntry(name, md); err != nil {
+			return err
+		}
+	}
+
+	for _, nd := range md.GetNestedType() {
+		if err := v.checkMessage(name+".", nd); err != nil {
+			return err
+		}
+	}
+	for _, ed := range md.GetEnumType() {
+		if err := v.checkEnum(name+".", ed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) checkExtension(prefix string, xd *protobuf.FieldDescriptorProto) error {
+	name := prefix + xd.GetName()
+	if n := xd.GetNumber(); n <= 0 || n > messageSetMaxNumber {
+		return v.errorf("extension", name, "invalid field number %d", n)
+	}
+	if xd.GetExtendee() == "" {
+		return v.errorf("extension", name, "missing extendee")
+	}
+	if xd.OneofIndex != nil {
+		return v.errorf("extension", name, "extension cannot be in a oneof")
+	}
+	return nil
+}
+
+func (v *validator) checkMapEntry(name string, md *protobuf.DescriptorProto) error {
+	switch {
+	case !strings.HasSuffix(md.GetName(), "Entry"):
+		return v.errorf("message", name, "map entry name must end with %q", "Entry")
+	case len(md.GetNestedType()) > 0 || len(md.GetEnumType()) > 0 || len(md.GetExtension()) > 0 ||
+		len(md.GetExtensionRange()) > 0 || len(md.GetOneofDecl()) > 0:
+		return v.errorf("message", name, "map entry must only declare key and value fields")
+	case len(md.GetField()) != 2:
+		return v.errorf("message", name, "map entry must have exactly two fields, got %d", len(md.GetField()))
+	}
+	for _, f := range md.GetField() {
+		fname := name + "." + f.GetName()
+		var want string
+		switch f.GetNumber() {
+		case 1:
+			want = "key"
+		case 2:
+			want = "value"
+		default:
+			return v.errorf("field", fname, "map entry field number must be 1 or 2")
+		}
+		if f.GetName() != want {
+			return v.errorf("field", fname, "map entry field %d must be named %q", f.GetNumber(), want)
+		}
+		if f.GetLabel() != protobuf.FieldDescriptorProto_LABEL_OPTIONAL {
+			return v.errorf("field", fname, "map entry field must be optional")
+		}
+		if f.GetNumber() == 1 {
+			switch f.GetType() {
+			case protobuf.FieldDescriptorProto_TYPE_DOUBLE,
+				protobuf.FieldDescriptorProto_TYPE_FLOAT,
+				protobuf.FieldDescriptorProto_TYPE_BYTES,
+				protobuf.FieldDescriptorProto_TYPE_MESSAGE,
+				protobuf.FieldDescriptorProto_TYPE_GROUP,
+				protobuf.FieldDescriptorProto_TYPE_ENUM:
+				return v.errorf("field", fname, "invalid map key type %v", f.GetType())
+			}
+		}
+	}
+	return nil
+}
+
+func (v *validator) checkEnum(prefix string, ed *protobuf.EnumDescriptorProto) error {
+	name := prefix + ed.GetName()
+	if len(ed.GetValue()) == 0 {
+		return v.errorf("enum", name, "enum must have at least one value")
+	}
+
+	// Enum reserved ranges are inclusive; convert them to half-open ranges.
+	var reserved [][2]int64
+	for _, r := range ed.GetReservedRange() {
+		reserved = append(reserved, [2]int64{int64(r.GetStart()), int64(r.GetEnd()) + 1})
+	}
+	if err := v.checkRanges("enum", name, "reserved", reserved); err != nil {
+		return err
+	}
+	reservedNames := make(map[string]bool)
+	for _, s := range ed.GetReservedName() {
+		reservedNames[s] = true
+	}
+
+	allowAlias := ed.GetOptions().GetAllowAlias()
+	numbers := make(map[int32]string)
+	for _, vd := range ed.GetValue() {
+		vname := name + "." + vd.GetName()
+		n := vd.GetNumber()
+		switch {
+		case numbers[n] != "" && !allowAlias:
+			return v.errorf("enum value", vname, "number %d is already used by %q; set allow_alias to permit aliases", n, numbers[n])
+		case inRanges(reserved, n):
+			return v.errorf("enum value", vname, "number %d is reserved", n)
+		case reservedNames[vd.GetName()]:
+			return v.errorf("enum value", vname, "value name is reserved")
+		}
+		if numbers[n] == "" {
+			numbers[n] = vd.GetName()
+		}
+	}
+	return nil
+}
+
+func inRanges(rs [][2]int64, n int32) bool {
+	for _, r := range rs {
+		if r[0] <= int64(n) && int64(n) < r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package jsontree parses the JSON produced by jsonpb into a tree that keeps
+the order of object members, for the packages that re-encode that JSON in
+other formats.
+*/
+package jsontree
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Member is a key and value of an object. Parse sets Key to a string;
+// callers converting from or to other formats may use other key types.
+type Member struct {
+	Key interface{}
+	Val interface{}
+}
+
+// Parse parses the JSON text js. The result, and each value nested in it,
+// is nil, a bool, a json.Number, a string, a []interface{} or a []Member,
+// and arrays and objects are never nil.
+func Parse(js string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(js))
+	dec.UseNumber()
+	return parse(dec)
+}
+
+func parse(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			v, err := parse(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err := dec.Token()
+		return a, err
+	case json.Delim('{'):
+		o := []Member{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := parse(dec)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, Member{k, v})
+		}
+		_, err := dec.Token()
+		return o, err
+	}
+	return tok, nil
+}
+
+// Number converts n to the narrowest Go type that holds it exactly:
+// an int64 or, failing that, a uint64 for integers that fit in 64 bits,
+// and otherwise a float32 or a float64.
+func Number(n json.Number) (interface{}, error) {
+	s := string(n)
+	if x, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return x, nil
+	}
+	if x, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return x, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	if float64(float32(f)) == f {
+		return float32(f), nil
+	}
+	return f, nil
+}
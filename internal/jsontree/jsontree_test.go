@@ -0,0 +1,85 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package jsontree
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse(`{"b":[1,"x",null],"a":{},"c":[],"d":true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Member{
+		{"b", []interface{}{json.Number("1"), "x", nil}},
+		{"a", []Member{}},
+		{"c", []interface{}{}},
+		{"d", true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %#v, want %#v", got, want)
+	}
+	for _, in := range []string{``, `[1,`, `{"a"}`} {
+		if got, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %#v, want error", in, got)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		in   json.Number
+		want interface{}
+	}{
+		{"0", int64(0)},
+		{"-9223372036854775808", int64(-9223372036854775808)},
+		{"18446744073709551615", uint64(18446744073709551615)},
+		{"18446744073709551616", float32(18446744073709551616)},
+		{"1.5", float32(1.5)},
+		{"0.1", float64(0.1)},
+		{"1e10", float32(1e10)},
+	}
+	for _, tt := range tests {
+		got, err := Number(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Number(%v) = %#v, %v; want %#v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []json.Number{"", "x", "1e400"} {
+		if got, err := Number(in); err == nil {
+			t.Errorf("Number(%q) = %#v, want error", in, got)
+		}
+	}
+}
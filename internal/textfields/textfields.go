@@ -0,0 +1,181 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package textfields holds the reflection helpers shared by the packages
+that encode a message field by field with scalars as text, protoflat and
+protoxml.
+
+Scalars use the Go syntax of package strconv, enums use their value
+names, and bytes use standard base64.
+*/
+package textfields
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Range calls f for each field of the generated message struct sv, in
+// order, stopping at the first error. A oneof is passed as its populated
+// member, with oneof set, and is skipped if no member is populated.
+func Range(sv reflect.Value, f func(prop *proto.Properties, v reflect.Value, oneof bool) error) error {
+	st := sv.Type()
+	sprops := proto.GetProperties(st)
+	for i, prop := range sprops.Prop {
+		if strings.HasPrefix(st.Field(i).Name, "XXX_") {
+			continue
+		}
+		fv := sv.Field(i)
+		if fv.Kind() == reflect.Interface {
+			// A oneof field; the value is a pointer to a wrapper struct
+			// holding the populated member.
+			if fv.IsNil() {
+				continue
+			}
+			w := fv.Elem().Elem()
+			if err := f(proto.GetProperties(w.Type()).Prop[0], w.Field(0), true); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := f(prop, fv, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsSet reports whether v, a singular field that is not part of a oneof,
+// is populated: a non-nil pointer, non-empty proto3 bytes, or a proto3
+// scalar other than zero.
+func IsSet(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr:
+		return !v.IsNil()
+	case reflect.Slice:
+		return v.Len() > 0
+	}
+	return v.Interface() != reflect.Zero(v.Type()).Interface()
+}
+
+// Field returns the field of the generated message struct sv whose name in
+// the .proto file is name, and its properties. If the field is a member of
+// a oneof, the oneof is set to it, keeping its value if it was already set,
+// and the returned value is the member within the oneof's wrapper struct.
+// Field returns a nil prop if there is no such field.
+func Field(sv reflect.Value, name string) (fv reflect.Value, prop *proto.Properties) {
+	sprops := proto.GetProperties(sv.Type())
+	if oop, ok := sprops.OneofTypes[name]; ok {
+		fv = sv.Field(oop.Field)
+		if fv.IsNil() || fv.Elem().Type() != oop.Type {
+			fv.Set(reflect.New(oop.Type.Elem()))
+		}
+		return fv.Elem().Elem().Field(0), oop.Prop
+	}
+	for i, p := range sprops.Prop {
+		if p.OrigName == name && p.Tag != 0 {
+			return sv.Field(i), p
+		}
+	}
+	return reflect.Value{}, nil
+}
+
+// FormatScalar returns the text of the scalar v, which has properties prop.
+func FormatScalar(v reflect.Value, prop *proto.Properties) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int32:
+		if s, ok := v.Interface().(fmt.Stringer); ok && prop != nil && prop.Enum != "" {
+			return s.String()
+		}
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Slice:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	}
+	return v.String()
+}
+
+// ParseScalar sets the scalar v, which has properties prop, from the text s.
+// Enums accept either a value name or a number.
+func ParseScalar(v reflect.Value, prop *proto.Properties, s string) error {
+	var err error
+	switch v.Kind() {
+	case reflect.Bool:
+		var x bool
+		x, err = strconv.ParseBool(s)
+		v.SetBool(x)
+	case reflect.Int32, reflect.Int64:
+		if prop != nil && prop.Enum != "" {
+			if x, ok := proto.EnumValueMap(prop.Enum)[s]; ok {
+				v.SetInt(int64(x))
+				return nil
+			}
+		}
+		var x int64
+		x, err = strconv.ParseInt(s, 10, v.Type().Bits())
+		v.SetInt(x)
+	case reflect.Uint32, reflect.Uint64:
+		var x uint64
+		x, err = strconv.ParseUint(s, 10, v.Type().Bits())
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		x, err = strconv.ParseFloat(s, v.Type().Bits())
+		v.SetFloat(x)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice:
+		var b []byte
+		b, err = base64.StdEncoding.DecodeString(s)
+		v.SetBytes(b)
+	default:
+		return fmt.Errorf("unsupported field type %v", v.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %v", s, v.Type())
+	}
+	return nil
+}
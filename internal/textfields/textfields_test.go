@@ -0,0 +1,110 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package textfields
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
+)
+
+func TestFieldAndRange(t *testing.T) {
+	m := &pb.Communique{MakeMeCry: proto.Bool(false)}
+	sv := reflect.ValueOf(m).Elem()
+
+	fv, prop := Field(sv, "col")
+	if prop == nil {
+		t.Fatal(`Field("col") found no field`)
+	}
+	if err := ParseScalar(fv, prop, "GREEN"); err != nil {
+		t.Fatalf("ParseScalar: %v", err)
+	}
+	if got, want := m.GetCol(), pb.MyMessage_GREEN; got != want {
+		t.Errorf("after setting col, GetCol() = %v, want %v", got, want)
+	}
+	if _, prop := Field(sv, "Col"); prop != nil {
+		t.Errorf(`Field("Col") = %v, want no field`, prop.OrigName)
+	}
+
+	var got []string
+	err := Range(sv, func(prop *proto.Properties, v reflect.Value, oneof bool) error {
+		if oneof || IsSet(v) {
+			got = append(got, prop.OrigName+"="+FormatScalar(reflect.Indirect(v), prop))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	if want := []string{"make_me_cry=false", "col=GREEN"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Range visited %q, want %q", got, want)
+	}
+}
+
+func TestScalar(t *testing.T) {
+	m := new(pb.Communique)
+	sv := reflect.ValueOf(m).Elem()
+	for _, tt := range []struct {
+		field, in, want string
+	}{
+		{"col", "2", "BLUE"},
+		{"data", "AP8=", "AP8="},
+		{"temp_c", "1e-3", "0.001"},
+		{"number", "-7", "-7"},
+	} {
+		fv, prop := Field(sv, tt.field)
+		if err := ParseScalar(fv, prop, tt.in); err != nil {
+			t.Errorf("ParseScalar(%s, %q): %v", tt.field, tt.in, err)
+			continue
+		}
+		if got := FormatScalar(fv, prop); got != tt.want {
+			t.Errorf("FormatScalar(%s) after parsing %q = %q, want %q", tt.field, tt.in, got, tt.want)
+		}
+	}
+	for _, tt := range []struct{ field, in string }{
+		{"col", "PURPLE"},
+		{"number", "2147483648"},
+		{"data", "!"},
+		{"make_me_cry", "yes"},
+	} {
+		fv, prop := Field(sv, tt.field)
+		if fv.Kind() == reflect.Ptr {
+			fv.Set(reflect.New(fv.Type().Elem()))
+			fv = fv.Elem()
+		}
+		if err := ParseScalar(fv, prop, tt.in); err == nil {
+			t.Errorf("ParseScalar(%s, %q) succeeded, want error", tt.field, tt.in)
+		}
+	}
+}
@@ -82,6 +82,12 @@ type Marshaler struct {
 	// fully-qualified type name from the type URL and pass that to
 	// proto.MessageType(string).
 	AnyResolver AnyResolver
+
+	// Whether to render a google.protobuf.Value holding NaN, Infinity or
+	// -Infinity as the strings "NaN", "Infinity" and "-Infinity".
+	// Such numbers cannot be represented in JSON, so by default
+	// marshaling them returns an error.
+	NonFiniteNumbersAsStrings bool
 }
 
 // AnyResolver takes a type URL, present in an Any message, and resolves it into
@@ -274,6 +280,11 @@ func (m *Marshaler) marshalObject(out *errWriter, v proto.Message, indent, typeU
 			}
 			// oneof -> *T -> T -> T.F
 			x := kind.Elem().Elem().Field(0)
+			if x.Kind() == reflect.Float64 && !m.NonFiniteNumbersAsStrings {
+				if f := x.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+					return fmt.Errorf("non-finite number %v in Value cannot be represented in JSON", f)
+				}
+			}
 			// TODO: pass the correct Properties if needed.
 			return m.marshalValue(out, &proto.Properties{}, x, indent)
 		}
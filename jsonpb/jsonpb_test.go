@@ -579,6 +579,37 @@ func TestMarshalIllegalTime(t *testing.T) {
 	}
 }
 
+func TestMarshalNonFiniteValue(t *testing.T) {
+	num := func(f float64) *stpb.Value { return &stpb.Value{Kind: &stpb.Value_NumberValue{f}} }
+	tests := []struct {
+		desc string
+		pb   proto.Message
+		json string // output with NonFiniteNumbersAsStrings set
+	}{
+		{"NaN", &pb.KnownTypes{Val: num(math.NaN())}, `{"val":"NaN"}`},
+		{"Infinity", num(math.Inf(1)), `"Infinity"`},
+		{"-Infinity", num(math.Inf(-1)), `"-Infinity"`},
+		{"ListValue element", &stpb.ListValue{Values: []*stpb.Value{num(1), num(math.NaN())}}, `[1,"NaN"]`},
+		{"nested Struct", &stpb.Struct{Fields: map[string]*stpb.Value{
+			"a": {Kind: &stpb.Value_StructValue{&stpb.Struct{Fields: map[string]*stpb.Value{
+				"b": {Kind: &stpb.Value_ListValue{&stpb.ListValue{Values: []*stpb.Value{num(math.Inf(-1))}}}},
+			}}}},
+		}}, `{"a":{"b":["-Infinity"]}}`},
+	}
+	for _, tt := range tests {
+		if _, err := new(Marshaler).MarshalToString(tt.pb); err == nil || !strings.Contains(err.Error(), "non-finite") {
+			t.Errorf("%s: marshaling error = %v, want non-finite number error", tt.desc, err)
+		}
+		m := &Marshaler{NonFiniteNumbersAsStrings: true}
+		json, err := m.MarshalToString(tt.pb)
+		if err != nil {
+			t.Errorf("%s: marshaling error with NonFiniteNumbersAsStrings: %v", tt.desc, err)
+		} else if json != tt.json {
+			t.Errorf("%s: got [%v] want [%v]", tt.desc, json, tt.json)
+		}
+	}
+}
+
 func TestMarshalJSONPBMarshaler(t *testing.T) {
 	rawJson := `{ "foo": "bar", "baz": [0, 1, 2, 3] }`
 	msg := dynamicMessage{RawJson: rawJson}
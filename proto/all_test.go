@@ -33,6 +33,7 @@ package proto_test
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -40,16 +41,19 @@ import (
 	"math/rand"
 	"reflect"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/golang/protobuf/jsonpb"
 	. "github.com/golang/protobuf/proto"
 	pb3 "github.com/golang/protobuf/proto/proto3_proto"
 	. "github.com/golang/protobuf/proto/test_proto"
 	descriptorpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	anypb "github.com/golang/protobuf/ptypes/any"
 )
 
 var globalO *Buffer
@@ -1227,6 +1231,113 @@ func TestPackedNonPackedDecoderSwitching(t *testing.T) {
 	}
 }
 
+// Decoders must accept both packed and non-packed encodings for every
+// repeated scalar field, regardless of how the field is declared.
+func TestPackedNonPackedAllKinds(t *testing.T) {
+	goTestBase, err := Marshal(initGoTest(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newGoTest := func() Message { return new(GoTest) }
+	f32 := func(f float32) uint64 { return uint64(math.Float32bits(f)) }
+	f64 := math.Float64bits
+
+	tests := []struct {
+		name  string
+		num   int
+		wire  int
+		enc   func(*Buffer, uint64) error
+		elems []uint64
+		base  []byte
+		new   func() Message
+		get   func(Message) interface{}
+		want  interface{}
+	}{
+		{"bool", 20, WireVarint, (*Buffer).EncodeVarint, []uint64{1, 0, 1}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_BoolRepeated }, []bool{true, false, true}},
+		{"bool packed", 50, WireVarint, (*Buffer).EncodeVarint, []uint64{1, 0, 1}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_BoolRepeatedPacked }, []bool{true, false, true}},
+		{"int32", 21, WireVarint, (*Buffer).EncodeVarint, []uint64{1, 1 << 20, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Int32Repeated }, []int32{1, 1 << 20, -1}},
+		{"int32 packed", 51, WireVarint, (*Buffer).EncodeVarint, []uint64{1, 1 << 20, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Int32RepeatedPacked }, []int32{1, 1 << 20, -1}},
+		{"int64", 22, WireVarint, (*Buffer).EncodeVarint, []uint64{1, 1 << 40, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Int64Repeated }, []int64{1, 1 << 40, -1}},
+		{"int64 packed", 52, WireVarint, (*Buffer).EncodeVarint, []uint64{1, 1 << 40, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Int64RepeatedPacked }, []int64{1, 1 << 40, -1}},
+		{"fixed32", 23, WireFixed32, (*Buffer).EncodeFixed32, []uint64{1, math.MaxUint32}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Fixed32Repeated }, []uint32{1, math.MaxUint32}},
+		{"fixed32 packed", 53, WireFixed32, (*Buffer).EncodeFixed32, []uint64{1, math.MaxUint32}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Fixed32RepeatedPacked }, []uint32{1, math.MaxUint32}},
+		{"fixed64", 24, WireFixed64, (*Buffer).EncodeFixed64, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Fixed64Repeated }, []uint64{1, math.MaxUint64}},
+		{"fixed64 packed", 54, WireFixed64, (*Buffer).EncodeFixed64, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Fixed64RepeatedPacked }, []uint64{1, math.MaxUint64}},
+		{"uint32", 25, WireVarint, (*Buffer).EncodeVarint, []uint64{1, math.MaxUint32}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Uint32Repeated }, []uint32{1, math.MaxUint32}},
+		{"uint32 packed", 55, WireVarint, (*Buffer).EncodeVarint, []uint64{1, math.MaxUint32}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Uint32RepeatedPacked }, []uint32{1, math.MaxUint32}},
+		{"uint64", 26, WireVarint, (*Buffer).EncodeVarint, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Uint64Repeated }, []uint64{1, math.MaxUint64}},
+		{"uint64 packed", 56, WireVarint, (*Buffer).EncodeVarint, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Uint64RepeatedPacked }, []uint64{1, math.MaxUint64}},
+		{"float", 27, WireFixed32, (*Buffer).EncodeFixed32, []uint64{f32(1.5), f32(-2)}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_FloatRepeated }, []float32{1.5, -2}},
+		{"float packed", 57, WireFixed32, (*Buffer).EncodeFixed32, []uint64{f32(1.5), f32(-2)}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_FloatRepeatedPacked }, []float32{1.5, -2}},
+		{"double", 28, WireFixed64, (*Buffer).EncodeFixed64, []uint64{f64(1.5), f64(-2)}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_DoubleRepeated }, []float64{1.5, -2}},
+		{"double packed", 58, WireFixed64, (*Buffer).EncodeFixed64, []uint64{f64(1.5), f64(-2)}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_DoubleRepeatedPacked }, []float64{1.5, -2}},
+		{"sint32", 202, WireVarint, (*Buffer).EncodeZigzag32, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Sint32Repeated }, []int32{1, -1}},
+		{"sint32 packed", 502, WireVarint, (*Buffer).EncodeZigzag32, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Sint32RepeatedPacked }, []int32{1, -1}},
+		{"sint64", 203, WireVarint, (*Buffer).EncodeZigzag64, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Sint64Repeated }, []int64{1, -1}},
+		{"sint64 packed", 503, WireVarint, (*Buffer).EncodeZigzag64, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Sint64RepeatedPacked }, []int64{1, -1}},
+		{"sfixed32", 204, WireFixed32, (*Buffer).EncodeFixed32, []uint64{1, math.MaxUint32}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Sfixed32Repeated }, []int32{1, -1}},
+		{"sfixed32 packed", 504, WireFixed32, (*Buffer).EncodeFixed32, []uint64{1, math.MaxUint32}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Sfixed32RepeatedPacked }, []int32{1, -1}},
+		{"sfixed64", 205, WireFixed64, (*Buffer).EncodeFixed64, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Sfixed64Repeated }, []int64{1, -1}},
+		{"sfixed64 packed", 505, WireFixed64, (*Buffer).EncodeFixed64, []uint64{1, math.MaxUint64}, goTestBase, newGoTest,
+			func(m Message) interface{} { return m.(*GoTest).F_Sfixed64RepeatedPacked }, []int64{1, -1}},
+		{"enum", 1, WireVarint, (*Buffer).EncodeVarint, []uint64{1, 1}, nil, func() Message { return new(RepeatedEnum) },
+			func(m Message) interface{} { return m.(*RepeatedEnum).Color }, []RepeatedEnum_Color{RepeatedEnum_RED, RepeatedEnum_RED}},
+		{"enum packed", 16, WireVarint, (*Buffer).EncodeVarint, []uint64{1, 2}, nil, func() Message { return new(pb3.Message) },
+			func(m Message) interface{} { return m.(*pb3.Message).RFunny }, []pb3.Message_Humour{pb3.Message_PUNS, pb3.Message_SLAPSTICK}},
+	}
+	for _, tt := range tests {
+		unpacked := NewBuffer(append([]byte(nil), tt.base...))
+		payload := NewBuffer(nil)
+		for _, e := range tt.elems {
+			unpacked.EncodeVarint(uint64(tt.num)<<3 | uint64(tt.wire))
+			tt.enc(unpacked, e)
+			tt.enc(payload, e)
+		}
+		packed := NewBuffer(append([]byte(nil), tt.base...))
+		packed.EncodeVarint(uint64(tt.num)<<3 | WireBytes)
+		packed.EncodeRawBytes(payload.Bytes())
+
+		for _, enc := range []struct {
+			name string
+			b    []byte
+		}{{"non-packed", unpacked.Bytes()}, {"packed", packed.Bytes()}} {
+			m := tt.new()
+			if err := Unmarshal(enc.b, m); err != nil {
+				t.Errorf("%s: Unmarshal(%s): %v", tt.name, enc.name, err)
+				continue
+			}
+			if got := tt.get(m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: Unmarshal(%s) = %v, want %v", tt.name, enc.name, got, tt.want)
+			}
+		}
+	}
+}
+
 func TestProto1RepeatedGroup(t *testing.T) {
 	pb := &MessageList{
 		Message: []*MessageList_Message{
@@ -1400,6 +1511,39 @@ func TestAllSetDefaults(t *testing.T) {
 	}
 }
 
+func TestSetDefaultsMatchesGetters(t *testing.T) {
+	// After SetDefaults, every field with a declared default is set to
+	// the value its getter returns for a fresh message.
+	m := new(Defaults)
+	SetDefaults(m)
+	v := reflect.ValueOf(m).Elem()
+	fresh := reflect.ValueOf(new(Defaults))
+	n := 0
+	for i, p := range GetProperties(v.Type()).Prop {
+		if !p.HasDefault {
+			continue
+		}
+		n++
+		f := v.Field(i)
+		if f.IsNil() {
+			t.Errorf("field %s with default %q is unset after SetDefaults", p.OrigName, p.Default)
+			continue
+		}
+		if f.Kind() == reflect.Ptr {
+			f = f.Elem()
+		}
+		get := fresh.MethodByName("Get" + v.Type().Field(i).Name)
+		want := get.Call(nil)[0]
+		// Compare the formatted values, so that NaN equals NaN.
+		if got, want := fmt.Sprint(f.Interface()), fmt.Sprint(want.Interface()); got != want {
+			t.Errorf("field %s = %s after SetDefaults, want %s", p.OrigName, got, want)
+		}
+	}
+	if n == 0 {
+		t.Fatal("Defaults has no fields with defaults")
+	}
+}
+
 func TestSetDefaultsWithSetField(t *testing.T) {
 	// Check that a set value is not overridden.
 	m := &Defaults{
@@ -1543,6 +1687,355 @@ func TestVarintOverflow(t *testing.T) {
 	}
 }
 
+func TestPeekTag(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		num  int
+		wire int
+		ok   bool
+	}{
+		{[]byte{1<<3 | WireVarint, 0x01}, 1, WireVarint, true},
+		{[]byte{2<<3 | WireFixed64}, 2, WireFixed64, true},
+		{[]byte{15<<3 | WireBytes}, 15, WireBytes, true},
+		{[]byte{0x83, 0x01}, 16, WireStartGroup, true},
+		{[]byte{0x84, 0x01}, 16, WireEndGroup, true},
+		{[]byte{0xfd, 0xff, 0x03}, 8191, WireFixed32, true},
+		{[]byte{0xf8, 0xff, 0xff, 0xff, 0x0f}, 536870911, WireVarint, true},
+		{nil, 0, 0, false},
+		{[]byte{0x80}, 0, 0, false},
+		{[]byte{0xf8, 0xff, 0xff}, 0, 0, false},
+		{[]byte{0x00}, 0, 0, false},
+		{[]byte{0x80, 0x80, 0x80, 0x80, 0x10}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		b := NewBuffer(tt.in)
+		num, wire, ok := b.PeekTag()
+		if num != tt.num || wire != tt.wire || ok != tt.ok {
+			t.Errorf("PeekTag(%x) = %d, %d, %v; want %d, %d, %v", tt.in, num, wire, ok, tt.num, tt.wire, tt.ok)
+		}
+		// Peeking again gives the same result, and decoding consumes the tag.
+		if num2, wire2, ok2 := b.PeekTag(); num2 != num || wire2 != wire || ok2 != ok {
+			t.Errorf("second PeekTag(%x) = %d, %d, %v; want %d, %d, %v", tt.in, num2, wire2, ok2, num, wire, ok)
+		}
+		if !tt.ok {
+			continue
+		}
+		x, err := b.DecodeVarint()
+		if err != nil || int(x>>3) != num || int(x&7) != wire {
+			t.Errorf("DecodeVarint(%x) after PeekTag = %#x, %v", tt.in, x, err)
+		}
+	}
+}
+
+func TestBufferMaxBytes(t *testing.T) {
+	msg := &GoTestField{Label: String("label"), Type: String("type")}
+	b, err := Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(b)
+
+	for _, max := range []int{0, -1, n, n + 1} {
+		buf := NewBuffer(b)
+		buf.SetMaxBytes(max)
+		got := new(GoTestField)
+		if err := buf.Unmarshal(got); err != nil {
+			t.Errorf("SetMaxBytes(%d): Unmarshal of %d bytes: %v", max, n, err)
+		} else if !Equal(got, msg) {
+			t.Errorf("SetMaxBytes(%d): Unmarshal = %v, want %v", max, got, msg)
+		}
+	}
+
+	buf := NewBuffer(b)
+	buf.SetMaxBytes(n - 1)
+	got := new(GoTestField)
+	if err := buf.Unmarshal(got); err == nil {
+		t.Errorf("SetMaxBytes(%d): Unmarshal of %d bytes succeeded, want error", n-1, n)
+	}
+	if got.Label != nil || got.Type != nil {
+		t.Errorf("SetMaxBytes(%d): Unmarshal decoded %v from oversized input", n-1, got)
+	}
+
+	// The limit applies to each delimited message.
+	buf = NewBuffer(nil)
+	buf.EncodeMessage(msg)
+	buf.EncodeMessage(&GoTestField{Label: String(strings.Repeat("x", n)), Type: String("type")})
+	buf.SetMaxBytes(n)
+	if err := buf.DecodeMessage(new(GoTestField)); err != nil {
+		t.Errorf("DecodeMessage of %d bytes with limit %d: %v", n, n, err)
+	}
+	if err := buf.DecodeMessage(new(GoTestField)); err == nil {
+		t.Errorf("DecodeMessage of oversized message with limit %d succeeded, want error", n)
+	}
+}
+
+func TestBufferCanonicalUnknown(t *testing.T) {
+	tag := func(num, wire int) []byte {
+		return EncodeVarint(uint64(num)<<3 | uint64(wire))
+	}
+	field := func(num int, v uint64) []byte {
+		return append(tag(num, WireVarint), EncodeVarint(v)...)
+	}
+	cat := func(bs ...[]byte) []byte {
+		var b []byte
+		for _, x := range bs {
+			b = append(b, x...)
+		}
+		return b
+	}
+	newMsg := func() *MyMessage {
+		return &MyMessage{
+			Count: Int32(1),
+			Inner: &InnerMessage{
+				Host:             String("h"),
+				XXX_unrecognized: cat(field(30, 1), field(20, 2)),
+			},
+			XXX_unrecognized: cat(field(50, 1), field(40, 2), field(50, 3), field(45, 4)),
+		}
+	}
+	m := newMsg()
+
+	b := NewBuffer(nil)
+	if err := b.Marshal(m); err != nil {
+		t.Fatal(err)
+	}
+	unsorted := b.Bytes()
+
+	b = NewBuffer(nil)
+	b.SetCanonicalUnknown(true)
+	if err := b.Marshal(m); err != nil {
+		t.Fatal(err)
+	}
+	sorted := b.Bytes()
+	if len(sorted) != len(unsorted) {
+		t.Fatalf("canonical output has %d bytes, want %d", len(sorted), len(unsorted))
+	}
+	if !Equal(m, newMsg()) {
+		t.Errorf("Marshal modified the message: %v", m)
+	}
+
+	inner := cat(
+		tag(1, WireBytes), []byte{1, 'h'}, // host: "h"
+		field(20, 2), field(30, 1),
+	)
+	want := cat(
+		field(1, 1), // count: 1
+		tag(5, WireBytes), EncodeVarint(uint64(len(inner))), inner,
+		field(40, 2), field(45, 4), field(50, 1), field(50, 3),
+	)
+	if !bytes.Equal(sorted, want) {
+		t.Errorf("canonical output:\n got %x\nwant %x", sorted, want)
+	}
+	if bytes.Equal(unsorted, want) {
+		t.Errorf("default output is sorted; want unknown fields in insertion order")
+	}
+
+	// Sorting is idempotent and leaves ordered messages alone.
+	var m2 MyMessage
+	if err := Unmarshal(sorted, &m2); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := b.Marshal(&m2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), sorted) {
+		t.Errorf("re-marshaling sorted output:\n got %x\nwant %x", b.Bytes(), sorted)
+	}
+}
+
+// stringData returns the address of the bytes of s.
+func stringData(s string) uintptr {
+	return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+}
+
+func TestBufferInternStrings(t *testing.T) {
+	newMsg := func() *MyMessage {
+		return &MyMessage{
+			Count: Int32(1),
+			Name:  String("region-1"),
+			Pet:   []string{"dog", "cat"},
+		}
+	}
+	newMap := func() *MessageWithMap {
+		return &MessageWithMap{StrToStr: map[string]string{"k": "status-200"}}
+	}
+
+	for _, intern := range []bool{false, true} {
+		buf := NewBuffer(nil)
+		for i := 0; i < 2; i++ {
+			buf.EncodeMessage(newMsg())
+			buf.EncodeMessage(newMap())
+		}
+		buf.SetInternStrings(intern)
+		var msgs [2]*MyMessage
+		var maps [2]*MessageWithMap
+		for i := range msgs {
+			msgs[i], maps[i] = new(MyMessage), new(MessageWithMap)
+			if err := buf.DecodeMessage(msgs[i]); err != nil {
+				t.Fatal(err)
+			}
+			if err := buf.DecodeMessage(maps[i]); err != nil {
+				t.Fatal(err)
+			}
+			if !Equal(msgs[i], newMsg()) || !Equal(maps[i], newMap()) {
+				t.Fatalf("decoded %v, %v; want %v, %v", msgs[i], maps[i], newMsg(), newMap())
+			}
+		}
+		shared := map[string]bool{
+			"name": stringData(*msgs[0].Name) == stringData(*msgs[1].Name),
+			"pet":  stringData(msgs[0].Pet[1]) == stringData(msgs[1].Pet[1]),
+			"map":  stringData(maps[0].StrToStr["k"]) == stringData(maps[1].StrToStr["k"]),
+		}
+		for what, got := range shared {
+			if got != intern {
+				t.Errorf("SetInternStrings(%v): %s strings shared = %v, want %v", intern, what, got, intern)
+			}
+		}
+	}
+
+	// Interned strings are not allocated again.
+	vocab := &MyMessage{Count: Int32(1), Name: String("region-1")}
+	for i := 0; i < 100; i++ {
+		vocab.Pet = append(vocab.Pet, "status-200")
+	}
+	enc, err := Marshal(vocab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocs := func(intern bool) float64 {
+		buf := NewBuffer(nil)
+		buf.SetInternStrings(intern)
+		m := &MyMessage{Pet: make([]string, 0, len(vocab.Pet))}
+		return testing.AllocsPerRun(100, func() {
+			buf.SetBuf(enc)
+			m.Pet = m.Pet[:0]
+			if err := buf.Unmarshal(m); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	if plain, interned := allocs(false), allocs(true); interned > plain-float64(len(vocab.Pet)) {
+		t.Errorf("Unmarshal made %v allocations with interning, %v without; want at least %d fewer", interned, plain, len(vocab.Pet))
+	}
+
+	// Only a bounded number of distinct strings are interned.
+	big := &MyMessage{Count: Int32(1)}
+	for i := 0; i < 1000; i++ {
+		big.Pet = append(big.Pet, strconv.Itoa(i))
+	}
+	buf := NewBuffer(nil)
+	buf.EncodeMessage(big)
+	buf.EncodeMessage(&MyMessage{Count: Int32(1), Pet: []string{"0", "extra"}})
+	buf.EncodeMessage(&MyMessage{Count: Int32(1), Pet: []string{"0", "extra"}})
+	buf.SetInternStrings(true)
+	var a, b, c MyMessage
+	for _, m := range []*MyMessage{&a, &b, &c} {
+		if err := buf.DecodeMessage(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stringData(a.Pet[0]) != stringData(c.Pet[0]) {
+		t.Errorf("string decoded before the limit was not interned")
+	}
+	if stringData(b.Pet[1]) == stringData(c.Pet[1]) {
+		t.Errorf("string decoded after the limit was interned")
+	}
+}
+
+func TestSetDiscardUnknownGroup(t *testing.T) {
+	// An unknown group (field 10) holding a varint and a nested unknown
+	// group (field 11), between the two known fields of GoTestField.
+	group := []byte{
+		0x53,       // start group 10
+		0x08, 0x05, // field 1, varint 5
+		0x5b,            // start group 11
+		0x12, 0x01, 'x', // field 2, bytes "x"
+		0x5c, // end group 11
+		0x54, // end group 10
+	}
+	var in []byte
+	in = append(in, 0x0a, 0x01, 'a') // Label: "a"
+	in = append(in, group...)
+	in = append(in, 0x12, 0x01, 'b') // Type: "b"
+	known := &GoTestField{Label: String("a"), Type: String("b")}
+
+	for _, discard := range []bool{false, true} {
+		buf := NewBuffer(nil)
+		buf.EncodeRawBytes(in)
+		buf.EncodeRawBytes(in)
+		buf.SetDiscardUnknown(discard)
+
+		b := NewBuffer(in)
+		b.SetDiscardUnknown(discard)
+		decoded := [2]*GoTestField{new(GoTestField), new(GoTestField)}
+		if err := b.Unmarshal(decoded[0]); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if err := buf.DecodeMessage(decoded[1]); err != nil {
+			t.Fatalf("DecodeMessage: %v", err)
+		}
+
+		for _, m := range decoded {
+			if m.GetLabel() != "a" || m.GetType() != "b" {
+				t.Errorf("SetDiscardUnknown(%v): known fields decoded as %v, want %v", discard, m, known)
+			}
+			want := group
+			if discard {
+				want = nil
+			}
+			if !bytes.Equal(m.XXX_unrecognized, want) {
+				t.Errorf("SetDiscardUnknown(%v): unknown fields = %x, want %x", discard, m.XXX_unrecognized, want)
+			}
+			got, err := Marshal(m)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			wantBytes, _ := Marshal(known)
+			wantBytes = append(wantBytes, want...)
+			if !bytes.Equal(got, wantBytes) {
+				t.Errorf("SetDiscardUnknown(%v): re-marshaled to %x, want %x", discard, got, wantBytes)
+			}
+		}
+	}
+}
+
+func TestSetDiscardUnknownMerge(t *testing.T) {
+	var in []byte
+	in = append(in, 0x08, 0x01)                              // key: 1
+	in = append(in, 0x30, 0x02)                              // unknown field 6
+	in = append(in, 0xa0, 0x06, 0x07)                        // extension field 100
+	in = append(in, 0x22, 0x05, 0x0a, 0x01, 'h', 0x48, 0x03) // inner: {host: "h", unknown field 9}
+
+	// Unknown fields already in the message being merged into are kept;
+	// only those being decoded are dropped.
+	old := []byte{0x28, 0x01} // unknown field 5
+	m := &OtherMessage{XXX_unrecognized: append([]byte(nil), old...)}
+	b := NewBuffer(in)
+	b.SetDiscardUnknown(true)
+	if err := b.Unmarshal(m); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if m.GetKey() != 1 || m.GetInner().GetHost() != "h" {
+		t.Errorf("known fields decoded as %v", m)
+	}
+	if !bytes.Equal(m.XXX_unrecognized, old) {
+		t.Errorf("unknown fields = %x, want %x", m.XXX_unrecognized, old)
+	}
+	if len(m.GetInner().XXX_unrecognized) != 0 {
+		t.Errorf("unknown fields of inner message = %x, want none", m.GetInner().XXX_unrecognized)
+	}
+
+	// Fields in extension ranges are kept, as DiscardUnknown keeps them.
+	got, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !bytes.Contains(got, []byte{0xa0, 0x06, 0x07}) {
+		t.Errorf("re-marshaled to %x, want extension field 100 kept", got)
+	}
+}
+
 func TestBytesWithInvalidLengthInGroup(t *testing.T) {
 	// Overflowing a 64-bit length should not be allowed.
 	b := []byte{0xbb, 0x30, 0xb2, 0x30, 0xb0, 0xb2, 0x83, 0xf1, 0xb0, 0xb2, 0xef, 0xbf, 0xbd, 0x01}
@@ -1780,6 +2273,80 @@ func TestUnmarshalMergesMessages(t *testing.T) {
 	}
 }
 
+func TestGroupEncoding(t *testing.T) {
+	tests := []struct {
+		desc  string
+		m     Message
+		want  []byte
+		group int // offset of the first group's start tag in want
+	}{
+		{
+			desc:  "singular",
+			m:     &MyMessage{Count: Int32(1), Somegroup: &MyMessage_SomeGroup{GroupField: Int32(8)}},
+			want:  []byte{0x08, 0x01, 0x43, 0x48, 0x08, 0x44},
+			group: 2,
+		},
+		{
+			desc:  "multi-byte tags",
+			m:     &GroupNew{G: &GroupNew_G{X: Int32(1), Y: Int32(2)}},
+			want:  []byte{0xab, 0x06, 0x10, 0x01, 0x18, 0x02, 0xac, 0x06},
+			group: 0,
+		},
+		{
+			desc: "repeated",
+			m: &MessageList{Message: []*MessageList_Message{
+				{Name: String("x"), Count: Int32(1)},
+				{Name: String("y"), Count: Int32(2)},
+			}},
+			want: []byte{
+				0x0b, 0x12, 0x01, 'x', 0x18, 0x01, 0x0c,
+				0x0b, 0x12, 0x01, 'y', 0x18, 0x02, 0x0c,
+			},
+			group: 0,
+		},
+		{
+			desc:  "oneof",
+			m:     &Oneof{Union: &Oneof_FGroup{FGroup: &Oneof_F_Group{X: Int32(5)}}},
+			want:  []byte{0x83, 0x01, 0x88, 0x01, 0x05, 0x84, 0x01},
+			group: 0,
+		},
+		{
+			desc:  "empty",
+			m:     &GroupNew{G: &GroupNew_G{}},
+			want:  []byte{0xab, 0x06, 0xac, 0x06},
+			group: 0,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(tt.m)
+		if err != nil {
+			t.Errorf("%s: Marshal: %v", tt.desc, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: Marshal = %x, want %x", tt.desc, got, tt.want)
+		}
+		if n := Size(tt.m); n != len(tt.want) {
+			t.Errorf("%s: Size = %d, want %d", tt.desc, n, len(tt.want))
+		}
+		m := newOf(tt.m)
+		if err := Unmarshal(tt.want, m); err != nil {
+			t.Errorf("%s: Unmarshal: %v", tt.desc, err)
+		} else if !Equal(m, tt.m) {
+			t.Errorf("%s: Unmarshal = %v, want %v", tt.desc, m, tt.m)
+		}
+		// Input that ends inside the group is an error.
+		for n := tt.group + 1; n < len(tt.want); n++ {
+			if tt.desc == "repeated" && n == len(tt.want)/2 {
+				continue // ends between the two groups
+			}
+			if err := Unmarshal(tt.want[:n], newOf(tt.m)); err == nil {
+				t.Errorf("%s: Unmarshal(%x) of truncated group succeeded", tt.desc, tt.want[:n])
+			}
+		}
+	}
+}
+
 func TestUnmarshalMergesGroups(t *testing.T) {
 	// If a nested group occurs twice in the input,
 	// the fields should be merged when decoding.
@@ -1817,6 +2384,61 @@ func TestUnmarshalMergesGroups(t *testing.T) {
 	}
 }
 
+func TestUnmarshalMergeReusesMessages(t *testing.T) {
+	// Merging into a message with a populated nested message
+	// should decode into the existing nested message.
+	inner := &InnerMessage{Port: Int32(1234)}
+	got := &OtherMessage{Inner: inner}
+	data, err := Marshal(&OtherMessage{Inner: &InnerMessage{Host: String("polhode")}})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if err := UnmarshalMerge(data, got); err != nil {
+		t.Fatalf("UnmarshalMerge: %v", err)
+	}
+	if got.Inner != inner {
+		t.Errorf("UnmarshalMerge replaced the nested message")
+	}
+	want := &OtherMessage{Inner: &InnerMessage{Host: String("polhode"), Port: Int32(1234)}}
+	if !Equal(got, want) {
+		t.Errorf("\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestUnmarshalMergesOneofMessages(t *testing.T) {
+	// If the same oneof message case occurs twice in the input,
+	// the fields should be merged when decoding.
+	aData, err := Marshal(&Communique{Union: &Communique_Msg{&Strings{StringField: String("a")}}})
+	if err != nil {
+		t.Fatalf("Marshal(a): %v", err)
+	}
+	bData, err := Marshal(&Communique{Union: &Communique_Msg{&Strings{BytesField: []byte("b")}}})
+	if err != nil {
+		t.Fatalf("Marshal(b): %v", err)
+	}
+	want := &Communique{Union: &Communique_Msg{&Strings{StringField: String("a"), BytesField: []byte("b")}}}
+	got := new(Communique)
+	if err := Unmarshal(append(aData, bData...), got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !Equal(got, want) {
+		t.Errorf("\n got %v\nwant %v", got, want)
+	}
+
+	// A different case still replaces the value.
+	cData, err := Marshal(&Communique{Union: &Communique_Number{5}})
+	if err != nil {
+		t.Fatalf("Marshal(c): %v", err)
+	}
+	if err := UnmarshalMerge(cData, got); err != nil {
+		t.Fatalf("UnmarshalMerge: %v", err)
+	}
+	want = &Communique{Union: &Communique_Number{5}}
+	if !Equal(got, want) {
+		t.Errorf("\n got %v\nwant %v", got, want)
+	}
+}
+
 func TestEncodingSizes(t *testing.T) {
 	tests := []struct {
 		m Message
@@ -2252,6 +2874,49 @@ func TestConcurrentMarshal(t *testing.T) {
 	}
 }
 
+// TestConcurrentFirstUse makes sure that the per-type info caches are safe
+// when many goroutines look up types concurrently, including types that
+// have not been used before.
+func TestConcurrentFirstUse(t *testing.T) {
+	msgs := []Message{
+		&GoEnum{Foo: FOO_FOO1.Enum()},
+		&NonPackedTest{A: []int32{1, 2, 3}},
+		&PackedTest{B: []int32{1, 2, 3}},
+		&GroupOld{G: &GroupOld_G{X: Int32(1)}},
+		&GroupNew{G: &GroupNew_G{X: Int32(1), Y: Int32(2)}},
+		&FloatingPoint{F: Float64(1.5)},
+		&MoreRepeated{Ints: []int32{1}, Strings: []string{"a"}},
+		&SubDefaults{N: Int64(3)},
+	}
+	const N = 16
+
+	var wg sync.WaitGroup
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, m := range msgs {
+				b, err := Marshal(m)
+				if err != nil {
+					t.Errorf("Marshal(%T) error: %v", m, err)
+					continue
+				}
+				m2 := Clone(m)
+				m2.Reset()
+				if err := Unmarshal(b, m2); err != nil {
+					t.Errorf("Unmarshal(%T) error: %v", m, err)
+					continue
+				}
+				if !Equal(m, m2) {
+					t.Errorf("round trip of %T: got %v, want %v", m, m2, m)
+				}
+				DiscardUnknown(m2)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func TestInvalidUTF8(t *testing.T) {
 	const invalidUTF8 = "\xde\xad\xbe\xef\x80\x00\xff"
 	tests := []struct {
@@ -2424,6 +3089,41 @@ func benchmarkBufferUnmarshal(b *testing.B, pb Message) {
 	})
 }
 
+// plainMessage has no XXX methods, so every Marshal and Unmarshal
+// looks up its info in the global per-type caches.
+type plainMessage struct {
+	Count *int32  `protobuf:"varint,1,opt,name=count"`
+	Name  *string `protobuf:"bytes,2,opt,name=name"`
+}
+
+func (*plainMessage) Reset()         {}
+func (*plainMessage) String() string { return "" }
+func (*plainMessage) ProtoMessage()  {}
+
+// BenchmarkParallelInfoLookup measures contention on the per-type
+// info caches when many goroutines encode and decode concurrently.
+func BenchmarkParallelInfoLookup(b *testing.B) {
+	m := &plainMessage{Count: Int32(42), Name: String("Dave")}
+	d, err := Marshal(m)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(d)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		m2 := new(plainMessage)
+		for pb.Next() {
+			if _, err := Marshal(m); err != nil {
+				b.Fatal(err)
+			}
+			*m2 = plainMessage{}
+			if err := Unmarshal(d, m2); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 // Benchmark{Marshal,BufferMarshal,Size,Unmarshal,BufferUnmarshal}{,Bytes}
 
 func BenchmarkMarshal(b *testing.B) {
@@ -2466,6 +3166,68 @@ func BenchmarkBufferUnmarshalBytes(b *testing.B) {
 	benchmarkBufferUnmarshal(b, bytesMsg())
 }
 
+func validate(d []byte, pb Message) error {
+	_, err := Validate(d, pb)
+	return err
+}
+
+func BenchmarkValidate(b *testing.B) {
+	benchmarkUnmarshal(b, testMsg(), validate)
+}
+
+func BenchmarkValidateBytes(b *testing.B) {
+	benchmarkUnmarshal(b, bytesMsg(), validate)
+}
+
+func BenchmarkValidateGoTest(b *testing.B) {
+	benchmarkUnmarshal(b, initGoTest(true), validate)
+}
+
+func BenchmarkUnmarshalGoTest(b *testing.B) {
+	benchmarkUnmarshal(b, initGoTest(true), Unmarshal)
+}
+
+func newLargeRepeated(n int) *MoreRepeated {
+	m := new(MoreRepeated)
+	for i := 0; i < n; i++ {
+		m.Bools = append(m.Bools, i%2 == 0)
+		m.BoolsPacked = append(m.BoolsPacked, i%3 == 0)
+		m.Ints = append(m.Ints, int32(i))
+		m.IntsPacked = append(m.IntsPacked, int32(-i))
+		m.Int64SPacked = append(m.Int64SPacked, int64(i)<<40)
+		m.Fixeds = append(m.Fixeds, uint32(i))
+	}
+	return m
+}
+
+// BenchmarkUnmarshalReuse decodes a large repeated-field payload into one
+// message, either resetting it normally or keeping its slice capacity.
+func BenchmarkUnmarshalReuse(b *testing.B) {
+	data, err := Marshal(newLargeRepeated(1000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Unmarshal", func(b *testing.B) {
+		m := new(MoreRepeated)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := Unmarshal(data, m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ResetKeepCapacity", func(b *testing.B) {
+		m := new(MoreRepeated)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ResetKeepCapacity(m)
+			if err := UnmarshalMerge(data, m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func BenchmarkUnmarshalUnrecognizedFields(b *testing.B) {
 	b.StopTimer()
 	pb := initGoTestField()
@@ -2493,6 +3255,34 @@ func BenchmarkUnmarshalUnrecognizedFields(b *testing.B) {
 	}
 }
 
+// BenchmarkUnmarshalFieldDispatch compares decoding a field found through
+// the dense, tag-indexed field table with one found through the sparse map
+// used for large field numbers.
+func BenchmarkUnmarshalFieldDispatch(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		msg  *Oneof
+	}{
+		{"Dense", &Oneof{Union: &Oneof_F_Int32{F_Int32: 1}}},
+		{"Sparse", &Oneof{Union: &Oneof_F_Largest_Tag{F_Largest_Tag: 1}}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			buf, err := Marshal(bm.msg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			m := new(Oneof)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := Unmarshal(buf, m); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 // TestRace tests whether there are races among the different marshalers.
 func TestRace(t *testing.T) {
 	m := &descriptorpb.FileDescriptorProto{
@@ -2522,3 +3312,302 @@ func TestRace(t *testing.T) {
 		m.String()
 	}()
 }
+
+func TestPackedEncodingSelection(t *testing.T) {
+	// records returns the wire types of the records of each field in b.
+	records := func(b []byte) map[int][]int {
+		m := make(map[int][]int)
+		for len(b) > 0 {
+			num, wire, n := DecodeTag(b)
+			if n == 0 {
+				t.Fatalf("bad tag in %x", b)
+			}
+			_, l := DecodeFieldValue(num, wire, b[n:])
+			if l == 0 {
+				t.Fatalf("bad field %d in %x", num, b)
+			}
+			m[num] = append(m[num], wire)
+			b = b[n+l:]
+		}
+		return m
+	}
+	packed := []int{WireBytes}
+
+	gt := initGoTest(false)
+	gt.F_Int32Repeated = []int32{1, 2, 3}
+	gt.F_Sint64Repeated = []int64{-1, 1}
+	gt.F_Int32RepeatedPacked = []int32{1, 2, 3}
+	gt.F_DoubleRepeatedPacked = []float64{1, 2}
+	b, err := Marshal(gt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := records(b)
+	for _, tt := range []struct {
+		num  int
+		want []int
+	}{
+		{21, []int{WireVarint, WireVarint, WireVarint}},
+		{203, []int{WireVarint, WireVarint}},
+		{51, packed},
+		{58, packed},
+	} {
+		if !reflect.DeepEqual(got[tt.num], tt.want) {
+			t.Errorf("proto2 field %d encoded with wire types %v, want %v", tt.num, got[tt.num], tt.want)
+		}
+	}
+
+	// Repeated scalars in proto3 are packed by default.
+	b, err = Marshal(&pb3.Message{
+		Key:      []uint64{1, 2, 3},
+		ShortKey: []int32{-1, 1},
+		RFunny:   []pb3.Message_Humour{pb3.Message_PUNS, pb3.Message_SLAPSTICK},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = records(b)
+	for _, num := range []int{5, 16, 19} {
+		if !reflect.DeepEqual(got[num], packed) {
+			t.Errorf("proto3 field %d encoded with wire types %v, want packed", num, got[num])
+		}
+	}
+}
+
+func TestMust(t *testing.T) {
+	m := &GoTest{Kind: GoTest_TIME.Enum()}
+	if got := Must(m, nil); got != m {
+		t.Errorf("Must(m, nil) = %p, want %p", got, m)
+	}
+
+	errBad := errors.New("bad input")
+	defer func() {
+		r := recover()
+		e, ok := r.(*MustError)
+		if !ok {
+			t.Fatalf("Must(m, err) panicked with %#v, want a *MustError", r)
+		}
+		if e.Err != errBad {
+			t.Errorf("Must(m, err) panicked with Err %v, want %v", e.Err, errBad)
+		}
+		for _, want := range []string{"test_proto.GoTest", "bad input"} {
+			if !strings.Contains(e.Error(), want) {
+				t.Errorf("Must(m, err) panic %q does not contain %q", e.Error(), want)
+			}
+		}
+	}()
+	Must(m, errBad)
+	t.Errorf("Must(m, err) did not panic")
+}
+
+func TestIsNil(t *testing.T) {
+	for _, tt := range []struct {
+		m    Message
+		want bool
+	}{
+		{nil, true},
+		{(*pb3.Message)(nil), true},
+		{(*GoTest)(nil), true},
+		{&pb3.Message{}, false},
+		{&pb3.Message{Name: "name", Nested: &pb3.Nested{Bunny: "bunny"}}, false},
+	} {
+		if got := IsNil(tt.m); got != tt.want {
+			t.Errorf("IsNil(%#v) = %v, want %v", tt.m, got, tt.want)
+		}
+	}
+}
+
+// unregisteredMessage is a message type not registered with RegisterType.
+type unregisteredMessage struct{}
+
+func (*unregisteredMessage) Reset()         {}
+func (*unregisteredMessage) String() string { return "" }
+func (*unregisteredMessage) ProtoMessage()  {}
+
+func TestMessageName(t *testing.T) {
+	for _, tt := range []struct {
+		m    Message
+		want string
+	}{
+		{&GoTest{}, "test_proto.GoTest"},
+		{(*GoTest)(nil), "test_proto.GoTest"},
+		{&GoTest_RequiredGroup{}, "test_proto.GoTest.RequiredGroup"},
+		{&pb3.Message{}, "proto3_proto.Message"},
+		{&pb3.Nested{}, "proto3_proto.Nested"},
+		{&anypb.Any{}, "google.protobuf.Any"},
+		{&descriptorpb.FileDescriptorProto{}, "google.protobuf.FileDescriptorProto"},
+		{&unregisteredMessage{}, ""},
+		{nil, ""},
+	} {
+		if got := MessageName(tt.m); got != tt.want {
+			t.Errorf("MessageName(%T) = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestResetKeepCapacity(t *testing.T) {
+	want := newLargeRepeated(100)
+	want.XXX_unrecognized = []byte{0xa0, 0x06, 0x01} // field 100, varint 1
+	data, err := Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := new(MoreRepeated)
+	if err := Unmarshal(data, m); err != nil {
+		t.Fatal(err)
+	}
+	ints := m.IntsPacked
+
+	ResetKeepCapacity(m)
+	if !Equal(m, new(MoreRepeated)) {
+		t.Errorf("after ResetKeepCapacity, message = %v, want empty", m)
+	}
+	if cap(m.IntsPacked) != cap(ints) || cap(m.XXX_unrecognized) == 0 {
+		t.Errorf("ResetKeepCapacity did not keep the capacity of the repeated and unknown fields")
+	}
+	if err := UnmarshalMerge(data, m); err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(m, want) {
+		t.Errorf("UnmarshalMerge after ResetKeepCapacity = %v, want %v", m, want)
+	}
+	if &m.IntsPacked[0] != &ints[0] {
+		t.Errorf("UnmarshalMerge after ResetKeepCapacity allocated a new IntsPacked slice")
+	}
+
+	// Other fields are cleared as by Reset.
+	msg := &MyMessage{
+		Count:    Int32(1),
+		Inner:    &InnerMessage{Host: String("h")},
+		RepInner: []*InnerMessage{{Host: String("r")}},
+		RepBytes: [][]byte{[]byte("x")},
+	}
+	inner := msg.RepInner[:1]
+	ResetKeepCapacity(msg)
+	if !Equal(msg, new(MyMessage)) || msg.Count != nil || msg.Inner != nil {
+		t.Errorf("after ResetKeepCapacity, message = %v, want empty", msg)
+	}
+	if inner[0] != nil {
+		t.Errorf("ResetKeepCapacity kept a reference to a repeated message")
+	}
+	mm := &MessageWithMap{NameMapping: map[int32]string{1: "a"}, ByteMapping: map[bool][]byte{true: {1}}}
+	ResetKeepCapacity(mm)
+	if len(mm.NameMapping) != 0 || len(mm.ByteMapping) != 0 {
+		t.Errorf("after ResetKeepCapacity, maps = %v, %v; want empty", mm.NameMapping, mm.ByteMapping)
+	}
+	pb3m := &pb3.Message{Name: "n", Data: []byte{1}, Key: []uint64{1}}
+	ResetKeepCapacity(pb3m)
+	if !Equal(pb3m, new(pb3.Message)) || pb3m.Data != nil {
+		t.Errorf("after ResetKeepCapacity, message = %v, want empty", pb3m)
+	}
+	ResetKeepCapacity(nil)
+	ResetKeepCapacity((*MyMessage)(nil))
+}
+
+func TestNew(t *testing.T) {
+	m, err := New("proto3_proto.Message")
+	if err != nil {
+		t.Fatalf("New(proto3_proto.Message): %v", err)
+	}
+	got, ok := m.(*pb3.Message)
+	if !ok || got == nil {
+		t.Fatalf("New(proto3_proto.Message) = %#v, want a non-nil *pb3.Message", m)
+	}
+	if !Equal(got, new(pb3.Message)) {
+		t.Errorf("New(proto3_proto.Message) = %v, want an empty message", got)
+	}
+	if m2, _ := New("proto3_proto.Message"); m2 == m {
+		t.Errorf("New returned the same message twice")
+	}
+
+	for _, tt := range []struct {
+		name, want string
+	}{
+		{"no.such.Message", "not registered"},
+		{"", "not registered"},
+		{"proto3_proto.Message_Humour", "not a message type"},
+		{"proto3_proto.Message.TerrainEntry", "not a message type"},
+	} {
+		m, err := New(tt.name)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("New(%q) = %v, %v; want error containing %q", tt.name, m, err, tt.want)
+		}
+	}
+}
+
+// TestPointerImplementationParity checks the deterministic encodings of a
+// corpus covering every kind of field against digests that are the same
+// for both the unsafe and the purego (pointer_reflect.go) builds.
+// "make test" runs the tests in both modes.
+func TestPointerImplementationParity(t *testing.T) {
+	full := initGoTest(true)
+	full.F_Int32Repeated = []int32{-1, 1}
+	full.F_Sint64Repeated = []int64{-1, 1}
+	full.F_BytesRepeated = [][]byte{{}, []byte("x")}
+	full.F_FloatRepeatedPacked = []float32{1.5}
+	full.F_Sfixed64RepeatedPacked = []int64{-2}
+	full.Repeatedgroup = []*GoTest_RepeatedGroup{initGoTest_RepeatedGroup(), initGoTest_RepeatedGroup()}
+	full.RepeatedField = []*GoTestField{initGoTestField()}
+
+	ext := &MyMessage{Count: Int32(1)}
+	if err := SetExtension(ext, E_Ext_More, &Ext{Data: String("more")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetExtension(ext, E_Greeting, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	msgSet := &MyMessageSet{}
+	if err := SetExtension(msgSet, E_X201, &Empty{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		m      Message
+		digest string
+	}{
+		{full, "7d26d7cc3076449203168e6ffcd21d84f121e73898095f08bc61b8c0f399c595"},
+		{&pb3.Message{
+			Name:        "name",
+			Hilarity:    pb3.Message_PUNS,
+			Data:        []byte("data"),
+			ResultCount: -1,
+			Key:         []uint64{1, 1 << 40},
+			ShortKey:    []int32{-1},
+			RFunny:      []pb3.Message_Humour{pb3.Message_SLAPSTICK},
+			Nested:      &pb3.Nested{Bunny: "bunny"},
+			Terrain:     map[string]*pb3.Nested{"a": {Cute: true}, "b": {}},
+			Proto2Field: &SubDefaults{N: Int64(3)},
+			Children:    []*pb3.Message{{Name: "child"}},
+			StringMap:   map[string]string{"k": "v"},
+		}, "70bfb8b6c2af5f3d808342537433d28807e029b67cb6e570410544769c28a80b"},
+		{&MessageWithMap{
+			NameMapping: map[int32]string{-1: "a", 2: "b"},
+			MsgMapping:  map[int64]*FloatingPoint{3: {F: Float64(1)}},
+			ByteMapping: map[bool][]byte{false: {}, true: []byte("t")},
+			StrToStr:    map[string]string{"x": "y"},
+		}, "222f3f2fca08aa1afb7f8dec8be0e6ee54aa41170b1e7b56a705c02797c05622"},
+		{&Communique{MakeMeCry: Bool(true), Union: &Communique_Msg{Msg: &Strings{StringField: String("s")}}}, "1f9df774a4719aef3034c621c96d3cec2640c2a7b911839291726e7732e301ca"},
+		{&Communique{Union: &Communique_Col{Col: MyMessage_BLUE}}, "dc718c3cdc53942026bc3cbb38c6af549d95530806da07e77c654d504e7b2226"},
+		{ext, "d0987ccb00a012801f53935f336dbad83fd5f83eea96d8f3550558d66168a85b"},
+		{msgSet, "6cc02f3f20481c3123b9ef71ac7222321a291916e6d7e376779786fec0116c13"},
+	}
+	for i, tt := range tests {
+		var b Buffer
+		b.SetDeterministic(true)
+		if err := b.Marshal(tt.m); err != nil {
+			t.Errorf("#%d: Marshal: %v", i, err)
+			continue
+		}
+		if got := fmt.Sprintf("%x", sha256.Sum256(b.Bytes())); got != tt.digest {
+			t.Errorf("#%d: digest of %T encoding (%d bytes) = %s, want %s", i, tt.m, len(b.Bytes()), got, tt.digest)
+		}
+		got := reflect.New(reflect.TypeOf(tt.m).Elem()).Interface().(Message)
+		if err := Unmarshal(b.Bytes(), got); err != nil {
+			t.Errorf("#%d: Unmarshal: %v", i, err)
+			continue
+		}
+		if !Equal(got, tt.m) {
+			t.Errorf("#%d: Unmarshal(Marshal(%v)) = %v", i, tt.m, got)
+		}
+	}
+}
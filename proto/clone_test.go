@@ -32,6 +32,7 @@
 package proto_test
 
 import (
+	"math"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -114,6 +115,49 @@ func TestClone(t *testing.T) {
 	}
 }
 
+func TestCloneNaNBits(t *testing.T) {
+	nan32s := []uint32{
+		0x7fc00000, // quiet NaN
+		0x7fa00000, // signaling NaN
+		0xffc12345, // negative quiet NaN with a payload
+		0x7f800001, // signaling NaN with a payload
+	}
+	nan64s := []uint64{
+		0x7ff8000000000000, // quiet NaN
+		0x7ff4000000000000, // signaling NaN
+		0xfff8000000012345, // negative quiet NaN with a payload
+		0x7ff0000000000001, // signaling NaN with a payload
+	}
+	for i := range nan32s {
+		f, d := math.Float32frombits(nan32s[i]), math.Float64frombits(nan64s[i])
+		m := &pb.GoTest{
+			F_FloatOptional:        proto.Float32(f),
+			F_DoubleOptional:       proto.Float64(d),
+			F_FloatRepeated:        []float32{1, f},
+			F_DoubleRepeatedPacked: []float64{d, 2},
+		}
+		c := proto.Clone(m).(*pb.GoTest)
+		if got := math.Float32bits(c.GetF_FloatOptional()); got != nan32s[i] {
+			t.Errorf("Clone: F_FloatOptional bits = %#x, want %#x", got, nan32s[i])
+		}
+		if got := math.Float64bits(c.GetF_DoubleOptional()); got != nan64s[i] {
+			t.Errorf("Clone: F_DoubleOptional bits = %#x, want %#x", got, nan64s[i])
+		}
+		if got := math.Float32bits(c.F_FloatRepeated[1]); got != nan32s[i] {
+			t.Errorf("Clone: F_FloatRepeated[1] bits = %#x, want %#x", got, nan32s[i])
+		}
+		if got := math.Float64bits(c.F_DoubleRepeatedPacked[0]); got != nan64s[i] {
+			t.Errorf("Clone: F_DoubleRepeatedPacked[0] bits = %#x, want %#x", got, nan64s[i])
+		}
+
+		m3 := &proto3pb.Message{Score: f}
+		c3 := proto.Clone(m3).(*proto3pb.Message)
+		if got := math.Float32bits(c3.Score); got != nan32s[i] {
+			t.Errorf("Clone: proto3 Score bits = %#x, want %#x", got, nan32s[i])
+		}
+	}
+}
+
 func TestCloneNil(t *testing.T) {
 	var m *pb.MyMessage
 	if c := proto.Clone(m); !proto.Equal(m, c) {
@@ -270,6 +314,29 @@ var mergeTests = []struct {
 		dst:  &pb.Communique{Union: &pb.Communique_Name{"Bobby Tables"}},
 		want: &pb.Communique{Union: &pb.Communique_Name{"Bobby Tables"}},
 	},
+	{ // A different oneof member in src replaces the one set in dst.
+		src:  &pb.Oneof{Union: &pb.Oneof_F_Enum{pb.MyMessage_BLUE}},
+		dst:  &pb.Oneof{Union: &pb.Oneof_F_String{"dst"}},
+		want: &pb.Oneof{Union: &pb.Oneof_F_Enum{pb.MyMessage_BLUE}},
+	},
+	{
+		src:  &pb.Oneof{},
+		dst:  &pb.Oneof{Union: &pb.Oneof_F_String{"dst"}},
+		want: &pb.Oneof{Union: &pb.Oneof_F_String{"dst"}},
+	},
+	{ // A message member is replaced, not merged, by a different member.
+		src: &pb.Oneof{Union: &pb.Oneof_F_String{"src"}},
+		dst: &pb.Oneof{Union: &pb.Oneof_F_Message{&pb.GoTestField{
+			Label: proto.String("label"),
+			Type:  proto.String("type"),
+		}}},
+		want: &pb.Oneof{Union: &pb.Oneof_F_String{"src"}},
+	},
+	{ // Each oneof in a message is merged independently.
+		src:  &pb.Oneof{Union: &pb.Oneof_F_Int32{1}},
+		dst:  &pb.Oneof{Union: &pb.Oneof_F_String{"dst"}, Tormato: &pb.Oneof_Value{2}},
+		want: &pb.Oneof{Union: &pb.Oneof_F_Int32{1}, Tormato: &pb.Oneof_Value{2}},
+	},
 	{
 		src:  &pb.Communique{Union: &pb.Communique_Number{1337}},
 		dst:  &pb.Communique{},
@@ -36,9 +36,12 @@ package proto
  */
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
 )
 
 // errOverflow is returned when an integer is too large to be represented.
@@ -71,6 +74,80 @@ func DecodeVarint(buf []byte) (x uint64, n int) {
 	return 0, 0
 }
 
+// DecodeTag reads a field tag from the slice.
+// It returns the field number, the wire type and the number of bytes
+// consumed, or zero bytes consumed if the tag is truncated or
+// the field number is out of range.
+func DecodeTag(buf []byte) (num int, wire int, n int) {
+	x, n := decodeVarint(buf)
+	if n == 0 || x>>3 == 0 || x>>3 > maxFieldNumber {
+		return 0, 0, 0
+	}
+	return int(x >> 3), int(x & 7), n
+}
+
+// DecodeFieldValue reads the value of a field with the given number and
+// wire type from the slice, where the field's tag has already been read.
+// It returns the raw encoding of the value and the number of bytes
+// consumed, or zero bytes consumed if the value is malformed or truncated.
+// The raw encoding is everything that follows the tag: the varint or the
+// fixed-size bytes of a scalar, the length prefix and the payload of a
+// WireBytes value, and the fields and the end-group tag of a group.
+// A field can thus be relayed without decoding it by writing its tag
+// followed by the raw bytes.
+func DecodeFieldValue(num int, wire int, buf []byte) (raw []byte, n int) {
+	switch wire {
+	case WireVarint:
+		_, n = decodeVarint(buf)
+	case WireFixed64:
+		if len(buf) >= 8 {
+			n = 8
+		}
+	case WireFixed32:
+		if len(buf) >= 4 {
+			n = 4
+		}
+	case WireBytes:
+		l, k := decodeVarint(buf)
+		if k > 0 && l <= uint64(len(buf)-k) {
+			n = k + int(l)
+		}
+	case WireStartGroup:
+		// Nested groups are tracked with a stack of their field numbers
+		// rather than by recursion, so that deeply nested input cannot
+		// overflow the call stack.
+		groups := []int{num}
+		i := 0
+		for len(groups) > 0 {
+			fnum, fwire, k := DecodeTag(buf[i:])
+			if k == 0 {
+				return nil, 0
+			}
+			i += k
+			switch fwire {
+			case WireStartGroup:
+				groups = append(groups, fnum)
+			case WireEndGroup:
+				if fnum != groups[len(groups)-1] {
+					return nil, 0
+				}
+				groups = groups[:len(groups)-1]
+			default:
+				_, m := DecodeFieldValue(fnum, fwire, buf[i:])
+				if m == 0 {
+					return nil, 0
+				}
+				i += m
+			}
+		}
+		n = i
+	}
+	if n == 0 {
+		return nil, 0
+	}
+	return buf[:n], n
+}
+
 func (p *Buffer) decodeVarintSlow() (x uint64, err error) {
 	i := p.index
 	l := len(p.buf)
@@ -236,6 +313,14 @@ func (p *Buffer) DecodeFixed32() (x uint64, err error) {
 	return
 }
 
+// PeekTag returns the field number and wire type of the next field in the
+// Buffer without advancing past it. ok is false if the Buffer is empty,
+// the tag is truncated or the field number is out of range.
+func (p *Buffer) PeekTag() (num int, wire int, ok bool) {
+	num, wire, n := DecodeTag(p.buf[p.index:])
+	return num, wire, n > 0
+}
+
 // DecodeZigzag64 reads a zigzag-encoded 64-bit integer
 // from the Buffer.
 // This is the format used for the sint64 protocol buffer type.
@@ -342,6 +427,57 @@ func Unmarshal(buf []byte, pb Message) error {
 	return NewBuffer(buf).Unmarshal(pb)
 }
 
+// ResetKeepCapacity resets pb like its Reset method, except that the repeated
+// fields and the unknown fields of pb keep their backing arrays, so that
+// a following UnmarshalMerge can decode into them without allocating.
+// This suits loops that decode many similar messages into one message:
+//
+//	for _, b := range inputs {
+//		proto.ResetKeepCapacity(m)
+//		if err := proto.UnmarshalMerge(b, m); err != nil {
+//			...
+//		}
+//	}
+//
+// Maps are emptied and all other fields, including nested messages, are
+// cleared. Slices previously read from pb share storage with the slices
+// decoded next, so they must not be retained across the loop.
+func ResetKeepCapacity(pb Message) {
+	if IsNil(pb) {
+		return
+	}
+	v := reflect.ValueOf(pb)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		pb.Reset()
+		return
+	}
+	s := v.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		f := s.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch {
+		case f.Kind() == reflect.Slice && (f.Type().Elem().Kind() != reflect.Uint8 || s.Type().Field(i).Name == "XXX_unrecognized"):
+			switch f.Type().Elem().Kind() {
+			case reflect.Ptr, reflect.String, reflect.Slice:
+				// Drop the references held by the elements.
+				zero := reflect.Zero(f.Type().Elem())
+				for j := 0; j < f.Len(); j++ {
+					f.Index(j).Set(zero)
+				}
+			}
+			f.SetLen(0)
+		case f.Kind() == reflect.Map:
+			for _, k := range f.MapKeys() {
+				f.SetMapIndex(k, reflect.Value{})
+			}
+		default:
+			f.Set(reflect.Zero(f.Type()))
+		}
+	}
+}
+
 // UnmarshalMerge parses the protocol buffer representation in buf and
 // writes the decoded result to pb.  If the struct underlying pb does not match
 // the data in buf, the results can be unpredictable.
@@ -370,7 +506,13 @@ func (p *Buffer) DecodeMessage(pb Message) error {
 	if err != nil {
 		return err
 	}
-	return NewBuffer(enc).Unmarshal(pb)
+	if err := p.checkSize(len(enc)); err != nil {
+		return err
+	}
+	b := NewBuffer(enc)
+	b.interned = p.interned
+	b.discardUnknown = p.discardUnknown
+	return b.Unmarshal(pb)
 }
 
 // DecodeGroup reads a tag-delimited group from the Buffer.
@@ -382,7 +524,15 @@ func (p *Buffer) DecodeGroup(pb Message) error {
 	if x < 0 {
 		return io.ErrUnexpectedEOF
 	}
-	err := Unmarshal(b[:x], pb)
+	if err := p.checkSize(x); err != nil {
+		p.index += y
+		return err
+	}
+	q := NewBuffer(b[:x])
+	q.interned = p.interned
+	q.discardUnknown = p.discardUnknown
+	pb.Reset()
+	err := q.Unmarshal(pb)
 	p.index += y
 	return err
 }
@@ -394,6 +544,26 @@ func (p *Buffer) DecodeGroup(pb Message) error {
 //
 // Unlike proto.Unmarshal, this does not reset pb before starting to unmarshal.
 func (p *Buffer) Unmarshal(pb Message) error {
+	if err := p.checkSize(len(p.buf) - p.index); err != nil {
+		return err
+	}
+	return p.unmarshal(pb)
+}
+
+func (p *Buffer) unmarshal(pb Message) error {
+	if p.interned != nil || p.discardUnknown {
+		if _, ok := pb.(Unmarshaler); !ok {
+			// Interning and discarding unknown fields need the table-driven
+			// unmarshaler, which the generated XXX_Unmarshal method would
+			// call without options.
+			u := getUnmarshalInfo(reflect.TypeOf(pb).Elem())
+			o := &unmarshalOptions{interned: p.interned, discardUnknown: p.discardUnknown}
+			err := u.unmarshal(toPointer(&pb), p.buf[p.index:], o)
+			p.index = len(p.buf)
+			return err
+		}
+	}
+
 	// If the object can unmarshal itself, let it.
 	if u, ok := pb.(newUnmarshaler); ok {
 		err := u.XXX_Unmarshal(p.buf[p.index:])
@@ -409,6 +579,11 @@ func (p *Buffer) Unmarshal(pb Message) error {
 		// See https://github.com/golang/protobuf/issues/424
 		err := u.Unmarshal(p.buf[p.index:])
 		p.index = len(p.buf)
+		if p.discardUnknown {
+			// A custom Unmarshaler cannot skip unknown fields as it
+			// decodes, so drop them afterwards.
+			DiscardUnknown(pb)
+		}
 		return err
 	}
 
@@ -425,3 +600,158 @@ func (p *Buffer) Unmarshal(pb Message) error {
 	p.index = len(p.buf)
 	return err
 }
+
+// maxFieldNumber is the largest valid field number.
+const maxFieldNumber = 1<<29 - 1
+
+// ValidateWire checks that b is a syntactically valid wire format encoding
+// of a message: every field has a valid wire type and a field number in
+// [1, 2^29), length-delimited and fixed-size values fit within b, and group
+// start and end tags are balanced. It does not check the fields against
+// any message type.
+// Truncated input is reported as io.ErrUnexpectedEOF.
+func ValidateWire(b []byte) error {
+	var groups []uint64 // field numbers of the open groups
+	for i := 0; i < len(b); {
+		start := i
+		x, n := decodeVarint(b[i:])
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		i += n
+		num, wire := x>>3, int(x&7)
+		if num == 0 || num > maxFieldNumber {
+			return fmt.Errorf("proto: invalid field number %d at offset %d", num, start)
+		}
+		switch wire {
+		case WireVarint:
+			_, n := decodeVarint(b[i:])
+			if n == 0 {
+				return io.ErrUnexpectedEOF
+			}
+			i += n
+		case WireFixed64:
+			if len(b)-i < 8 {
+				return io.ErrUnexpectedEOF
+			}
+			i += 8
+		case WireFixed32:
+			if len(b)-i < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			i += 4
+		case WireBytes:
+			l, n := decodeVarint(b[i:])
+			if n == 0 {
+				return io.ErrUnexpectedEOF
+			}
+			i += n
+			if l > uint64(len(b)-i) {
+				if int64(l) < 0 {
+					return fmt.Errorf("proto: negative length %d for field %d at offset %d", int64(l), num, start)
+				}
+				return io.ErrUnexpectedEOF
+			}
+			i += int(l)
+		case WireStartGroup:
+			groups = append(groups, num)
+		case WireEndGroup:
+			if len(groups) == 0 || groups[len(groups)-1] != num {
+				return fmt.Errorf("proto: unmatched end of group %d at offset %d", num, start)
+			}
+			groups = groups[:len(groups)-1]
+		default:
+			return fmt.Errorf("proto: invalid wire type %d for field %d at offset %d", wire, num, start)
+		}
+	}
+	if len(groups) > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// FormatWire returns a human-readable description of the wire format
+// encoding in b, similar to the output of "protoc --decode_raw".
+// Each field is written on its own line as its number, wire type and value.
+// Varints are written in decimal, fixed-size values in hexadecimal and
+// length-delimited values as hexadecimal bytes. The contents of groups are
+// indented. Malformed input is described by a comment, after which
+// formatting stops.
+func FormatWire(b []byte) string {
+	var out bytes.Buffer
+	var groups []uint64 // field numbers of the open groups
+	line := func(format string, args ...interface{}) {
+		for range groups {
+			out.WriteString("  ")
+		}
+		fmt.Fprintf(&out, format, args...)
+		out.WriteByte('\n')
+	}
+	for i := 0; i < len(b); {
+		start := i
+		x, n := decodeVarint(b[i:])
+		if n == 0 {
+			line("/* unexpected EOF in tag at offset %d */", start)
+			break
+		}
+		i += n
+		num, wire := x>>3, int(x&7)
+		if num == 0 || num > maxFieldNumber {
+			line("/* invalid field number %d at offset %d */", num, start)
+			break
+		}
+		ok := true
+		switch wire {
+		case WireVarint:
+			v, n := decodeVarint(b[i:])
+			if ok = n > 0; ok {
+				line("%d varint: %d", num, v)
+				i += n
+			}
+		case WireFixed32:
+			if ok = len(b)-i >= 4; ok {
+				line("%d fixed32: %#08x", num, binary.LittleEndian.Uint32(b[i:]))
+				i += 4
+			}
+		case WireFixed64:
+			if ok = len(b)-i >= 8; ok {
+				line("%d fixed64: %#016x", num, binary.LittleEndian.Uint64(b[i:]))
+				i += 8
+			}
+		case WireBytes:
+			l, n := decodeVarint(b[i:])
+			if ok = n > 0 && l <= uint64(len(b)-i-n); ok {
+				i += n
+				if l == 0 {
+					line("%d bytes: [0]", num)
+				} else {
+					line("%d bytes: [%d] %x", num, l, b[i:i+int(l)])
+				}
+				i += int(l)
+			}
+		case WireStartGroup:
+			line("%d group {", num)
+			groups = append(groups, num)
+		case WireEndGroup:
+			if len(groups) == 0 || groups[len(groups)-1] != num {
+				line("/* unmatched end of group %d at offset %d */", num, start)
+				return out.String()
+			}
+			groups = groups[:len(groups)-1]
+			line("}")
+		default:
+			line("/* invalid wire type %d for field %d at offset %d */", wire, num, start)
+			return out.String()
+		}
+		if !ok {
+			line("/* unexpected EOF in field %d at offset %d */", num, start)
+			break
+		}
+	}
+	for len(groups) > 0 {
+		line("/* missing end of group %d */", groups[len(groups)-1])
+		groups = groups[:len(groups)-1]
+		line("}")
+	}
+	return out.String()
+}
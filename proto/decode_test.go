@@ -34,11 +34,17 @@
 package proto_test
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"math/rand"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	tpb "github.com/golang/protobuf/proto/proto3_proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
 )
 
 var msgBlackhole = new(tpb.Message)
@@ -253,3 +259,483 @@ func BenchmarkDecodeEmpty(b *testing.B) {
 		}
 	}
 }
+
+func TestValidateWire(t *testing.T) {
+	valid, err := proto.Marshal(&pb.MyMessage{
+		Count:     proto.Int32(42),
+		Name:      proto.String("Dave"),
+		Inner:     &pb.InnerMessage{Host: proto.String("h")},
+		Somegroup: &pb.MyMessage_SomeGroup{GroupField: proto.Int32(8)},
+		Bigfloat:  proto.Float64(1.5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		desc string
+		in   []byte
+		want string // error substring, or "" for success
+	}{
+		{"empty", nil, ""},
+		{"message", valid, ""},
+		{"fixed32", []byte{0x0d, 1, 2, 3, 4}, ""},
+		{"nested groups", []byte{0x0b, 0x13, 0x08, 0x01, 0x14, 0x0c}, ""},
+		{"max field number", []byte{0xf8, 0xff, 0xff, 0xff, 0x0f, 0x00}, ""},
+		{"truncated", valid[:len(valid)-1], io.ErrUnexpectedEOF.Error()},
+		{"truncated tag", []byte{0x80}, io.ErrUnexpectedEOF.Error()},
+		{"truncated varint", []byte{0x08, 0x80}, io.ErrUnexpectedEOF.Error()},
+		{"truncated fixed64", []byte{0x09, 1, 2, 3}, io.ErrUnexpectedEOF.Error()},
+		{"truncated bytes", []byte{0x12, 0x05, 'a'}, io.ErrUnexpectedEOF.Error()},
+		{"wire type 6", []byte{0x0e}, "invalid wire type 6 for field 1"},
+		{"wire type 7", []byte{0x0f}, "invalid wire type 7 for field 1"},
+		{"field number 0", []byte{0x00, 0x01}, "invalid field number 0 at offset 0"},
+		{"field number too large", []byte{0x80, 0x80, 0x80, 0x80, 0x10, 0x00}, "invalid field number 536870912"},
+		{"negative length", []byte{0x12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, "negative length -1 for field 2"},
+		{"unterminated group", []byte{0x0b, 0x08, 0x01}, io.ErrUnexpectedEOF.Error()},
+		{"unmatched end group", []byte{0x0c}, "unmatched end of group 1 at offset 0"},
+		{"mismatched end group", []byte{0x0b, 0x14}, "unmatched end of group 2 at offset 1"},
+	}
+	for _, tt := range tests {
+		err := proto.ValidateWire(tt.in)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: ValidateWire(%x) = %v, want nil", tt.desc, tt.in, err)
+		case tt.want != "" && err == nil:
+			t.Errorf("%s: ValidateWire(%x) = nil, want error containing %q", tt.desc, tt.in, tt.want)
+		case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+			t.Errorf("%s: ValidateWire(%x) = %v, want error containing %q", tt.desc, tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	marshal := func(m proto.Message) []byte {
+		b, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	withExt := &pb.MyMessage{Count: proto.Int32(1)}
+	if err := proto.SetExtension(withExt, pb.E_Ext_Number, proto.Int32(7)); err != nil {
+		t.Fatal(err)
+	}
+	count := []byte{0x08, 0x01} // count: 1
+
+	// deep is a proto3 Message nested n messages deep in submessage fields.
+	deep := func(n int) []byte {
+		sizes := make([]int, n)
+		for i := 1; i < n; i++ {
+			sizes[i] = 2 + len(proto.EncodeVarint(uint64(sizes[i-1]))) + sizes[i-1]
+		}
+		var b []byte
+		for i := n - 1; i > 0; i-- {
+			b = append(b, 0x8a, 0x01) // submessage
+			b = append(b, proto.EncodeVarint(uint64(sizes[i-1]))...)
+		}
+		return b
+	}
+
+	tests := []struct {
+		desc    string
+		in      []byte
+		m       proto.Message
+		unknown bool
+		want    string // error substring, or "" for success
+	}{
+		{desc: "empty", in: nil, m: new(tpb.Message)},
+		{desc: "message", in: marshal(initGoTest(true)), m: new(pb.GoTest)},
+		{desc: "proto3", in: marshal(&tpb.Message{
+			Name:        "name",
+			Key:         []uint64{1, 2},
+			Nested:      &tpb.Nested{Bunny: "bunny"},
+			Terrain:     map[string]*tpb.Nested{"k": {Cute: true}},
+			Proto2Field: &pb.SubDefaults{N: proto.Int64(1)},
+		}), m: new(tpb.Message)},
+		{desc: "oneof", in: marshal(&pb.Oneof{Union: &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Label: proto.String("l"), Type: proto.String("t")}}}), m: new(pb.Oneof)},
+		{desc: "packed into unpacked", in: []byte{0x0a, 0x02, 0x01, 0x02}, m: new(pb.NonPackedTest)},
+		{desc: "registered extension", in: marshal(withExt), m: new(pb.MyMessage)},
+		{desc: "unknown field", in: append([]byte{0xa0, 0x06, 0x01}, count...), m: new(pb.MyMessage), unknown: true},
+		{desc: "unregistered extension", in: append([]byte{0xb0, 0x09, 0x01}, count...), m: new(pb.MyMessage), unknown: true},
+		{desc: "mismatched wire type", in: append([]byte{0x10, 0x01}, count...), m: new(pb.MyMessage), unknown: true},
+		{desc: "unknown field in submessage", in: append([]byte{0x2a, 0x05, 0x0a, 0x01, 'h', 0x78, 0x01}, count...), m: new(pb.MyMessage), unknown: true},
+		{desc: "truncated", in: marshal(initGoTest(true))[:20], m: new(pb.GoTest), want: io.ErrUnexpectedEOF.Error()},
+		{desc: "truncated packed", in: []byte{0x2a, 0x02, 0x01, 0x80}, m: new(tpb.Message), want: io.ErrUnexpectedEOF.Error()},
+		{desc: "truncated group", in: append([]byte{0x43, 0x48, 0x01}, count...), m: new(pb.MyMessage), want: io.ErrUnexpectedEOF.Error()},
+		{desc: "int32 overflow", in: []byte{0x98, 0x01, 0x80, 0x80, 0x80, 0x80, 0x10}, m: new(tpb.Message), want: "integer overflow"},
+		{desc: "field number 0", in: []byte{0x00, 0x00}, m: new(tpb.Message), want: "illegal tag 0"},
+		{desc: "map wire type", in: []byte{0x54}, m: new(tpb.Message), want: "bad wiretype for map field"},
+		{desc: "missing required", in: nil, m: new(pb.MyMessage), want: `required field "count" not set`},
+		{desc: "missing nested required", in: append([]byte{0x2a, 0x00}, count...), m: new(pb.MyMessage), want: `required field "inner.host" not set`},
+		{desc: "invalid UTF-8", in: []byte{0x0a, 0x01, 0xff}, m: new(tpb.Message), want: "invalid UTF-8"},
+		{desc: "invalid UTF-8 in map", in: []byte{0x52, 0x05, 0x0a, 0x01, 0xff, 0x12, 0x00}, m: new(tpb.Message), want: "invalid UTF-8"},
+		{desc: "nested", in: deep(10001), m: new(tpb.Message)},
+		{desc: "nested too deep", in: deep(10002), m: new(tpb.Message), want: "exceeded maximum nesting depth"},
+	}
+	for _, tt := range tests {
+		unknown, err := proto.Validate(tt.in, tt.m)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: Validate(%x, %T) = %v, want nil", tt.desc, tt.in, tt.m, err)
+		case tt.want != "" && err == nil:
+			t.Errorf("%s: Validate(%x, %T) = nil, want error containing %q", tt.desc, tt.in, tt.m, tt.want)
+		case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+			t.Errorf("%s: Validate(%x, %T) = %v, want error containing %q", tt.desc, tt.in, tt.m, err, tt.want)
+		}
+		if err == nil && unknown != tt.unknown {
+			t.Errorf("%s: Validate(%x, %T) reported unknown fields %v, want %v", tt.desc, tt.in, tt.m, unknown, tt.unknown)
+		}
+		if strings.Contains(tt.desc, "too deep") {
+			continue
+		}
+		if uerr := proto.Unmarshal(tt.in, tt.m); fmt.Sprint(uerr) != fmt.Sprint(err) {
+			t.Errorf("%s: Validate(%x, %T) = %v, but Unmarshal = %v", tt.desc, tt.in, tt.m, err, uerr)
+		}
+	}
+}
+
+// TestValidateFuzz checks that Validate agrees with Unmarshal
+// on random mutations of valid encodings.
+func TestValidateFuzz(t *testing.T) {
+	corpus := []proto.Message{
+		initGoTest(true),
+		&pb.MyMessage{
+			Count:     proto.Int32(42),
+			Name:      proto.String("Dave"),
+			Inner:     &pb.InnerMessage{Host: proto.String("h")},
+			Others:    []*pb.OtherMessage{{Key: proto.Int64(1), Inner: &pb.InnerMessage{Host: proto.String("o")}}},
+			Somegroup: &pb.MyMessage_SomeGroup{GroupField: proto.Int32(8)},
+		},
+		&tpb.Message{
+			Name:        "name",
+			Key:         []uint64{1, 2, 300},
+			ShortKey:    []int32{-1, 5},
+			Nested:      &tpb.Nested{Bunny: "bunny"},
+			Terrain:     map[string]*tpb.Nested{"k": {Cute: true}},
+			RFunny:      []tpb.Message_Humour{tpb.Message_PUNS},
+			Proto2Field: &pb.SubDefaults{N: proto.Int64(1)},
+		},
+		&pb.MessageWithMap{
+			NameMapping: map[int32]string{1: "a"},
+			MsgMapping:  map[int64]*pb.FloatingPoint{2: {F: proto.Float64(1)}},
+		},
+	}
+	const N = 2000
+	seed := time.Now().UnixNano()
+	t.Logf("RNG seed is %d", seed)
+	rng := rand.New(rand.NewSource(seed))
+	for _, m := range corpus {
+		valid, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < N; i++ {
+			b := append([]byte(nil), valid...)
+			for n := rng.Intn(3) + 1; n > 0 && len(b) > 0; n-- {
+				j := rng.Intn(len(b))
+				switch rng.Intn(4) {
+				case 0:
+					b[j] = byte(rng.Intn(256))
+				case 1:
+					b[j] ^= 1 << uint(rng.Intn(8))
+				case 2:
+					b = append(b[:j], b[j+1:]...)
+				case 3:
+					b = b[:j]
+				}
+			}
+			_, verr := proto.Validate(b, m)
+			uerr := proto.Unmarshal(b, proto.Clone(m))
+			if (verr == nil) != (uerr == nil) {
+				t.Fatalf("Validate(%x, %T) = %v, but Unmarshal = %v", b, m, verr, uerr)
+			}
+		}
+	}
+}
+
+func TestDecodeTag(t *testing.T) {
+	wires := []int{proto.WireVarint, proto.WireFixed64, proto.WireBytes, proto.WireStartGroup, proto.WireEndGroup, proto.WireFixed32}
+	for _, num := range []int{1, 15, 16, 2047, 2048, 1<<29 - 1} {
+		for _, wire := range wires {
+			tag := proto.EncodeVarint(uint64(num)<<3 | uint64(wire))
+			in := append(tag, 0x01) // trailing bytes are not consumed
+			gotNum, gotWire, n := proto.DecodeTag(in)
+			if gotNum != num || gotWire != wire || n != len(tag) {
+				t.Errorf("DecodeTag(%x) = %d, %d, %d; want %d, %d, %d", in, gotNum, gotWire, n, num, wire, len(tag))
+			}
+		}
+	}
+
+	for _, in := range [][]byte{
+		nil,
+		{0x80},                         // truncated
+		{0x08 | 0x80, 0x80},            // truncated
+		{0x00},                         // field number 0
+		{0x05},                         // field number 0
+		{0x80, 0x80, 0x80, 0x80, 0x10}, // field number 1<<29
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, // field number too large
+	} {
+		if num, wire, n := proto.DecodeTag(in); n != 0 {
+			t.Errorf("DecodeTag(%x) = %d, %d, %d; want n = 0", in, num, wire, n)
+		}
+	}
+}
+
+func TestDecodeFieldValue(t *testing.T) {
+	tests := []struct {
+		desc string
+		num  int
+		wire int
+		in   []byte
+		want []byte // nil if the value is malformed
+	}{
+		{"varint", 1, proto.WireVarint, []byte{0x96, 0x01, 0xff}, []byte{0x96, 0x01}},
+		{"fixed32", 1, proto.WireFixed32, []byte{1, 2, 3, 4, 5}, []byte{1, 2, 3, 4}},
+		{"fixed64", 1, proto.WireFixed64, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9}, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"bytes", 1, proto.WireBytes, []byte{0x02, 'h', 'i', 'x'}, []byte{0x02, 'h', 'i'}},
+		{"empty bytes", 1, proto.WireBytes, []byte{0x00, 0x08}, []byte{0x00}},
+		{"group", 5, proto.WireStartGroup, []byte{0x08, 0x01, 0x2c, 0x08}, []byte{0x08, 0x01, 0x2c}},
+		{"empty group", 5, proto.WireStartGroup, []byte{0x2c}, []byte{0x2c}},
+		{"nested group", 5, proto.WireStartGroup, []byte{0x0b, 0x12, 0x00, 0x0c, 0x2c, 0x2c}, []byte{0x0b, 0x12, 0x00, 0x0c, 0x2c}},
+		{"truncated varint", 1, proto.WireVarint, []byte{0x96}, nil},
+		{"truncated fixed32", 1, proto.WireFixed32, []byte{1, 2, 3}, nil},
+		{"truncated fixed64", 1, proto.WireFixed64, []byte{1, 2, 3, 4, 5, 6, 7}, nil},
+		{"truncated bytes", 1, proto.WireBytes, []byte{0x03, 'h', 'i'}, nil},
+		{"unterminated group", 5, proto.WireStartGroup, []byte{0x08, 0x01}, nil},
+		{"mismatched end group", 5, proto.WireStartGroup, []byte{0x08, 0x01, 0x34}, nil},
+		{"malformed field in group", 5, proto.WireStartGroup, []byte{0x0a, 0x05, 0x2c}, nil},
+		{"end group", 5, proto.WireEndGroup, []byte{0x2c}, nil},
+		{"invalid wire type", 1, 6, []byte{0x00}, nil},
+	}
+	for _, tt := range tests {
+		raw, n := proto.DecodeFieldValue(tt.num, tt.wire, tt.in)
+		if !bytes.Equal(raw, tt.want) || n != len(tt.want) {
+			t.Errorf("%s: DecodeFieldValue(%d, %d, %x) = %x, %d; want %x, %d", tt.desc, tt.num, tt.wire, tt.in, raw, n, tt.want, len(tt.want))
+		}
+	}
+
+	// Deeply nested groups are read without recursion.
+	const depth = 1000000
+	deep := bytes.Repeat([]byte{0x0b}, depth) // start group 1
+	deep = append(deep, bytes.Repeat([]byte{0x0c}, depth)...)
+	deep = append(deep, 0x2c)
+	if raw, n := proto.DecodeFieldValue(5, proto.WireStartGroup, deep); n != len(deep) || !bytes.Equal(raw, deep) {
+		t.Errorf("DecodeFieldValue of %d nested groups consumed %d bytes, want %d", depth, n, len(deep))
+	}
+	if _, n := proto.DecodeFieldValue(5, proto.WireStartGroup, deep[:len(deep)-1]); n != 0 {
+		t.Errorf("DecodeFieldValue of %d unterminated nested groups consumed %d bytes, want 0", depth, n)
+	}
+
+	// Relaying every field of a message by tag and raw value reproduces it.
+	in, err := proto.Marshal(&pb.MyMessage{
+		Count:     proto.Int32(42),
+		Name:      proto.String("Dave"),
+		Inner:     &pb.InnerMessage{Host: proto.String("h")},
+		Somegroup: &pb.MyMessage_SomeGroup{GroupField: proto.Int32(8)},
+		Bigfloat:  proto.Float64(1.5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []byte
+	for b := in; len(b) > 0; {
+		num, wire, n := proto.DecodeTag(b)
+		if n == 0 {
+			t.Fatalf("DecodeTag(%x) failed", b)
+		}
+		out = append(out, b[:n]...)
+		b = b[n:]
+		raw, n := proto.DecodeFieldValue(num, wire, b)
+		if n == 0 {
+			t.Fatalf("DecodeFieldValue(%d, %d, %x) failed", num, wire, b)
+		}
+		out = append(out, raw...)
+		b = b[n:]
+	}
+	if !bytes.Equal(out, in) {
+		t.Errorf("relayed message = %x, want %x", out, in)
+	}
+}
+
+func TestDecode32BitVarintRange(t *testing.T) {
+	varint := func(num int, x uint64) []byte {
+		return append(proto.EncodeVarint(uint64(num)<<3|proto.WireVarint), proto.EncodeVarint(x)...)
+	}
+	packed := func(num int, x uint64) []byte {
+		b := proto.EncodeVarint(uint64(num)<<3 | proto.WireBytes)
+		v := proto.EncodeVarint(x)
+		return append(append(b, proto.EncodeVarint(uint64(len(v)))...), v...)
+	}
+	const (
+		maxInt32 = 1<<31 - 1
+		minInt32 = -1 << 31
+	)
+	tests := []struct {
+		desc string
+		in   []byte
+		m    proto.Message
+		want proto.Message // nil if an error is expected
+	}{
+		{"int32 max", varint(2, maxInt32), new(pb.Defaults), &pb.Defaults{F_Int32: proto.Int32(maxInt32)}},
+		{"int32 min", varint(2, uint64(minInt32+1<<64)), new(pb.Defaults), &pb.Defaults{F_Int32: proto.Int32(minInt32)}},
+		{"int32 -1", varint(2, 1<<64-1), new(pb.Defaults), &pb.Defaults{F_Int32: proto.Int32(-1)}},
+		{"int32 2^31", varint(2, 1<<31), new(pb.Defaults), nil},
+		{"int32 2^32-1", varint(2, 1<<32-1), new(pb.Defaults), nil},
+		{"int32 below min", varint(2, uint64(minInt32-1+1<<64)), new(pb.Defaults), nil},
+		{"int32 repeated 2^31", varint(3, 1<<31), new(pb.MoreRepeated), nil},
+		{"int32 packed max", packed(4, maxInt32), new(pb.MoreRepeated), &pb.MoreRepeated{IntsPacked: []int32{maxInt32}}},
+		{"int32 packed 2^31", packed(4, 1<<31), new(pb.MoreRepeated), nil},
+		{"int32 non-packed into packed 2^31", varint(4, 1<<31), new(pb.MoreRepeated), nil},
+		{"sint32 max", varint(12, 1<<32-2), new(pb.Defaults), &pb.Defaults{F_Sint32: proto.Int32(maxInt32)}},
+		{"sint32 min", varint(12, 1<<32-1), new(pb.Defaults), &pb.Defaults{F_Sint32: proto.Int32(minInt32)}},
+		{"sint32 2^31", varint(12, 1<<32), new(pb.Defaults), nil},
+		{"sint32 below min", varint(12, 1<<32+1), new(pb.Defaults), nil},
+		{"uint32 2^32-1", varint(6, 1<<32-1), new(pb.Defaults), &pb.Defaults{F_Uint32: proto.Uint32(1<<32 - 1)}},
+		{"uint32 2^32", varint(6, 1<<32), new(pb.Defaults), nil},
+		{"uint32 value 2^32-1", varint(3, 1<<32-1), new(tpb.Message), &tpb.Message{HeightInCm: 1<<32 - 1}},
+		{"uint32 value 2^32", varint(3, 1<<32), new(tpb.Message), nil},
+		{"uint32 repeated 2^32", varint(25, 1<<32), new(pb.GoTest), nil},
+		{"uint32 packed 2^32", packed(55, 1<<32), new(pb.GoTest), nil},
+		{"enum -1", varint(14, 1<<64-1), new(pb.Defaults), &pb.Defaults{F_Enum: pb.Defaults_Color(-1).Enum()}},
+		{"enum 2^31", varint(14, 1<<31), new(pb.Defaults), nil},
+	}
+	for _, tt := range tests {
+		err := proto.Unmarshal(tt.in, tt.m)
+		if tt.want == nil {
+			if _, ok := err.(*proto.RequiredNotSetError); err == nil || ok {
+				t.Errorf("%s: Unmarshal(%x) = %v, %v; want overflow error", tt.desc, tt.in, tt.m, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Unmarshal(%x): %v", tt.desc, tt.in, err)
+			continue
+		}
+		if !proto.Equal(tt.m, tt.want) {
+			t.Errorf("%s: Unmarshal(%x) = %v, want %v", tt.desc, tt.in, tt.m, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalFieldNumberZero(t *testing.T) {
+	for _, in := range [][]byte{
+		{0x00},
+		{0x00, 0x00},
+		{0x00, 0x10, 0x01}, // followed by a valid F_Int32 field
+		{0x01, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0x02, 0x00, 0x10, 0x01},
+		{0x05, 0, 0, 0, 0},
+		{0x80, 0x00}, // overlong encoding of tag 0
+		{0x10, 0x01, 0x00},
+	} {
+		m := new(pb.Defaults)
+		err := proto.Unmarshal(in, m)
+		if err == nil || !strings.Contains(err.Error(), "tag 0") {
+			t.Errorf("Unmarshal(%x) = %v, want error mentioning tag 0", in, err)
+		}
+		if in[0] != 0x10 && m.F_Int32 != nil {
+			t.Errorf("Unmarshal(%x) parsed F_Int32 after tag 0", in)
+		}
+	}
+}
+
+func TestFormatWire(t *testing.T) {
+	e := proto.NewBuffer(nil)
+	e.EncodeVarint(1<<3 | proto.WireVarint)
+	e.EncodeVarint(150)
+	e.EncodeVarint(2<<3 | proto.WireFixed32)
+	e.EncodeFixed32(0xdeadbeef)
+	e.EncodeVarint(3<<3 | proto.WireFixed64)
+	e.EncodeFixed64(1)
+	e.EncodeVarint(4<<3 | proto.WireBytes)
+	e.EncodeRawBytes([]byte("hi"))
+	e.EncodeVarint(5<<3 | proto.WireStartGroup)
+	e.EncodeVarint(6<<3 | proto.WireVarint)
+	e.EncodeVarint(1)
+	e.EncodeVarint(7<<3 | proto.WireStartGroup)
+	e.EncodeVarint(7<<3 | proto.WireEndGroup)
+	e.EncodeVarint(5<<3 | proto.WireEndGroup)
+	e.EncodeVarint(8<<3 | proto.WireBytes)
+	e.EncodeRawBytes(nil)
+	all := e.Bytes()
+
+	msg, err := proto.Marshal(&pb.MyMessage{
+		Count:     proto.Int32(-1),
+		Name:      proto.String("Dave"),
+		Somegroup: &pb.MyMessage_SomeGroup{GroupField: proto.Int32(8)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc string
+		in   []byte
+		want string
+	}{{
+		desc: "empty",
+		in:   nil,
+		want: "",
+	}, {
+		desc: "all wire types",
+		in:   all,
+		want: `1 varint: 150
+2 fixed32: 0xdeadbeef
+3 fixed64: 0x0000000000000001
+4 bytes: [2] 6869
+5 group {
+  6 varint: 1
+  7 group {
+  }
+}
+8 bytes: [0]
+`,
+	}, {
+		desc: "message",
+		in:   msg,
+		want: `1 varint: 18446744073709551615
+2 bytes: [4] 44617665
+8 group {
+  9 varint: 8
+}
+`,
+	}, {
+		desc: "truncated varint",
+		in:   []byte{0x08, 0x96},
+		want: "/* unexpected EOF in field 1 at offset 0 */\n",
+	}, {
+		desc: "truncated bytes",
+		in:   []byte{0x08, 0x01, 0x12, 0x05, 'a'},
+		want: "1 varint: 1\n/* unexpected EOF in field 2 at offset 2 */\n",
+	}, {
+		desc: "truncated tag",
+		in:   []byte{0x80},
+		want: "/* unexpected EOF in tag at offset 0 */\n",
+	}, {
+		desc: "invalid wire type",
+		in:   []byte{0x0e, 0x00},
+		want: "/* invalid wire type 6 for field 1 at offset 0 */\n",
+	}, {
+		desc: "field number 0",
+		in:   []byte{0x00},
+		want: "/* invalid field number 0 at offset 0 */\n",
+	}, {
+		desc: "unmatched end group",
+		in:   []byte{0x0b, 0x14},
+		want: "1 group {\n  /* unmatched end of group 2 at offset 1 */\n",
+	}, {
+		desc: "missing end group",
+		in:   []byte{0x0b, 0x10, 0x01},
+		want: "1 group {\n  2 varint: 1\n  /* missing end of group 1 */\n}\n",
+	}}
+	for _, tt := range tests {
+		if got := proto.FormatWire(tt.in); got != tt.want {
+			t.Errorf("%s: FormatWire(%x):\ngot:\n%s\nwant:\n%s", tt.desc, tt.in, got, tt.want)
+		}
+	}
+
+	// Every prefix of a valid message is formatted without panicking.
+	for i := range all {
+		proto.FormatWire(all[:i])
+	}
+}
@@ -92,13 +92,20 @@ type discardFieldInfo struct {
 
 var (
 	discardInfoMap  = map[reflect.Type]*discardInfo{}
-	discardInfoLock sync.Mutex
+	discardInfoLock sync.RWMutex
 )
 
 func getDiscardInfo(t reflect.Type) *discardInfo {
+	discardInfoLock.RLock()
+	di := discardInfoMap[t]
+	discardInfoLock.RUnlock()
+	if di != nil {
+		return di
+	}
+
 	discardInfoLock.Lock()
 	defer discardInfoLock.Unlock()
-	di := discardInfoMap[t]
+	di = discardInfoMap[t]
 	if di == nil {
 		di = &discardInfo{typ: t}
 		discardInfoMap[t] = di
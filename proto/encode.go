@@ -38,6 +38,7 @@ package proto
 import (
 	"errors"
 	"reflect"
+	"sort"
 )
 
 var (
@@ -193,6 +194,82 @@ func (p *Buffer) EncodeMessage(pb Message) error {
 	return p.Marshal(pb)
 }
 
+// MergeWire returns the concatenation of two wire-format messages.
+// Because of the way the wire format is defined, unmarshaling the result
+// is equivalent to unmarshaling a and then merging b into it:
+// later singular fields replace earlier ones,
+// repeated fields are appended and embedded messages are merged.
+// The returned slice does not alias a or b.
+func MergeWire(a, b []byte) []byte {
+	m := make([]byte, 0, len(a)+len(b))
+	m = append(m, a...)
+	return append(m, b...)
+}
+
+// canonicalUnknown returns pb, or a copy of pb with the unknown fields of
+// every message sorted by field number if any of them are out of order.
+func canonicalUnknown(pb Message) Message {
+	sorted := true
+	w := Walker{Unknown: true, Pre: func(path WalkPath, v reflect.Value) error {
+		if len(path) > 0 && path[len(path)-1].Unknown && sortUnknown(v.Bytes()) != nil {
+			sorted = false
+			return StopWalk
+		}
+		return nil
+	}}
+	w.Walk(pb)
+	if sorted {
+		return pb
+	}
+	pb = Clone(pb)
+	w.Pre = func(path WalkPath, v reflect.Value) error {
+		if len(path) > 0 && path[len(path)-1].Unknown {
+			if s := sortUnknown(v.Bytes()); s != nil {
+				v.SetBytes(s)
+			}
+		}
+		return nil
+	}
+	w.Walk(pb)
+	return pb
+}
+
+// sortUnknown returns a copy of the wire-format message b with its fields
+// stably sorted by field number. It returns nil if the fields are already
+// sorted or if b is malformed.
+func sortUnknown(b []byte) []byte {
+	type field struct {
+		num int
+		raw []byte // the tag and value
+	}
+	var fields []field
+	inOrder := true
+	for i := 0; i < len(b); {
+		num, wire, n := DecodeTag(b[i:])
+		if n == 0 {
+			return nil
+		}
+		_, m := DecodeFieldValue(num, wire, b[i+n:])
+		if m == 0 {
+			return nil
+		}
+		if len(fields) > 0 && num < fields[len(fields)-1].num {
+			inOrder = false
+		}
+		fields = append(fields, field{num, b[i : i+n+m]})
+		i += n + m
+	}
+	if inOrder {
+		return nil
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].num < fields[j].num })
+	s := make([]byte, 0, len(b))
+	for _, f := range fields {
+		s = append(s, f.raw...)
+	}
+	return s
+}
+
 // All protocol buffer fields are nillable, but be careful.
 func isNil(v reflect.Value) bool {
 	switch v.Kind() {
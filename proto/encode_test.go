@@ -39,6 +39,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	tpb "github.com/golang/protobuf/proto/proto3_proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
 	"github.com/golang/protobuf/ptypes"
 )
 
@@ -83,3 +84,99 @@ func BenchmarkEmpty(b *testing.B) {
 		blackhole = raw
 	}
 }
+
+func TestMergeWire(t *testing.T) {
+	tests := []struct {
+		a, b proto.Message
+	}{{
+		a: &pb.MoreRepeated{
+			Bools:        []bool{true},
+			IntsPacked:   []int32{1, 2},
+			Int64SPacked: []int64{-1},
+			Strings:      []string{"a"},
+		},
+		b: &pb.MoreRepeated{
+			Bools:        []bool{false},
+			IntsPacked:   []int32{3},
+			Int64SPacked: []int64{-2, 2},
+			Fixeds:       []uint32{4},
+		},
+	}, {
+		a: &pb.GroupNew{G: &pb.GroupNew_G{X: proto.Int32(1)}},
+		b: &pb.GroupNew{G: &pb.GroupNew_G{Y: proto.Int32(2)}},
+	}, {
+		a: &pb.MyMessage{Count: proto.Int32(1), Pet: []string{"a"}, Inner: &pb.InnerMessage{Host: proto.String("h")}},
+		b: &pb.MyMessage{Count: proto.Int32(2), Pet: []string{"b"}, Inner: &pb.InnerMessage{Host: proto.String("h2"), Port: proto.Int32(80)}},
+	}, {
+		a: &pb.MessageWithMap{
+			NameMapping: map[int32]string{1: "a", 2: "b"},
+			MsgMapping:  map[int64]*pb.FloatingPoint{1: {F: proto.Float64(1)}},
+		},
+		b: &pb.MessageWithMap{
+			NameMapping: map[int32]string{2: "c", 3: "d"},
+			MsgMapping:  map[int64]*pb.FloatingPoint{1: {F: proto.Float64(2), Exact: proto.Bool(true)}},
+			ByteMapping: map[bool][]byte{true: []byte("x")},
+		},
+	}, {
+		a: &tpb.Message{
+			Name:      "a",
+			Key:       []uint64{1},
+			Nested:    &tpb.Nested{Bunny: "bugs"},
+			Terrain:   map[string]*tpb.Nested{"x": {Bunny: "x"}},
+			Children:  []*tpb.Message{{Name: "c1"}},
+			StringMap: map[string]string{"k": "v"},
+		},
+		b: &tpb.Message{
+			HeightInCm: 180,
+			Key:        []uint64{2, 3},
+			Nested:     &tpb.Nested{Cute: true},
+			Terrain:    map[string]*tpb.Nested{"x": {Cute: true}, "y": {Bunny: "y"}},
+			Children:   []*tpb.Message{{Name: "c2"}},
+			StringMap:  map[string]string{"k": "w"},
+		},
+	}, {
+		a: &pb.Oneof{Union: &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Label: proto.String("l"), Type: proto.String("t")}}},
+		b: &pb.Oneof{Union: &pb.Oneof_F_Int32{F_Int32: 7}},
+	}}
+	for _, tt := range tests {
+		a, err := proto.Marshal(tt.a)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %v", tt.a, err)
+		}
+		b, err := proto.Marshal(tt.b)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %v", tt.b, err)
+		}
+
+		want := proto.Clone(tt.a)
+		want.Reset()
+		if err := proto.Unmarshal(a, want); err != nil {
+			t.Fatalf("Unmarshal(a): %v", err)
+		}
+		src := proto.Clone(tt.b)
+		src.Reset()
+		if err := proto.Unmarshal(b, src); err != nil {
+			t.Fatalf("Unmarshal(b): %v", err)
+		}
+		proto.Merge(want, src)
+
+		got := proto.Clone(tt.a)
+		got.Reset()
+		if err := proto.Unmarshal(proto.MergeWire(a, b), got); err != nil {
+			t.Errorf("Unmarshal(MergeWire(%v, %v)): %v", tt.a, tt.b, err)
+			continue
+		}
+		if !proto.Equal(got, want) {
+			t.Errorf("Unmarshal(MergeWire(%v, %v)) = %v, want %v", tt.a, tt.b, got, want)
+		}
+	}
+
+	// The result does not share storage with its inputs.
+	a := make([]byte, 1, 10)
+	a[0] = 0x08
+	m := proto.MergeWire(a, []byte{0x01})
+	m[0] = 0xff
+	if a[0] != 0x08 || a[:2][1] != 0 {
+		t.Errorf("MergeWire modified its input: %x", a[:2])
+	}
+}
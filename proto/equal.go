@@ -36,6 +36,7 @@ package proto
 import (
 	"bytes"
 	"log"
+	"math"
 	"reflect"
 	"strings"
 )
@@ -49,8 +50,8 @@ Equality is defined in this way:
     corresponding fields are equal, unknown field sets
     are equal, and extensions sets are equal.
   - Two set scalar fields are equal iff their values are equal.
-    If the fields are of a floating-point type, remember that
-    NaN != x for all x, including NaN. If the message is defined
+    If the fields are of a floating-point type, a NaN is equal only
+    to a NaN with the same bit pattern, and +0 is equal to -0. If the message is defined
     in a proto3 .proto file, fields are not "set"; specifically,
     zero length proto3 "bytes" fields are equal (nil == {}).
   - Two repeated fields are equal iff their lengths are the same,
@@ -92,6 +93,45 @@ func Equal(a, b Message) bool {
 	return equalStruct(v1, v2)
 }
 
+// EqualOptions configures the comparison of messages.
+type EqualOptions struct {
+	// Whether to first compare the deterministic wire encodings of
+	// messages of the same type, and report them equal without
+	// inspecting their fields if the encodings are identical.
+	// Messages with different encodings are compared as by Equal,
+	// since values such as +0 and -0 are equal but encode differently.
+	//
+	// This is faster for large messages that are usually equal, but
+	// slower for messages that usually differ. Messages that cannot
+	// be marshaled, for example because a required field is not set,
+	// are always compared field by field.
+	UseWireComparison bool
+}
+
+// Equal reports whether a and b are equal, as defined by the package-level
+// Equal function.
+func (o *EqualOptions) Equal(a, b Message) bool {
+	if o.UseWireComparison && a != nil && b != nil && reflect.TypeOf(a) == reflect.TypeOf(b) {
+		if b1, ok := deterministicBytes(a); ok {
+			if b2, ok := deterministicBytes(b); ok && bytes.Equal(b1, b2) {
+				return true
+			}
+		}
+	}
+	return Equal(a, b)
+}
+
+// deterministicBytes returns the deterministic wire encoding of m, and
+// whether m could be marshaled.
+func deterministicBytes(m Message) ([]byte, bool) {
+	var b Buffer
+	b.SetDeterministic(true)
+	if err := b.Marshal(m); err != nil {
+		return nil, false
+	}
+	return b.Bytes(), true
+}
+
 // v1 and v2 are known to have the same type.
 func equalStruct(v1, v2 reflect.Value) bool {
 	sprop := GetProperties(v1.Type())
@@ -140,6 +180,28 @@ func equalStruct(v1, v2 reflect.Value) bool {
 	return bytes.Equal(u1, u2)
 }
 
+// equalFloat reports whether two float32 or float64 values are equal.
+// NaNs are compared by their bit patterns, which are not preserved by
+// converting a float32 to float64.
+func equalFloat(v1, v2 reflect.Value) bool {
+	if v1.Kind() == reflect.Float32 {
+		f1, ok1 := v1.Interface().(float32)
+		f2, ok2 := v2.Interface().(float32)
+		if !ok1 || !ok2 {
+			f1, f2 = float32(v1.Float()), float32(v2.Float())
+		}
+		if math.IsNaN(float64(f1)) && math.IsNaN(float64(f2)) {
+			return math.Float32bits(f1) == math.Float32bits(f2)
+		}
+		return f1 == f2
+	}
+	f1, f2 := v1.Float(), v2.Float()
+	if math.IsNaN(f1) && math.IsNaN(f2) {
+		return math.Float64bits(f1) == math.Float64bits(f2)
+	}
+	return f1 == f2
+}
+
 // v1 and v2 are known to have the same type.
 // prop may be nil.
 func equalAny(v1, v2 reflect.Value, prop *Properties) bool {
@@ -152,7 +214,7 @@ func equalAny(v1, v2 reflect.Value, prop *Properties) bool {
 	case reflect.Bool:
 		return v1.Bool() == v2.Bool()
 	case reflect.Float32, reflect.Float64:
-		return v1.Float() == v2.Float()
+		return equalFloat(v1, v2)
 	case reflect.Int32, reflect.Int64:
 		return v1.Int() == v2.Int()
 	case reflect.Interface:
@@ -32,6 +32,7 @@
 package proto_test
 
 import (
+	"math"
 	"testing"
 
 	. "github.com/golang/protobuf/proto"
@@ -233,8 +234,29 @@ var EqualTests = []struct {
 		&pb.Communique{Union: &pb.Communique_Name{"Bobby Tables"}},
 		false,
 	},
+
+	{"same NaN", &pb.Defaults{F_Double: Float64(nan64a)}, &pb.Defaults{F_Double: Float64(nan64a)}, true},
+	{"different NaNs", &pb.Defaults{F_Double: Float64(nan64a)}, &pb.Defaults{F_Double: Float64(nan64b)}, false},
+	{"NaN and number", &pb.Defaults{F_Double: Float64(nan64a)}, &pb.Defaults{F_Double: Float64(1)}, false},
+	{"same float32 NaN", &pb.Defaults{F_Float: Float32(nan32a)}, &pb.Defaults{F_Float: Float32(nan32a)}, true},
+	{"different float32 NaNs", &pb.Defaults{F_Float: Float32(nan32a)}, &pb.Defaults{F_Float: Float32(nan32b)}, false},
+	{"signaling float32 NaNs", &pb.Defaults{F_Float: Float32(snan32a)}, &pb.Defaults{F_Float: Float32(snan32b)}, false},
+	{"positive and negative zero", &pb.Defaults{F_Double: Float64(0)}, &pb.Defaults{F_Double: Float64(math.Copysign(0, -1))}, true},
+	{"repeated, same NaN", &pb.GoTest{F_FloatRepeated: []float32{1, nan32a}}, &pb.GoTest{F_FloatRepeated: []float32{1, nan32a}}, true},
+	{"repeated, different NaNs", &pb.GoTest{F_DoubleRepeated: []float64{nan64a}}, &pb.GoTest{F_DoubleRepeated: []float64{nan64b}}, false},
+	{"proto3, same NaN", &proto3pb.Message{Score: nan32a}, &proto3pb.Message{Score: nan32a}, true},
 }
 
+// NaNs with different bit patterns.
+var (
+	nan64a  = math.Float64frombits(0x7ff8000000000001)
+	nan64b  = math.Float64frombits(0x7ff8000000000002)
+	nan32a  = math.Float32frombits(0x7fc00001)
+	nan32b  = math.Float32frombits(0x7fc00002)
+	snan32a = math.Float32frombits(0x7f800001)
+	snan32b = math.Float32frombits(0x7f800002)
+)
+
 func TestEqual(t *testing.T) {
 	for _, tc := range EqualTests {
 		if res := Equal(tc.a, tc.b); res != tc.exp {
@@ -242,3 +264,67 @@ func TestEqual(t *testing.T) {
 		}
 	}
 }
+
+func TestEqualOptionsWireComparison(t *testing.T) {
+	opts := &EqualOptions{UseWireComparison: true}
+	for _, tc := range EqualTests {
+		if res := opts.Equal(tc.a, tc.b); res != tc.exp {
+			t.Errorf("%v: EqualOptions.Equal(%v, %v) = %v, want %v", tc.desc, tc.a, tc.b, res, tc.exp)
+		}
+	}
+
+	// Semantically equal messages with different encodings.
+	negZero := math.Copysign(0, -1)
+	a := &pb.GoTest{F_DoubleRepeated: []float64{1, 0}, F_FloatOptional: Float32(0)}
+	b := &pb.GoTest{F_DoubleRepeated: []float64{1, negZero}, F_FloatOptional: Float32(float32(negZero))}
+	if !opts.Equal(a, b) {
+		t.Errorf("EqualOptions.Equal(%v, %v) = false, want true", a, b)
+	}
+
+	// Maps are encoded in a deterministic order.
+	m1 := &proto3pb.Message{StringMap: map[string]string{}}
+	m2 := &proto3pb.Message{StringMap: map[string]string{}}
+	for i := 0; i < 100; i++ {
+		m1.StringMap[string(rune('a'+i%26))+string(rune('0'+i/26))] = "v"
+	}
+	for k, v := range m1.StringMap {
+		m2.StringMap[k] = v
+	}
+	if !opts.Equal(m1, m2) {
+		t.Errorf("EqualOptions.Equal of equal maps = false, want true")
+	}
+	m2.StringMap["a0"] = "w"
+	if opts.Equal(m1, m2) {
+		t.Errorf("EqualOptions.Equal of different maps = true, want false")
+	}
+}
+
+func largeEqualMessage() *proto3pb.Message {
+	m := &proto3pb.Message{Name: "root", StringMap: map[string]string{}}
+	for i := 0; i < 1000; i++ {
+		m.Children = append(m.Children, &proto3pb.Message{
+			Name:   "child",
+			Key:    []uint64{1, 2, 3, uint64(i)},
+			Nested: &proto3pb.Nested{Bunny: "bunny", Cute: i%2 == 0},
+		})
+		m.StringMap[string(rune('a'+i%26))+string(rune('0'+i/26))] = "value"
+	}
+	return m
+}
+
+func BenchmarkEqualLarge(b *testing.B) {
+	m1, m2 := largeEqualMessage(), largeEqualMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Equal(m1, m2)
+	}
+}
+
+func BenchmarkEqualLargeWireComparison(b *testing.B) {
+	m1, m2 := largeEqualMessage(), largeEqualMessage()
+	opts := &EqualOptions{UseWireComparison: true}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		opts.Equal(m1, m2)
+	}
+}
@@ -40,6 +40,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strconv"
 	"sync"
 )
@@ -430,7 +431,7 @@ func decodeExtension(b []byte, extension *ExtensionDesc) (interface{}, error) {
 		b = b[n:]
 		wire := int(x) & 7
 
-		b, err = unmarshal(b, valToPointer(value.Addr()), wire)
+		b, err = unmarshal(b, valToPointer(value.Addr()), wire, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -462,7 +463,8 @@ func GetExtensions(pb Message, es []*ExtensionDesc) (extensions []interface{}, e
 	return
 }
 
-// ExtensionDescs returns a new slice containing pb's extension descriptors, in undefined order.
+// ExtensionDescs returns a new slice containing pb's extension descriptors,
+// in increasing order of field number.
 // For non-registered extensions, ExtensionDescs returns an incomplete descriptor containing
 // just the Field field, which defines the extension's field number.
 func ExtensionDescs(pb Message) ([]*ExtensionDesc, error) {
@@ -490,6 +492,9 @@ func ExtensionDescs(pb Message) ([]*ExtensionDesc, error) {
 
 		extensions = append(extensions, desc)
 	}
+	sort.Slice(extensions, func(i, j int) bool {
+		return extensions[i].Field < extensions[j].Field
+	})
 	return extensions, nil
 }
 
@@ -35,6 +35,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"sort"
 	"strings"
@@ -208,6 +209,51 @@ func TestExtensionDescsWithUnregisteredExtensions(t *testing.T) {
 	}
 }
 
+func TestExtensionDescsOrder(t *testing.T) {
+	msg := &pb.MyMessage{Count: proto.Int32(0)}
+	// Set the extensions out of order, including one that is unregistered.
+	exts := []struct {
+		desc *proto.ExtensionDesc
+		val  interface{}
+	}{
+		{pb.E_Greeting, []string{"hello"}},
+		{pb.E_Ext_Number, proto.Int32(5)},
+		{repeatedFixed32Ext, []uint32{1, 2}},
+		{pb.E_Ext_More, &pb.Ext{}},
+		{pb.E_Ext_Text, proto.String("text")},
+	}
+	for _, e := range exts {
+		if err := proto.SetExtension(msg, e.desc, e.val); err != nil {
+			t.Fatalf("SetExtension(%d): %v", e.desc.Field, err)
+		}
+	}
+	want := []int32{103, 104, 105, 106, repeatedFixed32Ext.Field}
+
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	decoded := new(pb.MyMessage)
+	if err := proto.Unmarshal(b, decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	for _, m := range []*pb.MyMessage{msg, decoded} {
+		for i := 0; i < 20; i++ {
+			descs, err := proto.ExtensionDescs(m)
+			if err != nil {
+				t.Fatalf("ExtensionDescs: %v", err)
+			}
+			var got []int32
+			for _, d := range descs {
+				got = append(got, d.Field)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("ExtensionDescs returned fields %v, want %v", got, want)
+			}
+		}
+	}
+}
+
 type ExtensionDescSlice []*proto.ExtensionDesc
 
 func (s ExtensionDescSlice) Len() int           { return len(s) }
@@ -389,6 +435,69 @@ func TestGetExtensionDefaults(t *testing.T) {
 	}
 }
 
+func TestGetExtensionDefaultEncodings(t *testing.T) {
+	// The default values in these struct tags are written the way
+	// protoc records them in the descriptor.
+	tests := []struct {
+		typ  interface{}
+		tag  string
+		want interface{}
+	}{
+		{(*float64)(nil), "fixed64,301,opt,name=d,def=inf", math.Inf(1)},
+		{(*float64)(nil), "fixed64,302,opt,name=d,def=-inf", math.Inf(-1)},
+		{(*float32)(nil), "fixed32,303,opt,name=f,def=inf", float32(math.Inf(1))},
+		{(*float32)(nil), "fixed32,304,opt,name=f,def=-inf", float32(math.Inf(-1))},
+		{(*float64)(nil), "fixed64,305,opt,name=d,def=1e+300", 1e300},
+		{(*int64)(nil), "varint,306,opt,name=i,def=-9223372036854775808", int64(math.MinInt64)},
+		{(*uint64)(nil), "varint,307,opt,name=u,def=18446744073709551615", uint64(math.MaxUint64)},
+		{(*string)(nil), "bytes,308,opt,name=s,def=a,b\\c", "a,b\\c"},
+		{([]byte)(nil), "bytes,309,opt,name=b,def=\\000\\001\\377", []byte{0, 1, 0xff}},
+		{([]byte)(nil), "bytes,310,opt,name=b,def=\\x41\\n\\\\\\'\\\"", []byte("A\n\\'\"")},
+		{([]byte)(nil), "bytes,311,opt,name=b,def=", []byte{}},
+		{(*pb.DefaultsMessage_DefaultsEnum)(nil), "varint,312,opt,name=e,enum=test_proto.DefaultsMessage_DefaultsEnum,def=2", pb.DefaultsMessage_TWO},
+		{(*pb.DefaultsMessage_DefaultsEnum)(nil), "varint,313,opt,name=e,enum=test_proto.DefaultsMessage_DefaultsEnum,def=TWO", pb.DefaultsMessage_TWO},
+	}
+	for _, tt := range tests {
+		var p proto.Properties
+		p.Parse(tt.tag)
+		ext := &proto.ExtensionDesc{
+			ExtendedType:  (*pb.DefaultsMessage)(nil),
+			ExtensionType: tt.typ,
+			Field:         int32(p.Tag),
+			Name:          "test_proto.default_encoding",
+			Tag:           tt.tag,
+		}
+		v, err := proto.GetExtension(&pb.DefaultsMessage{}, ext)
+		if err != nil {
+			t.Errorf("GetExtension(%q): %v", tt.tag, err)
+			continue
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr {
+			rv = rv.Elem()
+		}
+		if got := rv.Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetExtension(%q) = %#v, want %#v", tt.tag, got, tt.want)
+		}
+	}
+
+	// NaN does not compare equal to itself.
+	ext := &proto.ExtensionDesc{
+		ExtendedType:  (*pb.DefaultsMessage)(nil),
+		ExtensionType: (*float64)(nil),
+		Field:         314,
+		Name:          "test_proto.default_nan",
+		Tag:           "fixed64,314,opt,name=d,def=nan",
+	}
+	v, err := proto.GetExtension(&pb.DefaultsMessage{}, ext)
+	if err != nil {
+		t.Fatalf("GetExtension(nan): %v", err)
+	}
+	if !math.IsNaN(*v.(*float64)) {
+		t.Errorf("GetExtension(nan) = %v, want NaN", *v.(*float64))
+	}
+}
+
 func TestNilMessage(t *testing.T) {
 	name := "nil interface"
 	if got, err := proto.GetExtension(nil, pb.E_Ext_More); err == nil {
@@ -690,3 +799,118 @@ func TestMarshalRace(t *testing.T) {
 		}
 	}
 }
+
+func TestExtensionLazyDecoding(t *testing.T) {
+	// An extension whose payload is malformed: its Ext.data field
+	// claims 5 bytes but has none.
+	malformed := []byte{0xba, 0x06, 0x02, 0x0a, 0x05}
+	// Two records of the repeated greeting extension.
+	greetings := []byte{0xd2, 0x06, 0x02, 'h', 'i', 0xd2, 0x06, 0x03, 'y', 'o', 'u'}
+
+	m := new(pb.MyMessage)
+	// Extensions are written before the count field, as Marshal does.
+	in := append(append(malformed, greetings...), 0x08, 0x01) // count: 1
+	// Extensions are not decoded until accessed, so Unmarshal succeeds.
+	if err := proto.Unmarshal(in, m); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !proto.HasExtension(m, pb.E_Ext_More) {
+		t.Errorf("HasExtension(more) = false, want true")
+	}
+	// An unaccessed extension is marshaled from its retained bytes.
+	var buf proto.Buffer
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(m); err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), in) {
+		t.Errorf("Marshal = %x, want %x", buf.Bytes(), in)
+	}
+	if _, err := proto.GetExtension(m, pb.E_Ext_More); err == nil {
+		t.Errorf("GetExtension(more) of malformed payload succeeded, want error")
+	}
+
+	v, err := proto.GetExtension(m, pb.E_Greeting)
+	if err != nil {
+		t.Fatalf("GetExtension(greeting): %v", err)
+	}
+	if got, want := v.([]string), []string{"hi", "you"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetExtension(greeting) = %q, want %q", got, want)
+	}
+
+	// A value mutated after a lazy get is what gets marshaled.
+	m2 := new(pb.MyMessage)
+	if err := proto.Unmarshal([]byte{0xba, 0x06, 0x03, 0x0a, 0x01, 'a', 0x08, 0x01}, m2); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	v, err = proto.GetExtension(m2, pb.E_Ext_More)
+	if err != nil {
+		t.Fatalf("GetExtension(more): %v", err)
+	}
+	v.(*pb.Ext).Data = proto.String("b")
+	b, err := proto.Marshal(m2)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if want := []byte{0xba, 0x06, 0x03, 0x0a, 0x01, 'b', 0x08, 0x01}; !bytes.Equal(b, want) {
+		t.Errorf("Marshal after mutation = %x, want %x", b, want)
+	}
+}
+
+// repeatedFixed32Ext is a packed repeated fixed32 extension of MyMessage.
+// Its field number must differ from the extensions declared in other tests,
+// since the marshal info of an extension is cached by field number.
+var repeatedFixed32Ext = &proto.ExtensionDesc{
+	ExtendedType:  (*pb.MyMessage)(nil),
+	ExtensionType: ([]uint32)(nil),
+	Field:         123456800,
+	Name:          "a.e",
+	Tag:           "fixed32,123456800,rep,packed",
+}
+
+func newRepeatedFixed32ExtMessage(b testing.TB, n int) *pb.MyMessage {
+	vals := make([]uint32, n)
+	for i := range vals {
+		vals[i] = uint32(i)
+	}
+	m := &pb.MyMessage{Count: proto.Int32(1)}
+	if err := proto.SetExtension(m, repeatedFixed32Ext, vals); err != nil {
+		b.Fatal(err)
+	}
+	return m
+}
+
+func BenchmarkMarshalRepeatedFixed32Extension(b *testing.B) {
+	for _, n := range []int{10, 1000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			m := newRepeatedFixed32ExtMessage(b, n)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := proto.Marshal(m); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalRepeatedFixed32Extension(b *testing.B) {
+	for _, n := range []int{10, 1000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			data, err := proto.Marshal(newRepeatedFixed32ExtMessage(b, n))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				m := new(pb.MyMessage)
+				if err := proto.Unmarshal(data, m); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := proto.GetExtension(m, repeatedFixed32Ext); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto
+
+// Helpers for building repeated and map message fields without naming
+// their Go types.
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AppendMessage appends a new, empty message to the repeated message field
+// of pb with the given original (proto) name and returns the new element.
+// pb must be a non-nil pointer to a generated message struct.
+func AppendMessage(pb Message, name string) (Message, error) {
+	f, err := messageField(pb, name)
+	if err != nil {
+		return nil, err
+	}
+	if f.Kind() != reflect.Slice || !isMessagePtr(f.Type().Elem()) {
+		return nil, fmt.Errorf("proto: field %q of %T is not a repeated message field", name, pb)
+	}
+	e := reflect.New(f.Type().Elem().Elem())
+	f.Set(reflect.Append(f, e))
+	return e.Interface().(Message), nil
+}
+
+// PutMapMessage stores a new, empty message under key in the map field of pb
+// with the given original (proto) name, replacing any existing entry,
+// and returns the new value. The key must have the map's key type, except
+// that integer keys may be given as any Go integer type whose value fits.
+// pb must be a non-nil pointer to a generated message struct.
+func PutMapMessage(pb Message, name string, key interface{}) (Message, error) {
+	f, err := messageField(pb, name)
+	if err != nil {
+		return nil, err
+	}
+	if f.Kind() != reflect.Map || !isMessagePtr(f.Type().Elem()) {
+		return nil, fmt.Errorf("proto: field %q of %T is not a map field with message values", name, pb)
+	}
+	kt := f.Type().Key()
+	k, ok := convertMapKey(reflect.ValueOf(key), kt)
+	if !ok {
+		return nil, fmt.Errorf("proto: key %v of type %T does not fit %v map key of field %q of %T", key, key, kt, name, pb)
+	}
+	if f.IsNil() {
+		f.Set(reflect.MakeMap(f.Type()))
+	}
+	v := reflect.New(f.Type().Elem().Elem())
+	f.SetMapIndex(k, v)
+	return v.Interface().(Message), nil
+}
+
+// convertMapKey converts k to the map key type kt. Integer keys may be
+// given as any integer type whose value fits in kt.
+func convertMapKey(k reflect.Value, kt reflect.Type) (reflect.Value, bool) {
+	if !k.IsValid() {
+		return k, false
+	}
+	if k.Type() == kt {
+		return k, true
+	}
+	r := reflect.New(kt).Elem()
+	switch kt.Kind() {
+	case reflect.Int32, reflect.Int64:
+		var x int64
+		switch k.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			x = k.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if k.Uint() > 1<<63-1 {
+				return k, false
+			}
+			x = int64(k.Uint())
+		default:
+			return k, false
+		}
+		if r.OverflowInt(x) {
+			return k, false
+		}
+		r.SetInt(x)
+	case reflect.Uint32, reflect.Uint64:
+		var x uint64
+		switch k.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if k.Int() < 0 {
+				return k, false
+			}
+			x = uint64(k.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			x = k.Uint()
+		default:
+			return k, false
+		}
+		if r.OverflowUint(x) {
+			return k, false
+		}
+		r.SetUint(x)
+	case reflect.Bool, reflect.String:
+		if k.Kind() != kt.Kind() {
+			return k, false
+		}
+		r.Set(k.Convert(kt))
+	default:
+		return k, false
+	}
+	return r, true
+}
+
+// messageField returns the addressable struct field of pb with the given
+// original name.
+func messageField(pb Message, name string) (reflect.Value, error) {
+	v := reflect.ValueOf(pb)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("proto: %T is not a non-nil pointer to a message struct", pb)
+	}
+	i, ok := GetProperties(v.Elem().Type()).decoderOrigNames[name]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("proto: %T has no field %q", pb, name)
+	}
+	return v.Elem().Field(i), nil
+}
+
+// isMessagePtr reports whether t is a pointer to a message struct.
+func isMessagePtr(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && t.Implements(reflect.TypeOf((*Message)(nil)).Elem())
+}
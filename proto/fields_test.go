@@ -0,0 +1,114 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto_test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
+)
+
+func TestAppendMessage(t *testing.T) {
+	m := &pb.MyMessage{Count: proto.Int32(1)}
+	for _, host := range []string{"a", "b"} {
+		e, err := proto.AppendMessage(m, "rep_inner")
+		if err != nil {
+			t.Fatalf("AppendMessage: %v", err)
+		}
+		e.(*pb.InnerMessage).Host = proto.String(host)
+	}
+	o, err := proto.AppendMessage(m, "others")
+	if err != nil {
+		t.Fatalf("AppendMessage: %v", err)
+	}
+	o.(*pb.OtherMessage).Key = proto.Int64(7)
+
+	want := &pb.MyMessage{
+		Count:    proto.Int32(1),
+		RepInner: []*pb.InnerMessage{{Host: proto.String("a")}, {Host: proto.String("b")}},
+		Others:   []*pb.OtherMessage{{Key: proto.Int64(7)}},
+	}
+	if !proto.Equal(m, want) {
+		t.Errorf("built message = %v, want %v", m, want)
+	}
+
+	for _, name := range []string{"nope", "count", "pet", "inner"} {
+		if _, err := proto.AppendMessage(m, name); err == nil {
+			t.Errorf("AppendMessage(%q) succeeded, want error", name)
+		}
+	}
+	if _, err := proto.AppendMessage((*pb.MyMessage)(nil), "others"); err == nil {
+		t.Errorf("AppendMessage on nil message succeeded, want error")
+	}
+}
+
+func TestPutMapMessage(t *testing.T) {
+	m := &pb.MessageWithMap{}
+	for _, k := range []interface{}{int64(1), 2, uint8(3)} {
+		v, err := proto.PutMapMessage(m, "msg_mapping", k)
+		if err != nil {
+			t.Fatalf("PutMapMessage(%v): %v", k, err)
+		}
+		v.(*pb.FloatingPoint).F = proto.Float64(1.5)
+	}
+	// Putting an existing key replaces its value.
+	v, err := proto.PutMapMessage(m, "msg_mapping", 2)
+	if err != nil {
+		t.Fatalf("PutMapMessage(2): %v", err)
+	}
+	v.(*pb.FloatingPoint).F = proto.Float64(2.5)
+
+	want := &pb.MessageWithMap{MsgMapping: map[int64]*pb.FloatingPoint{
+		1: {F: proto.Float64(1.5)},
+		2: {F: proto.Float64(2.5)},
+		3: {F: proto.Float64(1.5)},
+	}}
+	if !proto.Equal(m, want) {
+		t.Errorf("built message = %v, want %v", m, want)
+	}
+
+	for _, tt := range []struct {
+		name string
+		key  interface{}
+	}{
+		{"msg_mapping", "1"},
+		{"msg_mapping", nil},
+		{"msg_mapping", uint64(1 << 63)},
+		{"name_mapping", int32(1)},
+		{"nope", 1},
+	} {
+		if _, err := proto.PutMapMessage(m, tt.name, tt.key); err == nil {
+			t.Errorf("PutMapMessage(%q, %v) succeeded, want error", tt.name, tt.key)
+		}
+	}
+}
@@ -350,7 +350,11 @@ type Buffer struct {
 	buf   []byte // encode/decode byte stream
 	index int    // read point
 
-	deterministic bool
+	deterministic    bool
+	canonicalUnknown bool              // sort unknown fields by number when marshaling
+	maxBytes         int               // decode size limit; 0 means no limit
+	discardUnknown   bool              // drop unknown fields when unmarshaling
+	interned         map[string]string // decoded strings, if interning is enabled
 }
 
 // NewBuffer allocates a new Buffer and initializes its internal data to
@@ -399,6 +403,65 @@ func (p *Buffer) SetDeterministic(deterministic bool) {
 	p.deterministic = deterministic
 }
 
+// SetCanonicalUnknown sets whether Marshal sorts the unknown fields of each
+// message by field number before writing them. Fields with the same number
+// keep their relative order, so the meaning of the message is unchanged.
+// Together with SetDeterministic this makes messages that differ only in
+// the order their unknown fields were decoded or merged serialize to the
+// same bytes. The message passed to Marshal is not modified; if any of its
+// unknown fields are out of order, a sorted copy is marshaled instead.
+// Unknown fields inside extensions are not sorted.
+// By default, unknown fields are written in the order they were stored.
+func (p *Buffer) SetCanonicalUnknown(canonical bool) {
+	p.canonicalUnknown = canonical
+}
+
+// SetMaxBytes limits the size of the input that Unmarshal, DecodeMessage and
+// DecodeGroup will decode to n bytes. Input larger than the limit is rejected
+// with an error before any decoding is done, so the limit caps the work
+// spent on untrusted input. For DecodeMessage and DecodeGroup the limit
+// applies to each message read from the Buffer.
+// A limit of zero or less means no limit, which is the default.
+func (p *Buffer) SetMaxBytes(n int) {
+	p.maxBytes = n
+}
+
+// SetInternStrings controls whether string values decoded by Unmarshal,
+// DecodeMessage and DecodeGroup are interned: when a string equal to one
+// the Buffer has decoded before is read, the earlier string is reused
+// instead of allocating a new one. This reduces allocations when decoding
+// many messages that repeat a small vocabulary of strings. At most 1000
+// distinct strings are remembered; the table is discarded when interning is
+// turned off. Interning does not apply to extensions, which are decoded
+// lazily, or to messages that implement Unmarshaler.
+func (p *Buffer) SetInternStrings(intern bool) {
+	if !intern {
+		p.interned = nil
+	} else if p.interned == nil {
+		p.interned = make(map[string]string)
+	}
+}
+
+// SetDiscardUnknown sets whether Unmarshal, DecodeMessage and DecodeGroup
+// skip the unknown fields of the decoded message and its sub-messages
+// instead of keeping them. Unknown fields of every wire type are skipped,
+// including groups, which are skipped through their matching end group tag.
+// As with DiscardUnknown, fields in extension ranges are kept. Unknown
+// fields that a message held before it was merged into are also kept.
+// By default, unknown fields are kept so that they survive a round trip
+// through Marshal.
+func (p *Buffer) SetDiscardUnknown(discard bool) {
+	p.discardUnknown = discard
+}
+
+// checkSize returns an error if n exceeds the Buffer's size limit.
+func (p *Buffer) checkSize(n int) error {
+	if p.maxBytes > 0 && n > p.maxBytes {
+		return fmt.Errorf("proto: message of %d bytes exceeds limit of %d bytes", n, p.maxBytes)
+	}
+	return nil
+}
+
 /*
  * Helper routines for simplifying the creation of optional fields of basic type.
  */
@@ -460,6 +523,44 @@ func String(v string) *string {
 	return &v
 }
 
+// Must is a helper that wraps a call to a function returning (Message, error)
+// and panics with a *MustError if the error is non-nil. It is intended for
+// use in variable initializations such as
+//
+//	var defaultConfig = proto.Must(loadConfig()).(*pb.Config)
+func Must(m Message, err error) Message {
+	if err != nil {
+		name := MessageName(m)
+		if name == "" {
+			name = fmt.Sprintf("%T", m)
+		}
+		panic(&MustError{Name: name, Err: err})
+	}
+	return m
+}
+
+// MustError is the error type Must panics with. Err is the error it was
+// given, so a caller that recovers the panic can inspect it.
+type MustError struct {
+	Name string // the message's full name, or its Go type if it has none
+	Err  error  // the error passed to Must
+}
+
+func (e *MustError) Error() string {
+	return fmt.Sprintf("proto: Must(%s): %v", e.Name, e.Err)
+}
+
+// IsNil reports whether m is nil, either as a nil interface or as a nil
+// pointer of a message type such as (*pb.Config)(nil). Comparing m with nil
+// only detects the former.
+func IsNil(m Message) bool {
+	if m == nil {
+		return true
+	}
+	v := reflect.ValueOf(m)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
 // EnumName is a helper function to simplify printing protocol buffer enums
 // by name.  Given an enum map and a value, it returns a useful string.
 func EnumName(m map[int32]string, v int32) string {
@@ -854,6 +955,11 @@ func fieldDefault(ft reflect.Type, prop *Properties) (sf *scalarField, nestedMes
 	case reflect.Int32:
 		x, err := strconv.ParseInt(prop.Default, 10, 32)
 		if err != nil {
+			// Enum defaults may also be given by value name.
+			if v, ok := EnumValueMap(prop.Enum)[prop.Default]; ok && prop.Enum != "" {
+				sf.value = v
+				break
+			}
 			return nil, false, fmt.Errorf("proto: bad default int32 %q: %v", prop.Default, err)
 		}
 		sf.value = int32(x)
@@ -867,7 +973,12 @@ func fieldDefault(ft reflect.Type, prop *Properties) (sf *scalarField, nestedMes
 		sf.value = prop.Default
 	case reflect.Uint8:
 		// []byte (not *uint8)
-		sf.value = []byte(prop.Default)
+		// The default is C-escaped by protoc, e.g. "\000\x01".
+		x, err := unquoteC(prop.Default, 0)
+		if err != nil {
+			return nil, false, fmt.Errorf("proto: bad default bytes %q: %v", prop.Default, err)
+		}
+		sf.value = []byte(x)
 	case reflect.Uint32:
 		x, err := strconv.ParseUint(prop.Default, 10, 32)
 		if err != nil {
@@ -912,6 +1023,16 @@ func mapKeys(vs []reflect.Value) sort.Interface {
 	return s
 }
 
+// isMapKeyKind reports whether k is the kind of a Go type that can hold
+// a legal map key: an integral, bool or string field type.
+func isMapKeyKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int32, reflect.Int64, reflect.Uint32, reflect.Uint64, reflect.Bool, reflect.String:
+		return true
+	}
+	return false
+}
+
 type mapKeySorter struct {
 	vs   []reflect.Value
 	less func(a, b reflect.Value) bool
@@ -3,6 +3,7 @@ package proto_test
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -32,6 +33,50 @@ func TestMap(t *testing.T) {
 	}
 }
 
+// mistypedMapValue declares a float value with a varint wire type.
+type mistypedMapValue struct {
+	M map[int32]float32 `protobuf:"bytes,1,rep,name=m" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+}
+
+func (*mistypedMapValue) Reset()         {}
+func (*mistypedMapValue) String() string { return "" }
+func (*mistypedMapValue) ProtoMessage()  {}
+
+// mistypedMapKey declares a string key with a fixed32 wire type.
+type mistypedMapKey struct {
+	M map[string]int32 `protobuf:"bytes,1,rep,name=m" protobuf_key:"fixed32,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+}
+
+func (*mistypedMapKey) Reset()         {}
+func (*mistypedMapKey) String() string { return "" }
+func (*mistypedMapKey) ProtoMessage()  {}
+
+// mistypedMapMessage holds a mistyped map in a nested message.
+type mistypedMapMessage struct {
+	V *mistypedMapValue `protobuf:"bytes,1,opt,name=v"`
+}
+
+func (*mistypedMapMessage) Reset()         {}
+func (*mistypedMapMessage) String() string { return "" }
+func (*mistypedMapMessage) ProtoMessage()  {}
+
+func TestMapMistypedTag(t *testing.T) {
+	tests := []struct {
+		m    proto.Message
+		want string
+	}{
+		{&mistypedMapValue{M: map[int32]float32{1: 1.5}}, "field proto_test.mistypedMapValue.M: map value type float32 does not match wire type varint"},
+		{&mistypedMapKey{M: map[string]int32{"a": 1}}, "field proto_test.mistypedMapKey.M: map key type string does not match wire type fixed32"},
+		{&mistypedMapMessage{V: &mistypedMapValue{M: map[int32]float32{1: 1.5}}}, "field proto_test.mistypedMapValue.M: map value type float32"},
+	}
+	for _, tt := range tests {
+		b, err := proto.Marshal(tt.m)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Marshal(%T) = %x, %v; want error containing %q", tt.m, b, err, tt.want)
+		}
+	}
+}
+
 func marshaled() []byte {
 	m := &ppb.IntMaps{}
 	for i := 0; i < 1000; i++ {
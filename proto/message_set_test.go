@@ -86,3 +86,51 @@ func TestUnmarshalMessageSetWithDuplicate(t *testing.T) {
 		t.Errorf("output mismatch:\ngot  %x\nwant %x", got, want)
 	}
 }
+
+func TestMessageSetKnownAndUnknownItems(t *testing.T) {
+	/*
+		Message{
+			Tag{1, StartGroup},
+			Message{
+				Tag{2, Varint}, Uvarint(201),
+				Tag{3, Bytes}, Bytes(""),
+			},
+			Tag{1, EndGroup},
+			Tag{1, StartGroup},
+			Message{
+				Tag{3, Bytes}, Bytes("hoo"),
+				Tag{2, Varint}, Uvarint(12345),
+			},
+			Tag{1, EndGroup},
+		}
+
+		The second item has its fields out of order, which parsers
+		must accept.
+	*/
+	var in []byte
+	fmt.Sscanf("0b10c9011a000c0b1a03686f6f10b9600c", "%x", &in)
+
+	var m MyMessageSet
+	if err := proto.Unmarshal(in, &m); err != nil {
+		t.Fatalf("unexpected Unmarshal error: %v", err)
+	}
+	if !proto.HasExtension(&m, E_X201) {
+		t.Errorf("extension x201 not set after Unmarshal")
+	}
+	if _, err := proto.GetExtension(&m, E_X201); err != nil {
+		t.Errorf("GetExtension(x201): %v", err)
+	}
+
+	// The unknown item is retained, and items are written in the
+	// canonical order with the type_id first.
+	var want []byte
+	fmt.Sscanf("0b10c9011a000c0b10b9601a03686f6f0c", "%x", &want)
+	var b proto.Buffer
+	b.SetDeterministic(true)
+	if err := b.Marshal(&m); err != nil {
+		t.Fatalf("unexpected Marshal error: %v", err)
+	}
+	if got := b.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("output mismatch:\ngot  %x\nwant %x", got, want)
+	}
+}
@@ -34,6 +34,11 @@
 // This file contains an implementation of proto field accesses using package reflect.
 // It is slower than the code in pointer_unsafe.go but it avoids package unsafe and can
 // be used on App Engine.
+//
+// Both implementations produce identical encodings. The main performance
+// differences are that slices of enums are copied to and from []int32
+// rather than reinterpreted in place, and that every field access goes
+// through a reflect.Value.
 
 package proto
 
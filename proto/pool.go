@@ -0,0 +1,86 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto
+
+import (
+	"reflect"
+	"sync"
+)
+
+// A Pool is a set of messages of one type that may be reused to reduce
+// allocations in code that creates and discards many messages, such as
+// servers that decode a request message per call. It is safe for
+// concurrent use, and like sync.Pool it may drop its messages at any time.
+//
+// A Pool must not be copied after first use.
+type Pool struct {
+	// New returns a new, empty message. It is called by Get when
+	// the pool is empty and must be set before Get is called.
+	New func() Message
+
+	pool sync.Pool
+	once sync.Once
+	typ  reflect.Type // type of the messages returned by New
+}
+
+// NewPool returns a Pool of the messages returned by newMessage.
+func NewPool(newMessage func() Message) *Pool {
+	return &Pool{New: newMessage}
+}
+
+// Get returns an empty message from the pool, allocating one with New if
+// the pool is empty. The caller may modify it and return it with Put.
+func (p *Pool) Get() Message {
+	if m, ok := p.pool.Get().(Message); ok {
+		return m
+	}
+	return p.New()
+}
+
+// Put resets m and adds it to the pool. The caller must not use m after
+// calling Put. Nil messages, including typed nil pointers, and messages
+// of a different type than those returned by New are dropped.
+func (p *Pool) Put(m Message) {
+	if IsNil(m) || reflect.TypeOf(m) != p.messageType() {
+		return
+	}
+	m.Reset()
+	p.pool.Put(m)
+}
+
+// messageType returns the type of the messages returned by New.
+func (p *Pool) messageType() reflect.Type {
+	p.once.Do(func() {
+		p.typ = reflect.TypeOf(p.New())
+	})
+	return p.typ
+}
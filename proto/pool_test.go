@@ -0,0 +1,99 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto_test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	proto3pb "github.com/golang/protobuf/proto/proto3_proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
+)
+
+func TestPool(t *testing.T) {
+	p := proto.NewPool(func() proto.Message { return new(pb.MyMessage) })
+	m := p.Get().(*pb.MyMessage)
+	if !proto.Equal(m, new(pb.MyMessage)) {
+		t.Errorf("Get() = %v, want an empty message", m)
+	}
+
+	// Messages are reset when they are put back.
+	m.Count = proto.Int32(42)
+	m.Pet = []string{"cat"}
+	m.Inner = &pb.InnerMessage{Host: proto.String("h")}
+	p.Put(m)
+	if !proto.Equal(m, new(pb.MyMessage)) {
+		t.Errorf("after Put, message = %v, want an empty message", m)
+	}
+	for i := 0; i < 10; i++ {
+		if got := p.Get(); !proto.Equal(got, new(pb.MyMessage)) {
+			t.Errorf("Get() = %v, want an empty message", got)
+		}
+	}
+	p.Put(nil)
+	p.Put((*pb.MyMessage)(nil))
+
+	// Messages of another type are dropped rather than handed out by Get.
+	other := &pb.GoTest{Kind: pb.GoTest_FUNCTION.Enum()}
+	p.Put(other)
+	if other.Kind == nil {
+		t.Errorf("Put reset a message of another type")
+	}
+	for i := 0; i < 10; i++ {
+		got := p.Get()
+		if _, ok := got.(*pb.MyMessage); !ok {
+			t.Errorf("Get() returned %T, want *test_proto.MyMessage", got)
+		}
+	}
+}
+
+func TestPoolAllocs(t *testing.T) {
+	newAllocs := testing.AllocsPerRun(100, func() {
+		m := new(proto3pb.Message)
+		m.Name = "name"
+		m.HeightInCm = 180
+		poolSink = m
+	})
+	p := proto.NewPool(func() proto.Message { return new(proto3pb.Message) })
+	poolAllocs := testing.AllocsPerRun(100, func() {
+		m := p.Get().(*proto3pb.Message)
+		m.Name = "name"
+		m.HeightInCm = 180
+		p.Put(m)
+	})
+	if poolAllocs >= newAllocs {
+		t.Errorf("allocations with a Pool = %v, want fewer than %v without", poolAllocs, newAllocs)
+	}
+}
+
+var poolSink proto.Message
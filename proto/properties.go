@@ -529,6 +529,21 @@ func MessageType(name string) reflect.Type {
 	return protoMapTypes[name]
 }
 
+// New returns a new, empty message of the type registered under the fully
+// qualified name, such as "google.protobuf.Duration". It returns an error if
+// no type is registered under the name or if the name refers to a map entry
+// or an enum rather than a message.
+func New(name string) (Message, error) {
+	t, ok := protoTypedNils[name]
+	if !ok {
+		if protoMapTypes[name] != nil || enumValueMaps[name] != nil {
+			return nil, fmt.Errorf("proto: %q is not a message type", name)
+		}
+		return nil, fmt.Errorf("proto: message type %q is not registered", name)
+	}
+	return reflect.New(reflect.TypeOf(t).Elem()).Interface().(Message), nil
+}
+
 // A registry of all linked proto files.
 var (
 	protoFiles = make(map[string][]byte) // file name => fileDescriptor
@@ -542,3 +557,21 @@ func RegisterFile(filename string, fileDescriptor []byte) {
 
 // FileDescriptor returns the compressed FileDescriptorProto for a .proto file.
 func FileDescriptor(filename string) []byte { return protoFiles[filename] }
+
+// RawDescriptor returns the compressed FileDescriptorProto of the file that
+// declares m's type and the path of indexes to the message's DescriptorProto
+// within it, as returned by the Descriptor method of generated messages.
+// It returns nil values if m has no such method.
+//
+// The result is gzip'd; package descriptor provides FileDescriptorProtoOf
+// to decompress and parse it.
+func RawDescriptor(m Message) (gzBytes []byte, path []int) {
+	type descriptor interface {
+		Descriptor() ([]byte, []int)
+	}
+	d, ok := m.(descriptor)
+	if !ok {
+		return nil, nil
+	}
+	return d.Descriptor()
+}
@@ -62,3 +62,25 @@ func TestVarintSize(t *testing.T) {
 		}
 	}
 }
+
+func TestVarintSizeBoundaries(t *testing.T) {
+	// Each additional 7 bits of value needs another byte.
+	for n := 1; n <= 10; n++ {
+		lo := uint64(0)
+		if n > 1 {
+			lo = 1 << uint(7*(n-1))
+		}
+		hi := uint64(math.MaxUint64)
+		if n < 10 {
+			hi = 1<<uint(7*n) - 1
+		}
+		for _, x := range []uint64{lo, hi} {
+			if got := SizeVarint(x); got != n {
+				t.Errorf("SizeVarint(%d) = %d, want %d", x, got, n)
+			}
+			if got := len(EncodeVarint(x)); got != n {
+				t.Errorf("len(EncodeVarint(%d)) = %d, want %d", x, got, n)
+			}
+		}
+	}
+}
@@ -189,3 +189,35 @@ func TestSize(t *testing.T) {
 		}
 	}
 }
+
+func TestMarshalCachesSizes(t *testing.T) {
+	m := &pb.MyMessage{
+		Count: Int32(1),
+		Inner: &pb.InnerMessage{Host: String("host")},
+	}
+	b, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := int(m.XXX_sizecache); got != len(b) {
+		t.Errorf("XXX_sizecache = %d after Marshal, want %d", got, len(b))
+	}
+	if got, want := int(m.Inner.XXX_sizecache), Size(m.Inner); got != want {
+		t.Errorf("Inner.XXX_sizecache = %d after Marshal, want %d", got, want)
+	}
+
+	// Marshal recomputes sizes, so changes made after an earlier Marshal
+	// are encoded correctly.
+	m.Inner.Host = String("a much longer host name")
+	b, err = Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := int(m.XXX_sizecache); got != len(b) || got != Size(m) {
+		t.Errorf("XXX_sizecache = %d after changing and marshaling again, want %d", got, len(b))
+	}
+	got := new(pb.MyMessage)
+	if err := Unmarshal(b, got); err != nil || !Equal(got, m) {
+		t.Errorf("Unmarshal(Marshal(%v)) = %v, %v", m, got, err)
+	}
+}
@@ -0,0 +1,112 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto
+
+import "io"
+
+// encoderFlushSize is the buffer size at which an Encoder writes to
+// its underlying writer.
+const encoderFlushSize = 4096
+
+// An Encoder writes fields in the protocol buffer wire format to an
+// io.Writer. Writes are buffered; call Flush when done.
+//
+// The Append and group methods do not return errors. The first error
+// from the underlying writer is kept and returned by Flush, and all
+// later writes are discarded.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+// NewEncoder returns an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// AppendVarint writes field num with the varint value v.
+func (e *Encoder) AppendVarint(num int, v uint64) {
+	e.buf = appendVarint(e.buf, uint64(num)<<3|WireVarint)
+	e.buf = appendVarint(e.buf, v)
+	e.maybeFlush()
+}
+
+// AppendFixed32 writes field num with the 32-bit fixed-size value v.
+func (e *Encoder) AppendFixed32(num int, v uint32) {
+	e.buf = appendVarint(e.buf, uint64(num)<<3|WireFixed32)
+	e.buf = appendFixed32(e.buf, v)
+	e.maybeFlush()
+}
+
+// AppendFixed64 writes field num with the 64-bit fixed-size value v.
+func (e *Encoder) AppendFixed64(num int, v uint64) {
+	e.buf = appendVarint(e.buf, uint64(num)<<3|WireFixed64)
+	e.buf = appendFixed64(e.buf, v)
+	e.maybeFlush()
+}
+
+// AppendBytes writes field num with the length-delimited value b.
+func (e *Encoder) AppendBytes(num int, b []byte) {
+	e.buf = appendVarint(e.buf, uint64(num)<<3|WireBytes)
+	e.buf = appendVarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+	e.maybeFlush()
+}
+
+// BeginGroup writes the start of group field num.
+func (e *Encoder) BeginGroup(num int) {
+	e.buf = appendVarint(e.buf, uint64(num)<<3|WireStartGroup)
+	e.maybeFlush()
+}
+
+// EndGroup writes the end of group field num.
+func (e *Encoder) EndGroup(num int) {
+	e.buf = appendVarint(e.buf, uint64(num)<<3|WireEndGroup)
+	e.maybeFlush()
+}
+
+// Flush writes any buffered data to the underlying writer and returns
+// the first error encountered by the Encoder, if any.
+func (e *Encoder) Flush() error {
+	if e.err == nil && len(e.buf) > 0 {
+		_, e.err = e.w.Write(e.buf)
+	}
+	e.buf = e.buf[:0]
+	return e.err
+}
+
+func (e *Encoder) maybeFlush() {
+	if len(e.buf) >= encoderFlushSize {
+		e.Flush()
+	}
+}
@@ -0,0 +1,158 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
+)
+
+func TestEncoder(t *testing.T) {
+	var got bytes.Buffer
+	e := proto.NewEncoder(&got)
+	e.AppendVarint(1, 150)
+	e.AppendFixed32(2, 0xdeadbeef)
+	e.AppendFixed64(3, 0x0123456789abcdef)
+	e.AppendBytes(4, []byte("hello"))
+	e.BeginGroup(5)
+	e.AppendVarint(6, 1)
+	e.EndGroup(5)
+	e.AppendBytes(536870911, nil)
+	if got.Len() != 0 {
+		t.Errorf("Encoder wrote %d bytes before Flush", got.Len())
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	want := proto.NewBuffer(nil)
+	want.EncodeVarint(1<<3 | proto.WireVarint)
+	want.EncodeVarint(150)
+	want.EncodeVarint(2<<3 | proto.WireFixed32)
+	want.EncodeFixed32(0xdeadbeef)
+	want.EncodeVarint(3<<3 | proto.WireFixed64)
+	want.EncodeFixed64(0x0123456789abcdef)
+	want.EncodeVarint(4<<3 | proto.WireBytes)
+	want.EncodeRawBytes([]byte("hello"))
+	want.EncodeVarint(5<<3 | proto.WireStartGroup)
+	want.EncodeVarint(6<<3 | proto.WireVarint)
+	want.EncodeVarint(1)
+	want.EncodeVarint(5<<3 | proto.WireEndGroup)
+	want.EncodeVarint(536870911<<3 | proto.WireBytes)
+	want.EncodeRawBytes(nil)
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("Encoder output:\n got %x\nwant %x", got.Bytes(), want.Bytes())
+	}
+}
+
+func TestEncoderMessage(t *testing.T) {
+	// Fields written by hand decode as the equivalent message.
+	var b bytes.Buffer
+	e := proto.NewEncoder(&b)
+	e.AppendVarint(1, 42)
+	e.AppendBytes(2, []byte("Dave"))
+	e.BeginGroup(8)
+	e.AppendVarint(9, 8)
+	e.EndGroup(8)
+	e.AppendFixed64(11, 0x3ff8000000000000) // 1.5
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	want := &pb.MyMessage{
+		Count:     proto.Int32(42),
+		Name:      proto.String("Dave"),
+		Somegroup: &pb.MyMessage_SomeGroup{GroupField: proto.Int32(8)},
+		Bigfloat:  proto.Float64(1.5),
+	}
+	got := new(pb.MyMessage)
+	if err := proto.Unmarshal(b.Bytes(), got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+	err    error
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestEncoderBuffering(t *testing.T) {
+	w := new(countingWriter)
+	e := proto.NewEncoder(w)
+	data := make([]byte, 1000)
+	for i := 0; i < 10; i++ {
+		e.AppendBytes(1, data)
+	}
+	if w.writes == 0 {
+		t.Errorf("Encoder did not write after buffering %d bytes", 10*len(data))
+	}
+	if w.writes > 3 {
+		t.Errorf("Encoder wrote %d times, want at most 3", w.writes)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got, want := w.Len(), 10*(len(data)+3); got != want {
+		t.Errorf("Encoder wrote %d bytes, want %d", got, want)
+	}
+
+	// Errors from the writer are sticky.
+	errWrite := errors.New("write failed")
+	w = &countingWriter{err: errWrite}
+	e = proto.NewEncoder(w)
+	e.AppendVarint(1, 1)
+	if err := e.Flush(); err != errWrite {
+		t.Errorf("Flush() = %v, want %v", err, errWrite)
+	}
+	e.AppendVarint(1, 1)
+	if err := e.Flush(); err != errWrite {
+		t.Errorf("second Flush() = %v, want %v", err, errWrite)
+	}
+	if w.writes != 1 {
+		t.Errorf("Encoder wrote %d times after an error, want 1", w.writes)
+	}
+}
@@ -63,6 +63,7 @@ type marshalInfo struct {
 	initialized  int32                      // 0 -- only typ is set, 1 -- fully initialized
 	messageset   bool                       // uses message set wire format
 	hasmarshaler bool                       // has custom marshaler
+	err          error                      // error found while computing the info, e.g. a mismatched field type
 	sync.RWMutex                            // protect extElems map, also for initialization
 	extElems     map[int32]*marshalElemInfo // info of extension elements
 }
@@ -92,15 +93,23 @@ type marshalElemInfo struct {
 
 var (
 	marshalInfoMap  = map[reflect.Type]*marshalInfo{}
-	marshalInfoLock sync.Mutex
+	marshalInfoLock sync.RWMutex
 )
 
 // getMarshalInfo returns the information to marshal a given type of message.
 // The info it returns may not necessarily initialized.
 // t is the type of the message (NOT the pointer to it).
 func getMarshalInfo(t reflect.Type) *marshalInfo {
-	marshalInfoLock.Lock()
+	// Most lookups are for types we have seen before.
+	marshalInfoLock.RLock()
 	u, ok := marshalInfoMap[t]
+	marshalInfoLock.RUnlock()
+	if ok {
+		return u
+	}
+
+	marshalInfoLock.Lock()
+	u, ok = marshalInfoMap[t]
 	if !ok {
 		u = &marshalInfo{typ: t}
 		marshalInfoMap[t] = u
@@ -165,6 +174,10 @@ func (u *marshalInfo) size(ptr pointer) int {
 	if atomic.LoadInt32(&u.initialized) == 0 {
 		u.computeMarshalInfo()
 	}
+	if u.err != nil {
+		// The message cannot be marshaled; marshal reports the error.
+		return 0
+	}
 
 	// If the message can marshal itself, let it do it, for compatibility.
 	// NOTE: This is not efficient.
@@ -222,6 +235,9 @@ func (u *marshalInfo) marshal(b []byte, ptr pointer, deterministic bool) ([]byte
 	if atomic.LoadInt32(&u.initialized) == 0 {
 		u.computeMarshalInfo()
 	}
+	if u.err != nil {
+		return b, u.err
+	}
 
 	// If the message can marshal itself, let it do it, for compatibility.
 	// NOTE: This is not efficient.
@@ -367,17 +383,21 @@ func (u *marshalInfo) computeMarshalInfo() {
 		j++
 		field.name = f.Name
 		u.fields = append(u.fields, field)
+		var err error
 		if f.Tag.Get("protobuf_oneof") != "" {
-			field.computeOneofFieldInfo(&f, oneofImplementers)
-			continue
-		}
-		if f.Tag.Get("protobuf") == "" {
+			err = field.computeOneofFieldInfo(&f, oneofImplementers)
+		} else if f.Tag.Get("protobuf") == "" {
 			// field has no tag (not in generated message), ignore it
 			u.fields = u.fields[:len(u.fields)-1]
 			j--
 			continue
+		} else {
+			err = field.computeMarshalFieldInfo(&f)
+		}
+		// Keep the first error, which Size and Marshal report.
+		if err != nil && u.err == nil {
+			u.err = fmt.Errorf("proto: field %v.%s: %v", t, f.Name, err)
 		}
-		field.computeMarshalFieldInfo(&f)
 	}
 
 	// fields are marshaled in tag order on the wire.
@@ -415,6 +435,10 @@ func (u *marshalInfo) getExtElemInfo(desc *ExtensionDesc) *marshalElemInfo {
 		t = t.Elem()
 	}
 	sizer, marshaler := typeMarshaler(t, tags, false, false)
+	if marshaler == nil {
+		// Extension descriptors have no marshal info to hold the error.
+		panic(fmt.Sprintf("proto: extension %s: type %v does not match wire type %s", desc.Name, t, tags[0]))
+	}
 	var deref bool
 	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
 		t = reflect.PtrTo(t)
@@ -440,12 +464,13 @@ func (u *marshalInfo) getExtElemInfo(desc *ExtensionDesc) *marshalElemInfo {
 }
 
 // computeMarshalFieldInfo fills up the information to marshal a field.
-func (fi *marshalFieldInfo) computeMarshalFieldInfo(f *reflect.StructField) {
+// It returns an error if the Go type of the field does not match its tag.
+func (fi *marshalFieldInfo) computeMarshalFieldInfo(f *reflect.StructField) error {
 	// parse protobuf tag of the field.
 	// tag has format of "bytes,49,opt,name=foo,def=hello!"
 	tags := strings.Split(f.Tag.Get("protobuf"), ",")
 	if tags[0] == "" {
-		return
+		return nil
 	}
 	tag, err := strconv.Atoi(tags[1])
 	if err != nil {
@@ -456,10 +481,12 @@ func (fi *marshalFieldInfo) computeMarshalFieldInfo(f *reflect.StructField) {
 		fi.required = true
 	}
 	fi.setTag(f, tag, wt)
-	fi.setMarshaler(f, tags)
+	return fi.setMarshaler(f, tags)
 }
 
-func (fi *marshalFieldInfo) computeOneofFieldInfo(f *reflect.StructField, oneofImplementers []interface{}) {
+// computeOneofFieldInfo fills up the information to marshal a oneof field.
+// It returns an error if the Go type of a oneof element does not match its tag.
+func (fi *marshalFieldInfo) computeOneofFieldInfo(f *reflect.StructField, oneofImplementers []interface{}) error {
 	fi.field = toField(f)
 	fi.wiretag = math.MaxInt32 // Use a large tag number, make oneofs sorted at the end. This tag will not appear on the wire.
 	fi.isPointer = true
@@ -480,6 +507,9 @@ func (fi *marshalFieldInfo) computeOneofFieldInfo(f *reflect.StructField, oneofI
 		}
 		wt := wiretype(tags[0])
 		sizer, marshaler := typeMarshaler(sf.Type, tags, false, true) // oneof should not omit any zero value
+		if marshaler == nil {
+			return fmt.Errorf("oneof element %v: type %v does not match wire type %s", t.Elem(), sf.Type, tags[0])
+		}
 		fi.oneofElems[t.Elem()] = &marshalElemInfo{
 			wiretag:   uint64(tag)<<3 | wt,
 			tagsize:   SizeVarint(uint64(tag) << 3),
@@ -487,6 +517,7 @@ func (fi *marshalFieldInfo) computeOneofFieldInfo(f *reflect.StructField, oneofI
 			marshaler: marshaler,
 		}
 	}
+	return nil
 }
 
 // wiretype returns the wire encoding of the type.
@@ -514,17 +545,23 @@ func (fi *marshalFieldInfo) setTag(f *reflect.StructField, tag int, wt uint64) {
 }
 
 // setMarshaler fills up the sizer and marshaler in the info of a field.
-func (fi *marshalFieldInfo) setMarshaler(f *reflect.StructField, tags []string) {
+// It returns an error if the Go type of the field does not match its tag.
+func (fi *marshalFieldInfo) setMarshaler(f *reflect.StructField, tags []string) error {
 	switch f.Type.Kind() {
 	case reflect.Map:
 		// map field
 		fi.isPointer = true
-		fi.sizer, fi.marshaler = makeMapMarshaler(f)
-		return
+		var err error
+		fi.sizer, fi.marshaler, err = makeMapMarshaler(f)
+		return err
 	case reflect.Ptr, reflect.Slice:
 		fi.isPointer = true
 	}
 	fi.sizer, fi.marshaler = typeMarshaler(f.Type, tags, true, false)
+	if fi.marshaler == nil {
+		return fmt.Errorf("type %v does not match wire type %s", f.Type, tags[0])
+	}
+	return nil
 }
 
 // typeMarshaler returns the sizer and marshaler of a given field.
@@ -532,6 +569,8 @@ func (fi *marshalFieldInfo) setMarshaler(f *reflect.StructField, tags []string)
 // tags is the generated "protobuf" tag of the field.
 // If nozero is true, zero value is not marshaled to the wire.
 // If oneof is true, it is a oneof field.
+// It returns nil if t is not a supported type or does not match the
+// encoding in tags.
 func typeMarshaler(t reflect.Type, tags []string, nozero, oneof bool) (sizer, marshaler) {
 	encoding := tags[0]
 
@@ -559,8 +598,13 @@ func typeMarshaler(t reflect.Type, tags []string, nozero, oneof bool) (sizer, ma
 	}
 	validateUTF8 = validateUTF8 && proto3
 
+	// The encoding determines the wire type in the tag, so it must agree
+	// with the Go type for the kinds that do not switch on it below.
 	switch t.Kind() {
 	case reflect.Bool:
+		if encoding != "varint" {
+			break
+		}
 		if pointer {
 			return sizeBoolPtr, appendBoolPtr
 		}
@@ -727,6 +771,9 @@ func typeMarshaler(t reflect.Type, tags []string, nozero, oneof bool) (sizer, ma
 			return sizeZigzag64Value, appendZigzag64Value
 		}
 	case reflect.Float32:
+		if encoding != "fixed32" {
+			break
+		}
 		if pointer {
 			return sizeFloat32Ptr, appendFloat32Ptr
 		}
@@ -741,6 +788,9 @@ func typeMarshaler(t reflect.Type, tags []string, nozero, oneof bool) (sizer, ma
 		}
 		return sizeFloat32Value, appendFloat32Value
 	case reflect.Float64:
+		if encoding != "fixed64" {
+			break
+		}
 		if pointer {
 			return sizeFloat64Ptr, appendFloat64Ptr
 		}
@@ -755,6 +805,9 @@ func typeMarshaler(t reflect.Type, tags []string, nozero, oneof bool) (sizer, ma
 		}
 		return sizeFloat64Value, appendFloat64Value
 	case reflect.String:
+		if encoding != "bytes" {
+			break
+		}
 		if validateUTF8 {
 			if pointer {
 				return sizeStringPtr, appendUTF8StringPtr
@@ -778,6 +831,9 @@ func typeMarshaler(t reflect.Type, tags []string, nozero, oneof bool) (sizer, ma
 		}
 		return sizeStringValue, appendStringValue
 	case reflect.Slice:
+		if encoding != "bytes" {
+			break
+		}
 		if slice {
 			return sizeBytesSlice, appendBytesSlice
 		}
@@ -805,7 +861,7 @@ func typeMarshaler(t reflect.Type, tags []string, nozero, oneof bool) (sizer, ma
 			return makeMessageMarshaler(getMarshalInfo(t))
 		}
 	}
-	panic(fmt.Sprintf("unknown or mismatched type: type: %v, wire type: %v", t, encoding))
+	return nil, nil
 }
 
 // Below are functions to size/marshal a specific type of a field.
@@ -2276,7 +2332,8 @@ func makeMessageSliceMarshaler(u *marshalInfo) (sizer, marshaler) {
 
 // makeMapMarshaler returns the sizer and marshaler for a map field.
 // f is the pointer to the reflect data structure of the field.
-func makeMapMarshaler(f *reflect.StructField) (sizer, marshaler) {
+// It returns an error if the key or value type does not match its tag.
+func makeMapMarshaler(f *reflect.StructField) (sizer, marshaler, error) {
 	// figure out key and value type
 	t := f.Type
 	keyType := t.Key()
@@ -2285,6 +2342,12 @@ func makeMapMarshaler(f *reflect.StructField) (sizer, marshaler) {
 	valTags := strings.Split(f.Tag.Get("protobuf_val"), ",")
 	keySizer, keyMarshaler := typeMarshaler(keyType, keyTags, false, false) // don't omit zero value in map
 	valSizer, valMarshaler := typeMarshaler(valType, valTags, false, false) // don't omit zero value in map
+	if keyMarshaler == nil {
+		return nil, nil, fmt.Errorf("map key type %v does not match wire type %s", keyType, keyTags[0])
+	}
+	if valMarshaler == nil {
+		return nil, nil, fmt.Errorf("map value type %v does not match wire type %s", valType, valTags[0])
+	}
 	keyWireTag := 1<<3 | wiretype(keyTags[0])
 	valWireTag := 2<<3 | wiretype(valTags[0])
 
@@ -2353,7 +2416,7 @@ func makeMapMarshaler(f *reflect.StructField) (sizer, marshaler) {
 				}
 			}
 			return b, nerr.E
-		}
+		}, nil
 }
 
 // makeOneOfMarshaler returns the sizer and marshaler for a oneof field.
@@ -2736,6 +2799,9 @@ func Marshal(pb Message) ([]byte, error) {
 // a Buffer for most applications.
 func (p *Buffer) Marshal(pb Message) error {
 	var err error
+	if p.canonicalUnknown {
+		pb = canonicalUnknown(pb)
+	}
 	if m, ok := pb.(newMarshaler); ok {
 		siz := m.XXX_Size()
 		p.grow(siz) // make sure buf has enough capacity
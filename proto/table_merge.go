@@ -86,13 +86,20 @@ type mergeFieldInfo struct {
 
 var (
 	mergeInfoMap  = map[reflect.Type]*mergeInfo{}
-	mergeInfoLock sync.Mutex
+	mergeInfoLock sync.RWMutex
 )
 
 func getMergeInfo(t reflect.Type) *mergeInfo {
+	mergeInfoLock.RLock()
+	mi := mergeInfoMap[t]
+	mergeInfoLock.RUnlock()
+	if mi != nil {
+		return mi
+	}
+
 	mergeInfoLock.Lock()
 	defer mergeInfoLock.Unlock()
-	mi := mergeInfoMap[t]
+	mi = mergeInfoMap[t]
 	if mi == nil {
 		mi = &mergeInfo{typ: t}
 		mergeInfoMap[t] = mi
@@ -366,6 +373,8 @@ func (mi *mergeInfo) computeMergeInfo() {
 				}
 			}
 		case reflect.Float32:
+			// Floats are copied by assignment, which preserves the bit
+			// pattern of NaNs, including signaling NaNs and payloads.
 			switch {
 			case isSlice: // E.g., []float32
 				mfi.merge = func(dst, src pointer) {
@@ -60,7 +60,7 @@ func (a *InternalMessageInfo) Unmarshal(msg Message, b []byte) error {
 		atomicStoreUnmarshalInfo(&a.unmarshal, u)
 	}
 	// Then do the unmarshaling.
-	err := u.unmarshal(toPointer(&msg), b)
+	err := u.unmarshal(toPointer(&msg), b, nil)
 	return err
 }
 
@@ -86,7 +86,35 @@ type unmarshalInfo struct {
 // It decodes the field, stores it at f, and returns the unused bytes.
 // w is the wire encoding.
 // b is the data after the tag and wire encoding have been read.
-type unmarshaler func(b []byte, f pointer, w int) ([]byte, error)
+// o holds the options of the current unmarshal, and may be nil.
+type unmarshaler func(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error)
+
+// unmarshalOptions holds the state of a single unmarshal that is shared
+// by the unmarshalers of all the messages it decodes.
+// A nil *unmarshalOptions unmarshals with the default behavior.
+type unmarshalOptions struct {
+	interned       map[string]string // decoded strings to reuse, or nil to not intern
+	discardUnknown bool              // drop unknown fields instead of keeping them
+}
+
+// maxInternedStrings bounds the number of distinct strings that are interned.
+const maxInternedStrings = 1000
+
+// string returns the string with the contents of b. If strings are being
+// interned, an earlier equal string is returned instead of a new one.
+func (o *unmarshalOptions) string(b []byte) string {
+	if o == nil || o.interned == nil {
+		return string(b)
+	}
+	if s, ok := o.interned[string(b)]; ok { // does not allocate
+		return s
+	}
+	s := string(b)
+	if len(o.interned) < maxInternedStrings {
+		o.interned[s] = s
+	}
+	return s
+}
 
 type unmarshalFieldInfo struct {
 	// location of the field in the proto message structure.
@@ -103,7 +131,7 @@ type unmarshalFieldInfo struct {
 
 var (
 	unmarshalInfoMap  = map[reflect.Type]*unmarshalInfo{}
-	unmarshalInfoLock sync.Mutex
+	unmarshalInfoLock sync.RWMutex
 )
 
 // getUnmarshalInfo returns the data structure which can be
@@ -114,9 +142,16 @@ func getUnmarshalInfo(t reflect.Type) *unmarshalInfo {
 	// unconditionally. We would end up allocating one
 	// per occurrence of that type as a message or submessage.
 	// We use a cache here just to reduce memory usage.
+	unmarshalInfoLock.RLock()
+	u := unmarshalInfoMap[t]
+	unmarshalInfoLock.RUnlock()
+	if u != nil {
+		return u
+	}
+
 	unmarshalInfoLock.Lock()
 	defer unmarshalInfoLock.Unlock()
-	u := unmarshalInfoMap[t]
+	u = unmarshalInfoMap[t]
 	if u == nil {
 		u = &unmarshalInfo{typ: t}
 		// Note: we just set the type here. The rest of the fields
@@ -130,8 +165,9 @@ func getUnmarshalInfo(t reflect.Type) *unmarshalInfo {
 // u provides type information used to unmarshal the message.
 // m is a pointer to a protocol buffer message.
 // b is a byte stream to unmarshal into m.
+// o holds the options of the unmarshal, and may be nil.
 // This is top routine used when recursively unmarshaling submessages.
-func (u *unmarshalInfo) unmarshal(m pointer, b []byte) error {
+func (u *unmarshalInfo) unmarshal(m pointer, b []byte, o *unmarshalOptions) error {
 	if atomic.LoadInt32(&u.initialized) == 0 {
 		u.computeUnmarshalInfo()
 	}
@@ -170,7 +206,7 @@ func (u *unmarshalInfo) unmarshal(m pointer, b []byte) error {
 		}
 		if fn := f.unmarshal; fn != nil {
 			var err error
-			b, err = fn(b, m.offset(f.field), wire)
+			b, err = fn(b, m.offset(f.field), wire, o)
 			if err == nil {
 				reqMask |= f.reqMask
 				continue
@@ -243,6 +279,11 @@ func (u *unmarshalInfo) unmarshal(m pointer, b []byte) error {
 		if err != nil {
 			return err
 		}
+		if emap == nil && o != nil && o.discardUnknown {
+			// Drop the unknown field. Fields in the extension ranges are
+			// kept, as DiscardUnknown keeps them.
+			continue
+		}
 		*z = encodeVarint(*z, tag<<3|uint64(wire))
 		*z = append(*z, b0[:len(b0)-len(b)]...)
 
@@ -415,7 +456,7 @@ func (u *unmarshalInfo) computeUnmarshalInfo() {
 	// when decoding a buffer of all zeros. Without this code, we
 	// would decode and skip an all-zero buffer of even length.
 	// [0 0] is [tag=0/wiretype=varint varint-encoded-0].
-	u.setTag(0, zeroField, func(b []byte, f pointer, w int) ([]byte, error) {
+	u.setTag(0, zeroField, func(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 		return nil, fmt.Errorf("proto: %s: illegal tag 0 (wire type %d)", t, w)
 	}, 0, "")
 
@@ -656,7 +697,7 @@ func typeUnmarshaler(t reflect.Type, tags string) unmarshaler {
 
 // Below are all the unmarshalers for individual fields of various types.
 
-func unmarshalInt64Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalInt64Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -670,7 +711,7 @@ func unmarshalInt64Value(b []byte, f pointer, w int) ([]byte, error) {
 	return b, nil
 }
 
-func unmarshalInt64Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalInt64Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -684,7 +725,7 @@ func unmarshalInt64Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	return b, nil
 }
 
-func unmarshalInt64Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalInt64Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -722,7 +763,7 @@ func unmarshalInt64Slice(b []byte, f pointer, w int) ([]byte, error) {
 	return b, nil
 }
 
-func unmarshalSint64Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalSint64Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -736,7 +777,7 @@ func unmarshalSint64Value(b []byte, f pointer, w int) ([]byte, error) {
 	return b, nil
 }
 
-func unmarshalSint64Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalSint64Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -750,7 +791,7 @@ func unmarshalSint64Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	return b, nil
 }
 
-func unmarshalSint64Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalSint64Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -788,7 +829,7 @@ func unmarshalSint64Slice(b []byte, f pointer, w int) ([]byte, error) {
 	return b, nil
 }
 
-func unmarshalUint64Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalUint64Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -802,7 +843,7 @@ func unmarshalUint64Value(b []byte, f pointer, w int) ([]byte, error) {
 	return b, nil
 }
 
-func unmarshalUint64Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalUint64Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -816,7 +857,7 @@ func unmarshalUint64Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	return b, nil
 }
 
-func unmarshalUint64Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalUint64Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -854,7 +895,7 @@ func unmarshalUint64Slice(b []byte, f pointer, w int) ([]byte, error) {
 	return b, nil
 }
 
-func unmarshalInt32Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalInt32Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -864,11 +905,14 @@ func unmarshalInt32Value(b []byte, f pointer, w int) ([]byte, error) {
 	}
 	b = b[n:]
 	v := int32(x)
+	if int64(v) != int64(x) {
+		return nil, errOverflow
+	}
 	*f.toInt32() = v
 	return b, nil
 }
 
-func unmarshalInt32Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalInt32Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -878,11 +922,14 @@ func unmarshalInt32Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	}
 	b = b[n:]
 	v := int32(x)
+	if int64(v) != int64(x) {
+		return nil, errOverflow
+	}
 	f.setInt32Ptr(v)
 	return b, nil
 }
 
-func unmarshalInt32Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalInt32Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -901,6 +948,9 @@ func unmarshalInt32Slice(b []byte, f pointer, w int) ([]byte, error) {
 			}
 			b = b[n:]
 			v := int32(x)
+			if int64(v) != int64(x) {
+				return nil, errOverflow
+			}
 			f.appendInt32Slice(v)
 		}
 		return res, nil
@@ -914,11 +964,14 @@ func unmarshalInt32Slice(b []byte, f pointer, w int) ([]byte, error) {
 	}
 	b = b[n:]
 	v := int32(x)
+	if int64(v) != int64(x) {
+		return nil, errOverflow
+	}
 	f.appendInt32Slice(v)
 	return b, nil
 }
 
-func unmarshalSint32Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalSint32Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -927,12 +980,15 @@ func unmarshalSint32Value(b []byte, f pointer, w int) ([]byte, error) {
 		return nil, io.ErrUnexpectedEOF
 	}
 	b = b[n:]
+	if x > math.MaxUint32 {
+		return nil, errOverflow
+	}
 	v := int32(x>>1) ^ int32(x)<<31>>31
 	*f.toInt32() = v
 	return b, nil
 }
 
-func unmarshalSint32Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalSint32Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -941,12 +997,15 @@ func unmarshalSint32Ptr(b []byte, f pointer, w int) ([]byte, error) {
 		return nil, io.ErrUnexpectedEOF
 	}
 	b = b[n:]
+	if x > math.MaxUint32 {
+		return nil, errOverflow
+	}
 	v := int32(x>>1) ^ int32(x)<<31>>31
 	f.setInt32Ptr(v)
 	return b, nil
 }
 
-func unmarshalSint32Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalSint32Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -964,6 +1023,9 @@ func unmarshalSint32Slice(b []byte, f pointer, w int) ([]byte, error) {
 				return nil, io.ErrUnexpectedEOF
 			}
 			b = b[n:]
+			if x > math.MaxUint32 {
+				return nil, errOverflow
+			}
 			v := int32(x>>1) ^ int32(x)<<31>>31
 			f.appendInt32Slice(v)
 		}
@@ -977,12 +1039,15 @@ func unmarshalSint32Slice(b []byte, f pointer, w int) ([]byte, error) {
 		return nil, io.ErrUnexpectedEOF
 	}
 	b = b[n:]
+	if x > math.MaxUint32 {
+		return nil, errOverflow
+	}
 	v := int32(x>>1) ^ int32(x)<<31>>31
 	f.appendInt32Slice(v)
 	return b, nil
 }
 
-func unmarshalUint32Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalUint32Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -991,12 +1056,15 @@ func unmarshalUint32Value(b []byte, f pointer, w int) ([]byte, error) {
 		return nil, io.ErrUnexpectedEOF
 	}
 	b = b[n:]
+	if x > math.MaxUint32 {
+		return nil, errOverflow
+	}
 	v := uint32(x)
 	*f.toUint32() = v
 	return b, nil
 }
 
-func unmarshalUint32Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalUint32Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -1005,12 +1073,15 @@ func unmarshalUint32Ptr(b []byte, f pointer, w int) ([]byte, error) {
 		return nil, io.ErrUnexpectedEOF
 	}
 	b = b[n:]
+	if x > math.MaxUint32 {
+		return nil, errOverflow
+	}
 	v := uint32(x)
 	*f.toUint32Ptr() = &v
 	return b, nil
 }
 
-func unmarshalUint32Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalUint32Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -1028,6 +1099,9 @@ func unmarshalUint32Slice(b []byte, f pointer, w int) ([]byte, error) {
 				return nil, io.ErrUnexpectedEOF
 			}
 			b = b[n:]
+			if x > math.MaxUint32 {
+				return nil, errOverflow
+			}
 			v := uint32(x)
 			s := f.toUint32Slice()
 			*s = append(*s, v)
@@ -1042,13 +1116,16 @@ func unmarshalUint32Slice(b []byte, f pointer, w int) ([]byte, error) {
 		return nil, io.ErrUnexpectedEOF
 	}
 	b = b[n:]
+	if x > math.MaxUint32 {
+		return nil, errOverflow
+	}
 	v := uint32(x)
 	s := f.toUint32Slice()
 	*s = append(*s, v)
 	return b, nil
 }
 
-func unmarshalFixed64Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixed64Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed64 {
 		return b, errInternalBadWireType
 	}
@@ -1060,7 +1137,7 @@ func unmarshalFixed64Value(b []byte, f pointer, w int) ([]byte, error) {
 	return b[8:], nil
 }
 
-func unmarshalFixed64Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixed64Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed64 {
 		return b, errInternalBadWireType
 	}
@@ -1072,7 +1149,7 @@ func unmarshalFixed64Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	return b[8:], nil
 }
 
-func unmarshalFixed64Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixed64Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -1107,7 +1184,7 @@ func unmarshalFixed64Slice(b []byte, f pointer, w int) ([]byte, error) {
 	return b[8:], nil
 }
 
-func unmarshalFixedS64Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixedS64Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed64 {
 		return b, errInternalBadWireType
 	}
@@ -1119,7 +1196,7 @@ func unmarshalFixedS64Value(b []byte, f pointer, w int) ([]byte, error) {
 	return b[8:], nil
 }
 
-func unmarshalFixedS64Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixedS64Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed64 {
 		return b, errInternalBadWireType
 	}
@@ -1131,7 +1208,7 @@ func unmarshalFixedS64Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	return b[8:], nil
 }
 
-func unmarshalFixedS64Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixedS64Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -1166,7 +1243,7 @@ func unmarshalFixedS64Slice(b []byte, f pointer, w int) ([]byte, error) {
 	return b[8:], nil
 }
 
-func unmarshalFixed32Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixed32Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed32 {
 		return b, errInternalBadWireType
 	}
@@ -1178,7 +1255,7 @@ func unmarshalFixed32Value(b []byte, f pointer, w int) ([]byte, error) {
 	return b[4:], nil
 }
 
-func unmarshalFixed32Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixed32Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed32 {
 		return b, errInternalBadWireType
 	}
@@ -1190,7 +1267,7 @@ func unmarshalFixed32Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	return b[4:], nil
 }
 
-func unmarshalFixed32Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixed32Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -1225,7 +1302,7 @@ func unmarshalFixed32Slice(b []byte, f pointer, w int) ([]byte, error) {
 	return b[4:], nil
 }
 
-func unmarshalFixedS32Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixedS32Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed32 {
 		return b, errInternalBadWireType
 	}
@@ -1237,7 +1314,7 @@ func unmarshalFixedS32Value(b []byte, f pointer, w int) ([]byte, error) {
 	return b[4:], nil
 }
 
-func unmarshalFixedS32Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixedS32Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed32 {
 		return b, errInternalBadWireType
 	}
@@ -1249,7 +1326,7 @@ func unmarshalFixedS32Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	return b[4:], nil
 }
 
-func unmarshalFixedS32Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFixedS32Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -1282,7 +1359,7 @@ func unmarshalFixedS32Slice(b []byte, f pointer, w int) ([]byte, error) {
 	return b[4:], nil
 }
 
-func unmarshalBoolValue(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalBoolValue(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -1299,7 +1376,7 @@ func unmarshalBoolValue(b []byte, f pointer, w int) ([]byte, error) {
 	return b[n:], nil
 }
 
-func unmarshalBoolPtr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalBoolPtr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireVarint {
 		return b, errInternalBadWireType
 	}
@@ -1312,7 +1389,7 @@ func unmarshalBoolPtr(b []byte, f pointer, w int) ([]byte, error) {
 	return b[n:], nil
 }
 
-func unmarshalBoolSlice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalBoolSlice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -1349,7 +1426,7 @@ func unmarshalBoolSlice(b []byte, f pointer, w int) ([]byte, error) {
 	return b[n:], nil
 }
 
-func unmarshalFloat64Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFloat64Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed64 {
 		return b, errInternalBadWireType
 	}
@@ -1361,7 +1438,7 @@ func unmarshalFloat64Value(b []byte, f pointer, w int) ([]byte, error) {
 	return b[8:], nil
 }
 
-func unmarshalFloat64Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFloat64Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed64 {
 		return b, errInternalBadWireType
 	}
@@ -1373,7 +1450,7 @@ func unmarshalFloat64Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	return b[8:], nil
 }
 
-func unmarshalFloat64Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFloat64Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -1408,7 +1485,7 @@ func unmarshalFloat64Slice(b []byte, f pointer, w int) ([]byte, error) {
 	return b[8:], nil
 }
 
-func unmarshalFloat32Value(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFloat32Value(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed32 {
 		return b, errInternalBadWireType
 	}
@@ -1420,7 +1497,7 @@ func unmarshalFloat32Value(b []byte, f pointer, w int) ([]byte, error) {
 	return b[4:], nil
 }
 
-func unmarshalFloat32Ptr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFloat32Ptr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireFixed32 {
 		return b, errInternalBadWireType
 	}
@@ -1432,7 +1509,7 @@ func unmarshalFloat32Ptr(b []byte, f pointer, w int) ([]byte, error) {
 	return b[4:], nil
 }
 
-func unmarshalFloat32Slice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalFloat32Slice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w == WireBytes { // packed
 		x, n := decodeVarint(b)
 		if n == 0 {
@@ -1467,7 +1544,7 @@ func unmarshalFloat32Slice(b []byte, f pointer, w int) ([]byte, error) {
 	return b[4:], nil
 }
 
-func unmarshalStringValue(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalStringValue(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireBytes {
 		return b, errInternalBadWireType
 	}
@@ -1479,12 +1556,12 @@ func unmarshalStringValue(b []byte, f pointer, w int) ([]byte, error) {
 	if x > uint64(len(b)) {
 		return nil, io.ErrUnexpectedEOF
 	}
-	v := string(b[:x])
+	v := o.string(b[:x])
 	*f.toString() = v
 	return b[x:], nil
 }
 
-func unmarshalStringPtr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalStringPtr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireBytes {
 		return b, errInternalBadWireType
 	}
@@ -1496,12 +1573,12 @@ func unmarshalStringPtr(b []byte, f pointer, w int) ([]byte, error) {
 	if x > uint64(len(b)) {
 		return nil, io.ErrUnexpectedEOF
 	}
-	v := string(b[:x])
+	v := o.string(b[:x])
 	*f.toStringPtr() = &v
 	return b[x:], nil
 }
 
-func unmarshalStringSlice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalStringSlice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireBytes {
 		return b, errInternalBadWireType
 	}
@@ -1513,13 +1590,13 @@ func unmarshalStringSlice(b []byte, f pointer, w int) ([]byte, error) {
 	if x > uint64(len(b)) {
 		return nil, io.ErrUnexpectedEOF
 	}
-	v := string(b[:x])
+	v := o.string(b[:x])
 	s := f.toStringSlice()
 	*s = append(*s, v)
 	return b[x:], nil
 }
 
-func unmarshalUTF8StringValue(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalUTF8StringValue(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireBytes {
 		return b, errInternalBadWireType
 	}
@@ -1531,7 +1608,7 @@ func unmarshalUTF8StringValue(b []byte, f pointer, w int) ([]byte, error) {
 	if x > uint64(len(b)) {
 		return nil, io.ErrUnexpectedEOF
 	}
-	v := string(b[:x])
+	v := o.string(b[:x])
 	*f.toString() = v
 	if !utf8.ValidString(v) {
 		return b[x:], errInvalidUTF8
@@ -1539,7 +1616,7 @@ func unmarshalUTF8StringValue(b []byte, f pointer, w int) ([]byte, error) {
 	return b[x:], nil
 }
 
-func unmarshalUTF8StringPtr(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalUTF8StringPtr(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireBytes {
 		return b, errInternalBadWireType
 	}
@@ -1551,7 +1628,7 @@ func unmarshalUTF8StringPtr(b []byte, f pointer, w int) ([]byte, error) {
 	if x > uint64(len(b)) {
 		return nil, io.ErrUnexpectedEOF
 	}
-	v := string(b[:x])
+	v := o.string(b[:x])
 	*f.toStringPtr() = &v
 	if !utf8.ValidString(v) {
 		return b[x:], errInvalidUTF8
@@ -1559,7 +1636,7 @@ func unmarshalUTF8StringPtr(b []byte, f pointer, w int) ([]byte, error) {
 	return b[x:], nil
 }
 
-func unmarshalUTF8StringSlice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalUTF8StringSlice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireBytes {
 		return b, errInternalBadWireType
 	}
@@ -1571,7 +1648,7 @@ func unmarshalUTF8StringSlice(b []byte, f pointer, w int) ([]byte, error) {
 	if x > uint64(len(b)) {
 		return nil, io.ErrUnexpectedEOF
 	}
-	v := string(b[:x])
+	v := o.string(b[:x])
 	s := f.toStringSlice()
 	*s = append(*s, v)
 	if !utf8.ValidString(v) {
@@ -1582,7 +1659,7 @@ func unmarshalUTF8StringSlice(b []byte, f pointer, w int) ([]byte, error) {
 
 var emptyBuf [0]byte
 
-func unmarshalBytesValue(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalBytesValue(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireBytes {
 		return b, errInternalBadWireType
 	}
@@ -1603,7 +1680,7 @@ func unmarshalBytesValue(b []byte, f pointer, w int) ([]byte, error) {
 	return b[x:], nil
 }
 
-func unmarshalBytesSlice(b []byte, f pointer, w int) ([]byte, error) {
+func unmarshalBytesSlice(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 	if w != WireBytes {
 		return b, errInternalBadWireType
 	}
@@ -1622,7 +1699,7 @@ func unmarshalBytesSlice(b []byte, f pointer, w int) ([]byte, error) {
 }
 
 func makeUnmarshalMessagePtr(sub *unmarshalInfo, name string) unmarshaler {
-	return func(b []byte, f pointer, w int) ([]byte, error) {
+	return func(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 		if w != WireBytes {
 			return b, errInternalBadWireType
 		}
@@ -1643,7 +1720,7 @@ func makeUnmarshalMessagePtr(sub *unmarshalInfo, name string) unmarshaler {
 			v = valToPointer(reflect.New(sub.typ))
 			f.setPointer(v)
 		}
-		err := sub.unmarshal(v, b[:x])
+		err := sub.unmarshal(v, b[:x], o)
 		if err != nil {
 			if r, ok := err.(*RequiredNotSetError); ok {
 				r.field = name + "." + r.field
@@ -1656,7 +1733,7 @@ func makeUnmarshalMessagePtr(sub *unmarshalInfo, name string) unmarshaler {
 }
 
 func makeUnmarshalMessageSlicePtr(sub *unmarshalInfo, name string) unmarshaler {
-	return func(b []byte, f pointer, w int) ([]byte, error) {
+	return func(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 		if w != WireBytes {
 			return b, errInternalBadWireType
 		}
@@ -1669,7 +1746,7 @@ func makeUnmarshalMessageSlicePtr(sub *unmarshalInfo, name string) unmarshaler {
 			return nil, io.ErrUnexpectedEOF
 		}
 		v := valToPointer(reflect.New(sub.typ))
-		err := sub.unmarshal(v, b[:x])
+		err := sub.unmarshal(v, b[:x], o)
 		if err != nil {
 			if r, ok := err.(*RequiredNotSetError); ok {
 				r.field = name + "." + r.field
@@ -1683,7 +1760,7 @@ func makeUnmarshalMessageSlicePtr(sub *unmarshalInfo, name string) unmarshaler {
 }
 
 func makeUnmarshalGroupPtr(sub *unmarshalInfo, name string) unmarshaler {
-	return func(b []byte, f pointer, w int) ([]byte, error) {
+	return func(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 		if w != WireStartGroup {
 			return b, errInternalBadWireType
 		}
@@ -1696,7 +1773,7 @@ func makeUnmarshalGroupPtr(sub *unmarshalInfo, name string) unmarshaler {
 			v = valToPointer(reflect.New(sub.typ))
 			f.setPointer(v)
 		}
-		err := sub.unmarshal(v, b[:x])
+		err := sub.unmarshal(v, b[:x], o)
 		if err != nil {
 			if r, ok := err.(*RequiredNotSetError); ok {
 				r.field = name + "." + r.field
@@ -1709,7 +1786,7 @@ func makeUnmarshalGroupPtr(sub *unmarshalInfo, name string) unmarshaler {
 }
 
 func makeUnmarshalGroupSlicePtr(sub *unmarshalInfo, name string) unmarshaler {
-	return func(b []byte, f pointer, w int) ([]byte, error) {
+	return func(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 		if w != WireStartGroup {
 			return b, errInternalBadWireType
 		}
@@ -1718,7 +1795,7 @@ func makeUnmarshalGroupSlicePtr(sub *unmarshalInfo, name string) unmarshaler {
 			return nil, io.ErrUnexpectedEOF
 		}
 		v := valToPointer(reflect.New(sub.typ))
-		err := sub.unmarshal(v, b[:x])
+		err := sub.unmarshal(v, b[:x], o)
 		if err != nil {
 			if r, ok := err.(*RequiredNotSetError); ok {
 				r.field = name + "." + r.field
@@ -1737,7 +1814,7 @@ func makeUnmarshalMap(f *reflect.StructField) unmarshaler {
 	vt := t.Elem()
 	unmarshalKey := typeUnmarshaler(kt, f.Tag.Get("protobuf_key"))
 	unmarshalVal := typeUnmarshaler(vt, f.Tag.Get("protobuf_val"))
-	return func(b []byte, f pointer, w int) ([]byte, error) {
+	return func(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
 		// The map entry is a submessage. Figure out how big it is.
 		if w != WireBytes {
 			return nil, fmt.Errorf("proto: bad wiretype for map field: got %d want %d", w, WireBytes)
@@ -1772,9 +1849,9 @@ func makeUnmarshalMap(f *reflect.StructField) unmarshaler {
 			var err error
 			switch x >> 3 {
 			case 1:
-				b, err = unmarshalKey(b, valToPointer(k), wire)
+				b, err = unmarshalKey(b, valToPointer(k), wire, o)
 			case 2:
-				b, err = unmarshalVal(b, valToPointer(v), wire)
+				b, err = unmarshalVal(b, valToPointer(v), wire, o)
 			default:
 				err = errInternalBadWireType // skip unknown tag
 			}
@@ -1821,15 +1898,23 @@ func makeUnmarshalMap(f *reflect.StructField) unmarshaler {
 func makeUnmarshalOneof(typ, ityp reflect.Type, unmarshal unmarshaler) unmarshaler {
 	sf := typ.Field(0)
 	field0 := toField(&sf)
-	return func(b []byte, f pointer, w int) ([]byte, error) {
-		// Allocate holder for value.
-		v := reflect.New(typ)
+	ptyp := reflect.PtrTo(typ)
+	return func(b []byte, f pointer, w int, o *unmarshalOptions) ([]byte, error) {
+		// Reuse the holder if this case is already set, so that a message
+		// value is merged into rather than replaced, as it is for Merge.
+		// Otherwise allocate a holder for the value.
+		var v reflect.Value
+		if iv := f.asPointerTo(ityp).Elem(); !iv.IsNil() && iv.Elem().Type() == ptyp {
+			v = iv.Elem()
+		} else {
+			v = reflect.New(typ)
+		}
 
 		// Unmarshal data into holder.
 		// We unmarshal into the first field of the holder object.
 		var err error
 		var nerr nonFatal
-		b, err = unmarshal(b, valToPointer(v).offset(field0), w)
+		b, err = unmarshal(b, valToPointer(v).offset(field0), w, o)
 		if !nerr.Merge(err) {
 			return nil, err
 		}
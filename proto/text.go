@@ -71,6 +71,7 @@ type textWriter struct {
 	ind      int
 	complete bool // if the current position is a complete line
 	compact  bool // whether to write out as a one-liner
+	eol      string
 	w        writer
 }
 
@@ -127,10 +128,11 @@ func (w *textWriter) Write(p []byte) (n int, err error) {
 			return n, err
 		}
 		if i+1 < len(frags) {
-			if err := w.w.WriteByte('\n'); err != nil {
+			nn, err := w.writeEOL()
+			n += nn
+			if err != nil {
 				return n, err
 			}
-			n++
 		}
 	}
 	w.complete = len(frags[len(frags)-1]) == 0
@@ -144,11 +146,28 @@ func (w *textWriter) WriteByte(c byte) error {
 	if !w.compact && w.complete {
 		w.writeIndent()
 	}
+	if c == '\n' {
+		_, err := w.writeEOL()
+		w.complete = true
+		return err
+	}
 	err := w.w.WriteByte(c)
-	w.complete = c == '\n'
+	w.complete = false
 	return err
 }
 
+// writeEOL writes the line terminator, which is "\n" unless
+// the TextMarshaler requested otherwise.
+func (w *textWriter) writeEOL() (int, error) {
+	if w.eol == "" {
+		if err := w.w.WriteByte('\n'); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+	return io.WriteString(w.w, w.eol)
+}
+
 func (w *textWriter) indent() { w.ind++ }
 
 func (w *textWriter) unindent() {
@@ -293,6 +312,11 @@ func (tm *TextMarshaler) writeStruct(w *textWriter, sv reflect.Value) error {
 
 		if props.Repeated && fv.Kind() == reflect.Slice {
 			// Repeated field.
+			if tm.RepeatedFieldHeaders && !w.compact && fv.Len() > 0 {
+				if _, err := fmt.Fprintf(w, "# %s (%d)\n", props.OrigName, fv.Len()); err != nil {
+					return err
+				}
+			}
 			for j := 0; j < fv.Len(); j++ {
 				if err := writeName(w, props); err != nil {
 					return err
@@ -314,6 +338,9 @@ func (tm *TextMarshaler) writeStruct(w *textWriter, sv reflect.Value) error {
 				if err := tm.writeAny(w, v, props); err != nil {
 					return err
 				}
+				if err := tm.writeFieldNumber(w, props.Tag); err != nil {
+					return err
+				}
 				if err := w.WriteByte('\n'); err != nil {
 					return err
 				}
@@ -322,6 +349,9 @@ func (tm *TextMarshaler) writeStruct(w *textWriter, sv reflect.Value) error {
 		}
 		if fv.Kind() == reflect.Map {
 			// Map fields are rendered as a repeated struct with key/value fields.
+			if kt := fv.Type().Key(); !isMapKeyKind(kt.Kind()) {
+				return fmt.Errorf("proto: map field %v.%s has invalid key type %v", st, props.OrigName, kt)
+			}
 			keys := fv.MapKeys()
 			sort.Sort(mapKeys(keys))
 			for _, key := range keys {
@@ -356,6 +386,9 @@ func (tm *TextMarshaler) writeStruct(w *textWriter, sv reflect.Value) error {
 				if err := tm.writeAny(w, key, props.MapKeyProp); err != nil {
 					return err
 				}
+				if err := tm.writeFieldNumber(w, 1); err != nil {
+					return err
+				}
 				if err := w.WriteByte('\n'); err != nil {
 					return err
 				}
@@ -373,6 +406,9 @@ func (tm *TextMarshaler) writeStruct(w *textWriter, sv reflect.Value) error {
 					if err := tm.writeAny(w, val, props.MapValProp); err != nil {
 						return err
 					}
+					if err := tm.writeFieldNumber(w, 2); err != nil {
+						return err
+					}
 					if err := w.WriteByte('\n'); err != nil {
 						return err
 					}
@@ -382,6 +418,9 @@ func (tm *TextMarshaler) writeStruct(w *textWriter, sv reflect.Value) error {
 				if err := w.WriteByte('>'); err != nil {
 					return err
 				}
+				if err := tm.writeFieldNumber(w, props.Tag); err != nil {
+					return err
+				}
 				if err := w.WriteByte('\n'); err != nil {
 					return err
 				}
@@ -439,6 +478,9 @@ func (tm *TextMarshaler) writeStruct(w *textWriter, sv reflect.Value) error {
 		if err := tm.writeAny(w, fv, props); err != nil {
 			return err
 		}
+		if err := tm.writeFieldNumber(w, props.Tag); err != nil {
+			return err
+		}
 
 		if err := w.WriteByte('\n'); err != nil {
 			return err
@@ -676,13 +718,35 @@ func (s int32Slice) Len() int           { return len(s) }
 func (s int32Slice) Less(i, j int) bool { return s[i] < s[j] }
 func (s int32Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
+// extensionsByName sorts extension field numbers by the full name of the
+// extension. Extensions without a registered descriptor sort last, by number.
+type extensionsByName struct {
+	ids  []int32
+	emap map[int32]*ExtensionDesc
+}
+
+func (s extensionsByName) Len() int      { return len(s.ids) }
+func (s extensionsByName) Swap(i, j int) { s.ids[i], s.ids[j] = s.ids[j], s.ids[i] }
+func (s extensionsByName) Less(i, j int) bool {
+	di, dj := s.emap[s.ids[i]], s.emap[s.ids[j]]
+	switch {
+	case di != nil && dj != nil && di.Name != dj.Name:
+		return di.Name < dj.Name
+	case di != nil && dj == nil:
+		return true
+	case di == nil && dj != nil:
+		return false
+	}
+	return s.ids[i] < s.ids[j]
+}
+
 // writeExtensions writes all the extensions in pv.
 // pv is assumed to be a pointer to a protocol message struct that is extendable.
 func (tm *TextMarshaler) writeExtensions(w *textWriter, pv reflect.Value) error {
 	emap := extensionMaps[pv.Type().Elem()]
 	ep, _ := extendable(pv.Interface())
 
-	// Order the extensions by ID.
+	// Order the extensions by ID, or by name if requested.
 	// This isn't strictly necessary, but it will give us
 	// canonical output, which will also make testing easier.
 	m, mu := ep.extensionsRead()
@@ -694,7 +758,11 @@ func (tm *TextMarshaler) writeExtensions(w *textWriter, pv reflect.Value) error
 	for id := range m {
 		ids = append(ids, id)
 	}
-	sort.Sort(int32Slice(ids))
+	if tm.SortExtensionsByName {
+		sort.Sort(extensionsByName{ids, emap})
+	} else {
+		sort.Sort(int32Slice(ids))
+	}
 	mu.Unlock()
 
 	for _, extNum := range ids {
@@ -718,13 +786,13 @@ func (tm *TextMarshaler) writeExtensions(w *textWriter, pv reflect.Value) error
 
 		// Repeated extensions will appear as a slice.
 		if !desc.repeated() {
-			if err := tm.writeExtension(w, desc.Name, pb); err != nil {
+			if err := tm.writeExtension(w, desc.Name, desc.Field, pb); err != nil {
 				return err
 			}
 		} else {
 			v := reflect.ValueOf(pb)
 			for i := 0; i < v.Len(); i++ {
-				if err := tm.writeExtension(w, desc.Name, v.Index(i).Interface()); err != nil {
+				if err := tm.writeExtension(w, desc.Name, desc.Field, v.Index(i).Interface()); err != nil {
 					return err
 				}
 			}
@@ -733,7 +801,7 @@ func (tm *TextMarshaler) writeExtensions(w *textWriter, pv reflect.Value) error
 	return nil
 }
 
-func (tm *TextMarshaler) writeExtension(w *textWriter, name string, pb interface{}) error {
+func (tm *TextMarshaler) writeExtension(w *textWriter, name string, num int32, pb interface{}) error {
 	if _, err := fmt.Fprintf(w, "[%s]:", name); err != nil {
 		return err
 	}
@@ -745,12 +813,25 @@ func (tm *TextMarshaler) writeExtension(w *textWriter, name string, pb interface
 	if err := tm.writeAny(w, reflect.ValueOf(pb), nil); err != nil {
 		return err
 	}
+	if err := tm.writeFieldNumber(w, int(num)); err != nil {
+		return err
+	}
 	if err := w.WriteByte('\n'); err != nil {
 		return err
 	}
 	return nil
 }
 
+// writeFieldNumber writes a comment holding the field number num at the
+// end of a field's line, if tm.AnnotateFieldNumbers is set.
+func (tm *TextMarshaler) writeFieldNumber(w *textWriter, num int) error {
+	if !tm.AnnotateFieldNumbers || w.compact {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, " # %d", num)
+	return err
+}
+
 func (w *textWriter) writeIndent() {
 	if !w.complete {
 		return
@@ -771,6 +852,28 @@ func (w *textWriter) writeIndent() {
 type TextMarshaler struct {
 	Compact   bool // use compact text format (one line).
 	ExpandAny bool // expand google.protobuf.Any messages of known types
+
+	// LineEnding is the line terminator used by the non-compact format.
+	// If empty, "\n" is used.
+	LineEnding string
+
+	// SortExtensionsByName orders extension fields by their full name
+	// instead of by field number. By default, extensions are written in
+	// ascending field number order.
+	SortExtensionsByName bool
+
+	// RepeatedFieldHeaders precedes the entries of each repeated field
+	// with a comment line holding the field name and the number of
+	// entries, like "# rpt_nested (3)". The comments are ignored by the
+	// parser. They are not written in the compact format.
+	RepeatedFieldHeaders bool
+
+	// AnnotateFieldNumbers ends the line of each field, including
+	// extensions and the key and value of map entries, with a comment
+	// holding the field number, like "count: 42 # 1". For a message, the
+	// comment follows its closing bracket. The comments are ignored by the
+	// parser. They are not written in the compact format.
+	AnnotateFieldNumbers bool
 }
 
 // Marshal writes a given protocol buffer in text format.
@@ -791,6 +894,7 @@ func (tm *TextMarshaler) Marshal(w io.Writer, pb Message) error {
 		w:        ww,
 		complete: true,
 		compact:  tm.Compact,
+		eol:      tm.LineEnding,
 	}
 
 	if etm, ok := pb.(encoding.TextMarshaler); ok {
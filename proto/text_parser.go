@@ -82,11 +82,19 @@ type textParser struct {
 	backed       bool   // whether back() was called
 	offset, line int
 	cur          token
+
+	bytesList   bool // accept bytes values written as lists of byte values
+	maxElements int  // maximum number of elements in a repeated field or map; 0 means no limit
+	lenientBool bool // accept bool values in any letter case
 }
 
+// utf8BOM is the UTF-8 encoding of the byte order mark, which some editors
+// write at the start of text files.
+const utf8BOM = "\ufeff"
+
 func newTextParser(s string) *textParser {
 	p := new(textParser)
-	p.s = s
+	p.s = strings.TrimPrefix(s, utf8BOM)
 	p.line = 1
 	p.cur.line = 1
 	return p
@@ -381,6 +389,15 @@ func (p *textParser) missingRequiredFieldError(sv reflect.Value) *RequiredNotSet
 	return &RequiredNotSetError{fmt.Sprintf("%v.<unknown field name>", st)} // should not happen
 }
 
+// checkElements returns an error if a repeated field or map that already
+// holds n elements cannot accept another one.
+func (p *textParser) checkElements(n int) *ParseError {
+	if p.maxElements > 0 && n >= p.maxElements {
+		return p.errorf("too many elements in repeated field or map (limit %d)", p.maxElements)
+	}
+	return nil
+}
+
 // Returns the index in the struct for the named field, as well as the parsed tag properties.
 func structFieldByName(sprops *StructProperties, name string) (int, *Properties, bool) {
 	i, ok := sprops.decoderOrigNames[name]
@@ -558,6 +575,9 @@ func (p *textParser) readStruct(sv reflect.Value, terminator string) error {
 				} else {
 					sl = reflect.MakeSlice(typ, 0, 1)
 				}
+				if err := p.checkElements(sl.Len()); err != nil {
+					return err
+				}
 				sl = reflect.Append(sl, ext)
 				SetExtension(ep, desc, sl.Interface())
 			}
@@ -589,6 +609,10 @@ func (p *textParser) readStruct(sv reflect.Value, terminator string) error {
 		}
 
 		if dst.Kind() == reflect.Map {
+			if kt := dst.Type().Key(); !isMapKeyKind(kt.Kind()) {
+				return p.errorf("map field %q in %v has invalid key type %v", name, st, kt)
+			}
+
 			// Consume any colon.
 			if err := p.checkForColon(props, dst.Type()); err != nil {
 				return err
@@ -652,6 +676,11 @@ func (p *textParser) readStruct(sv reflect.Value, terminator string) error {
 				}
 			}
 
+			if !dst.MapIndex(key).IsValid() {
+				if err := p.checkElements(dst.Len()); err != nil {
+					return err
+				}
+			}
 			dst.SetMapIndex(key, val)
 			continue
 		}
@@ -748,6 +777,9 @@ func (p *textParser) readAny(v reflect.Value, props *Properties) error {
 		at := v.Type()
 		if at.Elem().Kind() == reflect.Uint8 {
 			// Special case for []byte
+			if tok.value == "[" && p.bytesList {
+				return p.readBytesList(fv)
+			}
 			if tok.value[0] != '"' && tok.value[0] != '\'' {
 				// Deliberately written out here, as the error after
 				// this switch statement would write "invalid []byte: ...",
@@ -761,7 +793,22 @@ func (p *textParser) readAny(v reflect.Value, props *Properties) error {
 		// Repeated field.
 		if tok.value == "[" {
 			// Repeated field with list notation, like [1,2,3].
+			// The list may be empty, and may end with a comma, like [1,2,3,].
+			tok := p.next()
+			if tok.err != nil {
+				return tok.err
+			}
+			if tok.value == "]" {
+				return nil
+			}
+			if tok.value == "," {
+				return p.errorf("Expected a list element or ']' found %q", tok.value)
+			}
+			p.back()
 			for {
+				if err := p.checkElements(fv.Len()); err != nil {
+					return err
+				}
 				fv.Set(reflect.Append(fv, reflect.New(at.Elem()).Elem()))
 				err := p.readAny(fv.Index(fv.Len()-1), props)
 				if err != nil {
@@ -777,11 +824,21 @@ func (p *textParser) readAny(v reflect.Value, props *Properties) error {
 				if tok.value != "," {
 					return p.errorf("Expected ']' or ',' found %q", tok.value)
 				}
+				if tok = p.next(); tok.err != nil {
+					return tok.err
+				}
+				if tok.value == "]" {
+					break
+				}
+				p.back()
 			}
 			return nil
 		}
 		// One value of the repeated field.
 		p.back()
+		if err := p.checkElements(fv.Len()); err != nil {
+			return err
+		}
 		fv.Set(reflect.Append(fv, reflect.New(at.Elem()).Elem()))
 		return p.readAny(fv.Index(fv.Len()-1), props)
 	case reflect.Bool:
@@ -794,6 +851,16 @@ func (p *textParser) readAny(v reflect.Value, props *Properties) error {
 			fv.SetBool(false)
 			return nil
 		}
+		if p.lenientBool {
+			switch strings.ToLower(tok.value) {
+			case "true", "t":
+				fv.SetBool(true)
+				return nil
+			case "false", "f":
+				fv.SetBool(false)
+				return nil
+			}
+		}
 	case reflect.Float32, reflect.Float64:
 		v := tok.value
 		// Ignore 'f' for compatibility with output generated by C++, but don't
@@ -866,15 +933,82 @@ func (p *textParser) readAny(v reflect.Value, props *Properties) error {
 	return p.errorf("invalid %v: %v", v.Type(), tok.value)
 }
 
-// UnmarshalText reads a protocol buffer in Text format. UnmarshalText resets pb
+// readBytesList reads the remainder of a bytes value written as a list of
+// byte values, like [104, 105], after the opening '['.
+func (p *textParser) readBytesList(v reflect.Value) error {
+	b := []byte{}
+	for {
+		tok := p.next()
+		if tok.err != nil {
+			return tok.err
+		}
+		if tok.value == "]" {
+			break
+		}
+		x, err := strconv.ParseUint(tok.value, 0, 8)
+		if err != nil {
+			return p.errorf("invalid byte value %q in bytes list", tok.value)
+		}
+		b = append(b, byte(x))
+
+		tok = p.next()
+		if tok.err != nil {
+			return tok.err
+		}
+		if tok.value == "]" {
+			break
+		}
+		if tok.value != "," {
+			return p.errorf("Expected ']' or ',' found %q", tok.value)
+		}
+	}
+	v.Set(reflect.ValueOf(b))
+	return nil
+}
+
+// TextUnmarshaler is a configurable text format parser.
+type TextUnmarshaler struct {
+	// AllowBytesList accepts bytes field values written as a list of byte
+	// values, like [104, 105], as well as the canonical quoted strings.
+	// Some legacy emitters write bytes this way.
+	AllowBytesList bool
+
+	// MaxElements, if positive, limits the number of elements in any one
+	// repeated field or map. The limit applies to the total for a field,
+	// whether its elements are written as a list or as repeated entries.
+	MaxElements int
+
+	// LenientBool accepts bool values spelled in any letter case, like TRUE
+	// or False or T, as written by some other tools. By default, only
+	// true, True, t and 1 and false, False, f and 0 are accepted.
+	LenientBool bool
+}
+
+// Unmarshal reads a protocol buffer in Text format. Unmarshal resets pb
 // before starting to unmarshal, so any existing data in pb is always removed.
+// A UTF-8 byte order mark at the start of s is ignored.
 // If a required field is not set and no other error occurs,
-// UnmarshalText returns *RequiredNotSetError.
-func UnmarshalText(s string, pb Message) error {
+// Unmarshal returns *RequiredNotSetError.
+func (tu *TextUnmarshaler) Unmarshal(s string, pb Message) error {
 	if um, ok := pb.(encoding.TextUnmarshaler); ok {
 		return um.UnmarshalText([]byte(s))
 	}
 	pb.Reset()
 	v := reflect.ValueOf(pb)
-	return newTextParser(s).readStruct(v.Elem(), "")
+	p := newTextParser(s)
+	p.bytesList = tu.AllowBytesList
+	p.maxElements = tu.MaxElements
+	p.lenientBool = tu.LenientBool
+	return p.readStruct(v.Elem(), "")
+}
+
+var defaultTextUnmarshaler = TextUnmarshaler{}
+
+// UnmarshalText reads a protocol buffer in Text format. UnmarshalText resets pb
+// before starting to unmarshal, so any existing data in pb is always removed.
+// A UTF-8 byte order mark at the start of s is ignored.
+// If a required field is not set and no other error occurs,
+// UnmarshalText returns *RequiredNotSetError.
+func UnmarshalText(s string, pb Message) error {
+	return defaultTextUnmarshaler.Unmarshal(s, pb)
 }
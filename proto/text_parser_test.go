@@ -34,6 +34,7 @@ package proto_test
 import (
 	"fmt"
 	"math"
+	"strings"
 	"testing"
 
 	. "github.com/golang/protobuf/proto"
@@ -86,6 +87,15 @@ var unMarshalTextTests = []UnmarshalTextTest{
 		},
 	},
 
+	// CRLF line endings
+	{
+		in: "count:42 # Meaning\r\nname:\"Dave\"\r\n",
+		out: &MyMessage{
+			Count: Int32(42),
+			Name:  String("Dave"),
+		},
+	},
+
 	// Empty quoted string
 	{
 		in: `count:42 name:""`,
@@ -323,6 +333,47 @@ var unMarshalTextTests = []UnmarshalTextTest{
 		},
 	},
 
+	// Repeated field with list notation and a trailing comma
+	{
+		in: `count:42 pet: ["horsey", "bunny",]`,
+		out: &MyMessage{
+			Count: Int32(42),
+			Pet:   []string{"horsey", "bunny"},
+		},
+	},
+
+	// Repeated message with list notation and a trailing comma
+	{
+		in: `count:42 others: [{key: 1}, <key: 2>,]`,
+		out: &MyMessage{
+			Count: Int32(42),
+			Others: []*OtherMessage{
+				{Key: Int64(1)},
+				{Key: Int64(2)},
+			},
+		},
+	},
+
+	// Empty list notation
+	{
+		in: `count:42 pet: [] others: []`,
+		out: &MyMessage{
+			Count: Int32(42),
+		},
+	},
+
+	// List notation with only a comma
+	{
+		in:  `count:42 pet: [,]`,
+		err: `line 1.15: Expected a list element or ']' found ","`,
+	},
+
+	// List notation with a doubled comma
+	{
+		in:  `count:42 pet: ["horsey",,]`,
+		err: `line 1.24: invalid string: ,`,
+	},
+
 	// Repeated message with/without colon and <>/{}
 	{
 		in: `count:42 others:{} others{} others:<> others:{}`,
@@ -681,6 +732,162 @@ func TestOneofParsing(t *testing.T) {
 
 }
 
+func TestBytesListParsing(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []byte
+		err  string
+	}{
+		{in: `F_Bytes: [104, 105]`, want: []byte("hi")},
+		{in: `F_Bytes: [0x68, 0151, 0,255,]`, want: []byte("hi\x00\xff")},
+		{in: `F_Bytes: []`, want: []byte{}},
+		{in: `F_Bytes: "hi"`, want: []byte("hi")},
+		{in: `F_Bytes: [104, 256]`, err: `line 1.15: invalid byte value "256" in bytes list`},
+		{in: `F_Bytes: [-1]`, err: `line 1.10: invalid byte value "-1" in bytes list`},
+		{in: `F_Bytes: ["h"]`, err: `line 1.10: invalid byte value "\"h\"" in bytes list`},
+		{in: `F_Bytes: [104 105]`, err: `line 1.14: Expected ']' or ',' found "105"`},
+	}
+	tu := TextUnmarshaler{AllowBytesList: true}
+	for _, tt := range tests {
+		m := new(Defaults)
+		err := tu.Unmarshal(tt.in, m)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Unmarshal(%q) = %v, want error %q", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%q): %v", tt.in, err)
+			continue
+		}
+		if m.F_Bytes == nil || string(m.F_Bytes) != string(tt.want) {
+			t.Errorf("Unmarshal(%q): F_Bytes = %q, want %q", tt.in, m.F_Bytes, tt.want)
+		}
+	}
+
+	// The list form is rejected unless it is enabled.
+	if err := UnmarshalText(`F_Bytes: [104, 105]`, new(Defaults)); err == nil {
+		t.Errorf("UnmarshalText accepted a bytes list by default")
+	}
+}
+
+func TestUnmarshalTextBOM(t *testing.T) {
+	m := new(MyMessage)
+	if err := UnmarshalText("\ufeffcount: 42 name: \"Dave\"", m); err != nil {
+		t.Fatalf("UnmarshalText with a leading BOM: %v", err)
+	}
+	if want := (&MyMessage{Count: Int32(42), Name: String("Dave")}); !Equal(m, want) {
+		t.Errorf("UnmarshalText with a leading BOM = %v, want %v", m, want)
+	}
+
+	for _, in := range []string{
+		"count: 42 \ufeffname: \"Dave\"",
+		"count: \ufeff42",
+		"\ufeff\ufeffcount: 42",
+	} {
+		if err := UnmarshalText(in, new(MyMessage)); err == nil {
+			t.Errorf("UnmarshalText(%q) succeeded, want error for a BOM after the start", in)
+		}
+	}
+}
+
+func TestMaxElementsParsing(t *testing.T) {
+	tests := []struct {
+		in  string
+		err bool
+	}{
+		{in: `ints: [1, 2, 3]`},
+		{in: `ints: 1 ints: 2 ints: 3`},
+		{in: `ints: [1, 2] ints: 3`},
+		{in: `ints: [1, 2] ints: 3 bools: [true, false, true]`},
+		{in: `ints: [1, 2, 3, 4]`, err: true},
+		{in: `ints: 1 ints: 2 ints: 3 ints: 4`, err: true},
+		{in: `ints: [1, 2] ints: [3, 4]`, err: true},
+		{in: `ints: 1 ints: [2, 3] ints: 4`, err: true},
+	}
+	tu := TextUnmarshaler{MaxElements: 3}
+	for _, tt := range tests {
+		m := new(MoreRepeated)
+		err := tu.Unmarshal(tt.in, m)
+		if tt.err {
+			if err == nil || !strings.Contains(err.Error(), "too many elements") {
+				t.Errorf("Unmarshal(%q) = %v, want too many elements error", tt.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%q): %v", tt.in, err)
+		}
+	}
+
+	const mapIn = `name_mapping: {key: 1 value: "a"} name_mapping: {key: 2 value: "b"} name_mapping: {key: 1 value: "c"}`
+	if err := (&TextUnmarshaler{MaxElements: 2}).Unmarshal(mapIn, new(MessageWithMap)); err != nil {
+		t.Errorf("Unmarshal(%q): %v", mapIn, err)
+	}
+	const mapOver = mapIn + ` name_mapping: {key: 3 value: "d"}`
+	if err := (&TextUnmarshaler{MaxElements: 2}).Unmarshal(mapOver, new(MessageWithMap)); err == nil {
+		t.Errorf("Unmarshal(%q) succeeded, want too many elements error", mapOver)
+	}
+
+	// There is no limit by default.
+	if err := UnmarshalText(`ints: [1, 2, 3, 4]`, new(MoreRepeated)); err != nil {
+		t.Errorf("UnmarshalText: %v", err)
+	}
+}
+
+func TestLenientBoolParsing(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   bool
+		strict bool // accepted without LenientBool
+	}{
+		{"true", true, true},
+		{"True", true, true},
+		{"t", true, true},
+		{"1", true, true},
+		{"false", false, true},
+		{"False", false, true},
+		{"f", false, true},
+		{"0", false, true},
+		{"TRUE", true, false},
+		{"tRuE", true, false},
+		{"T", true, false},
+		{"FALSE", false, false},
+		{"fAlSe", false, false},
+		{"F", false, false},
+	}
+	lenient := TextUnmarshaler{LenientBool: true}
+	for _, tt := range tests {
+		in := "bools: " + tt.in + " bools: [" + tt.in + "]"
+		m := new(MoreRepeated)
+		if err := lenient.Unmarshal(in, m); err != nil {
+			t.Errorf("lenient Unmarshal(%q): %v", in, err)
+		} else if len(m.Bools) != 2 || m.Bools[0] != tt.want || m.Bools[1] != tt.want {
+			t.Errorf("lenient Unmarshal(%q) = %v, want two %v values", in, m.Bools, tt.want)
+		}
+		err := UnmarshalText(in, new(MoreRepeated))
+		if tt.strict && err != nil {
+			t.Errorf("UnmarshalText(%q): %v", in, err)
+		}
+		if !tt.strict && err == nil {
+			t.Errorf("UnmarshalText(%q) succeeded, want error without LenientBool", in)
+		}
+	}
+
+	for _, in := range []string{"bools: 123", "bools: yes", "bools: truthy", `bools: "true"`} {
+		if err := lenient.Unmarshal(in, new(MoreRepeated)); err == nil {
+			t.Errorf("lenient Unmarshal(%q) succeeded, want error", in)
+		}
+	}
+
+	// Bools are always written canonically.
+	m := &MoreRepeated{Bools: []bool{true, false}}
+	if got, want := CompactTextString(m), "bools:true bools:false "; got != want {
+		t.Errorf("CompactTextString = %q, want %q", got, want)
+	}
+}
+
 var benchInput string
 
 func init() {
@@ -176,6 +176,190 @@ func TestMarshalText(t *testing.T) {
 	}
 }
 
+func TestMarshalTextLineEnding(t *testing.T) {
+	tm := proto.TextMarshaler{LineEnding: "\r\n"}
+	buf := new(bytes.Buffer)
+	if err := tm.Marshal(buf, newTestMessage()); err != nil {
+		t.Fatalf("proto.TextMarshaler.Marshal: %v", err)
+	}
+	want := strings.Replace(text, "\n", "\r\n", -1)
+	if s := buf.String(); s != want {
+		t.Errorf("Got:\n===\n%q===\nExpected:\n===\n%q===\n", s, want)
+	}
+
+	// Round-trip a message without unknown fields.
+	in := &pb.MyMessage{
+		Count: proto.Int32(42),
+		Name:  proto.String("Dave\r\n"),
+		Inner: &pb.InnerMessage{Host: proto.String("footrest.syd")},
+		Somegroup: &pb.MyMessage_SomeGroup{
+			GroupField: proto.Int32(8),
+		},
+	}
+	s := tm.Text(in)
+	if strings.Contains(strings.Replace(s, "\r\n", "", -1), "\n") {
+		t.Errorf("output contains a bare LF: %q", s)
+	}
+	out := new(pb.MyMessage)
+	if err := proto.UnmarshalText(s, out); err != nil {
+		t.Fatalf("proto.UnmarshalText: %v", err)
+	}
+	if !proto.Equal(in, out) {
+		t.Errorf("Round-trip failed:\nstart: %v\n  end: %v", in, out)
+	}
+}
+
+func TestMarshalTextExtensionOrder(t *testing.T) {
+	msg := &pb.MyMessage{Count: proto.Int32(1)}
+	if err := proto.SetExtension(msg, pb.E_Greeting, []string{"hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.SetExtension(msg, pb.E_Ext_Number, proto.Int32(7)); err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.SetExtension(msg, pb.E_Ext_Text, proto.String("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.SetExtension(msg, pb.E_Ext_More, &pb.Ext{Data: proto.String("y")}); err != nil {
+		t.Fatal(err)
+	}
+	proto.SetRawExtension(msg, 201, []byte{0xc8, 0x0c, 0x01}) // varint 1
+
+	tests := []struct {
+		desc string
+		tm   proto.TextMarshaler
+		want string
+	}{{
+		desc: "by number",
+		tm:   proto.TextMarshaler{Compact: true},
+		want: `count:1 [test_proto.Ext.more]:<data:"y" > [test_proto.Ext.text]:"x" [test_proto.Ext.number]:7 [test_proto.greeting]:"hi" 201:1 `,
+	}, {
+		desc: "by name",
+		tm:   proto.TextMarshaler{Compact: true, SortExtensionsByName: true},
+		want: `count:1 [test_proto.Ext.more]:<data:"y" > [test_proto.Ext.number]:7 [test_proto.Ext.text]:"x" [test_proto.greeting]:"hi" 201:1 `,
+	}}
+	for _, tt := range tests {
+		if got := tt.tm.Text(msg); got != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalTextRepeatedFieldHeaders(t *testing.T) {
+	msg := &pb.MyMessage{
+		Count: proto.Int32(1),
+		Pet:   []string{"bunny", "kitty"},
+		RepInner: []*pb.InnerMessage{
+			{Host: proto.String("a")},
+			{Host: proto.String("b")},
+			{Host: proto.String("c")},
+		},
+		Others: []*pb.OtherMessage{{
+			Inner:  &pb.InnerMessage{Host: proto.String("d")},
+			Weight: proto.Float32(1),
+		}},
+	}
+	tm := proto.TextMarshaler{RepeatedFieldHeaders: true}
+	got := tm.Text(msg)
+	want := `count: 1
+# pet (2)
+pet: "bunny"
+pet: "kitty"
+# others (1)
+others: <
+  weight: 1
+  inner: <
+    host: "d"
+  >
+>
+# rep_inner (3)
+rep_inner: <
+  host: "a"
+>
+rep_inner: <
+  host: "b"
+>
+rep_inner: <
+  host: "c"
+>
+`
+	if got != want {
+		t.Errorf("Text:\n got %s\nwant %s", got, want)
+	}
+
+	parsed := new(pb.MyMessage)
+	if err := proto.UnmarshalText(got, parsed); err != nil {
+		t.Fatalf("UnmarshalText: %v", err)
+	}
+	if !proto.Equal(parsed, msg) {
+		t.Errorf("UnmarshalText = %v, want %v", parsed, msg)
+	}
+
+	// The headers are not written in the compact format.
+	tm.Compact = true
+	if got := tm.Text(msg); got != proto.CompactTextString(msg) {
+		t.Errorf("compact Text = %q, want %q", got, proto.CompactTextString(msg))
+	}
+}
+
+func TestMarshalTextAnnotateFieldNumbers(t *testing.T) {
+	msg := &pb.MyMessage{
+		Count: proto.Int32(1),
+		Pet:   []string{"bunny", "kitty"},
+		Inner: &pb.InnerMessage{Host: proto.String("h")},
+	}
+	if err := proto.SetExtension(msg, pb.E_Ext_Number, proto.Int32(5)); err != nil {
+		t.Fatal(err)
+	}
+	mapMsg := &pb.MessageWithMap{NameMapping: map[int32]string{1: "a"}}
+	oneofMsg := &pb.Communique{Union: &pb.Communique_Name{Name: "n"}}
+
+	tm := proto.TextMarshaler{AnnotateFieldNumbers: true}
+	for _, tt := range []struct {
+		m    proto.Message
+		want string
+	}{{
+		m: msg,
+		want: `count: 1 # 1
+pet: "bunny" # 4
+pet: "kitty" # 4
+inner: <
+  host: "h" # 1
+> # 5
+[test_proto.Ext.number]: 5 # 105
+`,
+	}, {
+		m: mapMsg,
+		want: `name_mapping: <
+  key: 1 # 1
+  value: "a" # 2
+> # 1
+`,
+	}, {
+		m:    oneofMsg,
+		want: "name: \"n\" # 6\n",
+	}} {
+		got := tm.Text(tt.m)
+		if got != tt.want {
+			t.Errorf("Text:\n got %s\nwant %s", got, tt.want)
+		}
+		parsed := proto.Clone(tt.m)
+		parsed.Reset()
+		if err := proto.UnmarshalText(got, parsed); err != nil {
+			t.Fatalf("UnmarshalText: %v", err)
+		}
+		if !proto.Equal(parsed, tt.m) {
+			t.Errorf("UnmarshalText = %v, want %v", parsed, tt.m)
+		}
+	}
+
+	// The annotations are not written in the compact format.
+	tm.Compact = true
+	if got := tm.Text(msg); got != proto.CompactTextString(msg) {
+		t.Errorf("compact Text = %q, want %q", got, proto.CompactTextString(msg))
+	}
+}
+
 func TestMarshalTextCustomMessage(t *testing.T) {
 	buf := new(bytes.Buffer)
 	if err := proto.MarshalText(buf, &textMessage{}); err != nil {
@@ -398,6 +582,7 @@ func TestFloats(t *testing.T) {
 		want string
 	}{
 		{0, "0"},
+		{math.Copysign(0, -1), "-0"},
 		{4.7, "4.7"},
 		{math.Inf(1), "inf"},
 		{math.Inf(-1), "-inf"},
@@ -413,6 +598,108 @@ func TestFloats(t *testing.T) {
 	}
 }
 
+func TestNegativeZeroRoundTrip(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	tests := []struct {
+		in       *pb.Defaults
+		want     string
+		negative bool
+	}{{
+		in:       &pb.Defaults{F_Float: proto.Float32(float32(negZero)), F_Double: proto.Float64(negZero)},
+		want:     "F_Float:-0 F_Double:-0 ",
+		negative: true,
+	}, {
+		in:   &pb.Defaults{F_Float: proto.Float32(0), F_Double: proto.Float64(0)},
+		want: "F_Float:0 F_Double:0 ",
+	}}
+	for _, tt := range tests {
+		s := proto.CompactTextString(tt.in)
+		if s != tt.want {
+			t.Errorf("CompactTextString(%v) = %q, want %q", tt.in, s, tt.want)
+		}
+		got := new(pb.Defaults)
+		if err := proto.UnmarshalText(s, got); err != nil {
+			t.Errorf("UnmarshalText(%q): %v", s, err)
+			continue
+		}
+		if got.F_Float == nil || got.F_Double == nil {
+			t.Errorf("UnmarshalText(%q) lost field presence: %v", s, got)
+			continue
+		}
+		if neg := math.Signbit(float64(*got.F_Float)); neg != tt.negative {
+			t.Errorf("UnmarshalText(%q): F_Float sign bit = %v, want %v", s, neg, tt.negative)
+		}
+		if neg := math.Signbit(*got.F_Double); neg != tt.negative {
+			t.Errorf("UnmarshalText(%q): F_Double sign bit = %v, want %v", s, neg, tt.negative)
+		}
+	}
+
+	// Other spellings of negative zero are parsed as negative zero.
+	for _, in := range []string{"F_Double: -0.0", "F_Double: -0e5", "F_Double: -0.0f"} {
+		got := new(pb.Defaults)
+		if err := proto.UnmarshalText(in, got); err != nil {
+			t.Errorf("UnmarshalText(%q): %v", in, err)
+			continue
+		}
+		if got.F_Double == nil || *got.F_Double != 0 || !math.Signbit(*got.F_Double) {
+			t.Errorf("UnmarshalText(%q) = %v, want negative zero", in, got.F_Double)
+		}
+	}
+}
+
+// Groups are keyed by their message name in the text format, not by the
+// lowercased field name, so that the output parses back.
+func TestGroupFieldNameText(t *testing.T) {
+	tests := []struct {
+		in   proto.Message
+		want string
+	}{{
+		in: &pb.MyMessage{
+			Count:     proto.Int32(1),
+			Somegroup: &pb.MyMessage_SomeGroup{GroupField: proto.Int32(8)},
+		},
+		want: "count:1 SomeGroup{group_field:8 } ",
+	}, {
+		in: &pb.MessageList{Message: []*pb.MessageList_Message{
+			{Name: proto.String("a"), Count: proto.Int32(1)},
+			{Name: proto.String("b"), Count: proto.Int32(2)},
+		}},
+		want: `Message{name:"a" count:1 } Message{name:"b" count:2 } `,
+	}, {
+		in:   &pb.Oneof{Union: &pb.Oneof_FGroup{FGroup: &pb.Oneof_F_Group{X: proto.Int32(3)}}},
+		want: "F_Group{x:3 } ",
+	}}
+	for _, tt := range tests {
+		s := proto.CompactTextString(tt.in)
+		if s != tt.want {
+			t.Errorf("CompactTextString(%v) = %q, want %q", tt.in, s, tt.want)
+		}
+		got := proto.Clone(tt.in)
+		got.Reset()
+		if err := proto.UnmarshalText(s, got); err != nil {
+			t.Errorf("UnmarshalText(%q): %v", s, err)
+			continue
+		}
+		if !proto.Equal(got, tt.in) {
+			t.Errorf("UnmarshalText(%q) = %v, want %v", s, got, tt.in)
+		}
+	}
+
+	// The lowercased field name is not accepted for groups.
+	for _, tt := range []struct {
+		in  string
+		msg proto.Message
+	}{
+		{"somegroup { group_field: 8 }", new(pb.MyMessage)},
+		{`message { name: "a" count: 1 }`, new(pb.MessageList)},
+		{"f_group { x: 3 }", new(pb.Oneof)},
+	} {
+		if err := proto.UnmarshalText(tt.in, tt.msg); err == nil {
+			t.Errorf("UnmarshalText(%q) succeeded, want error", tt.in)
+		}
+	}
+}
+
 func TestRepeatedNilText(t *testing.T) {
 	m := &pb.MessageList{
 		Message: []*pb.MessageList_Message{
@@ -516,3 +803,91 @@ func TestRacyMarshal(t *testing.T) {
 		}()
 	}
 }
+
+// invalidMapKeys has map fields whose key types are not legal in proto,
+// as if generated from a malformed descriptor.
+type invalidMapKeys struct {
+	FloatKey   map[float64]string         `protobuf:"bytes,1,rep,name=float_key,json=floatKey" protobuf_key:"fixed64,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	MessageKey map[*pb.InnerMessage]int32 `protobuf:"bytes,2,rep,name=message_key,json=messageKey" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+}
+
+func (*invalidMapKeys) Reset()         {}
+func (*invalidMapKeys) String() string { return "" }
+func (*invalidMapKeys) ProtoMessage()  {}
+
+func TestMapInvalidKeyType(t *testing.T) {
+	for _, m := range []*invalidMapKeys{
+		{FloatKey: map[float64]string{1.5: "a", 2.5: "b"}},
+		{MessageKey: map[*pb.InnerMessage]int32{{Host: proto.String("h")}: 1}},
+	} {
+		var buf bytes.Buffer
+		err := proto.MarshalText(&buf, m)
+		if err == nil || !strings.Contains(err.Error(), "invalid key type") {
+			t.Errorf("MarshalText(%+v) = %v, want invalid key type error", m, err)
+		}
+	}
+
+	for _, in := range []string{
+		`float_key: < key: 1.5 value: "a" >`,
+		`message_key: < key: < host: "h" > value: 1 >`,
+	} {
+		err := proto.UnmarshalText(in, new(invalidMapKeys))
+		if err == nil || !strings.Contains(err.Error(), "invalid key type") {
+			t.Errorf("UnmarshalText(%q) = %v, want invalid key type error", in, err)
+		}
+	}
+}
+
+// proto3Optional is laid out as protoc-gen-go generates a proto3 message
+// with optional (explicit presence) fields.
+type proto3Optional struct {
+	OptionalInt32  *int32  `protobuf:"varint,1,opt,name=optional_int32,json=optionalInt32,proto3,oneof"`
+	OptionalString *string `protobuf:"bytes,2,opt,name=optional_string,json=optionalString,proto3,oneof"`
+	OptionalBool   *bool   `protobuf:"varint,3,opt,name=optional_bool,json=optionalBool,proto3,oneof"`
+	PlainInt32     int32   `protobuf:"varint,4,opt,name=plain_int32,json=plainInt32,proto3"`
+}
+
+func (m *proto3Optional) Reset()         { *m = proto3Optional{} }
+func (m *proto3Optional) String() string { return proto.CompactTextString(m) }
+func (*proto3Optional) ProtoMessage()    {}
+
+func TestProto3OptionalText(t *testing.T) {
+	m := &proto3Optional{
+		OptionalInt32:  proto.Int32(0),
+		OptionalString: proto.String(""),
+		OptionalBool:   proto.Bool(false),
+	}
+	const want = `optional_int32:0 optional_string:"" optional_bool:false `
+	if got := proto.CompactTextString(m); got != want {
+		t.Errorf("CompactTextString(%#v) = %q, want %q", m, got, want)
+	}
+
+	got := new(proto3Optional)
+	if err := proto.UnmarshalText(want+"plain_int32: 0", got); err != nil {
+		t.Fatalf("UnmarshalText(%q): %v", want, err)
+	}
+	if got.OptionalInt32 == nil || got.OptionalString == nil || got.OptionalBool == nil {
+		t.Errorf("UnmarshalText(%q) = %#v, want optional fields present", want, got)
+	}
+	if !proto.Equal(got, m) {
+		t.Errorf("UnmarshalText(%q) = %v, want %v", want, got, m)
+	}
+
+	// Presence also survives the wire format.
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = new(proto3Optional)
+	if err := proto.Unmarshal(b, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.OptionalInt32 == nil || got.OptionalString == nil || got.OptionalBool == nil {
+		t.Errorf("Unmarshal(Marshal(%v)) = %#v, want optional fields present", m, got)
+	}
+
+	// Unset optional fields and implicit-presence zeros are omitted.
+	if got := proto.CompactTextString(&proto3Optional{}); got != "" {
+		t.Errorf("CompactTextString of empty message = %q, want empty", got)
+	}
+}
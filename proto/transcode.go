@@ -0,0 +1,73 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto
+
+// Functions for converting messages between the text and wire formats
+// without a generated type at the call site.
+
+// TextToBinary parses s as the text format of the registered message type
+// with the given fully-qualified name, like "pkg.Message", and returns the
+// wire encoding of the parsed message. Any messages written in expanded
+// form and extensions are resolved using the registered types.
+// As with UnmarshalText, missing required fields are reported with a
+// *RequiredNotSetError, in which case the wire encoding is still returned.
+func TextToBinary(s, name string) ([]byte, error) {
+	pb, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := UnmarshalText(s, pb); err != nil {
+		if _, ok := err.(*RequiredNotSetError); !ok {
+			return nil, err
+		}
+	}
+	return Marshal(pb)
+}
+
+// BinaryToText parses b as the wire encoding of the registered message type
+// with the given fully-qualified name and returns the message in text
+// format, as written by MarshalTextString. Missing required fields are
+// reported with a *RequiredNotSetError, in which case the text is still
+// returned.
+func BinaryToText(b []byte, name string) (string, error) {
+	pb, err := New(name)
+	if err != nil {
+		return "", err
+	}
+	if err := Unmarshal(b, pb); err != nil {
+		if _, ok := err.(*RequiredNotSetError); !ok {
+			return "", err
+		}
+		return MarshalTextString(pb), err
+	}
+	return MarshalTextString(pb), nil
+}
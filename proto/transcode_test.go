@@ -0,0 +1,130 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb3 "github.com/golang/protobuf/proto/proto3_proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
+	"github.com/golang/protobuf/ptypes"
+)
+
+func TestTranscode(t *testing.T) {
+	an, err := ptypes.MarshalAny(&pb3.Nested{Bunny: "Monty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext := &pb.MyMessage{Count: proto.Int32(1)}
+	if err := proto.SetExtension(ext, pb.E_Ext_More, &pb.Ext{Data: proto.String("more")}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []proto.Message{
+		&pb3.Message{
+			Name:         "Rob",
+			Hilarity:     pb3.Message_PUNS,
+			HeightInCm:   178,
+			Data:         []byte("roboto"),
+			ResultCount:  47,
+			TrueScotsman: true,
+			Score:        8.1,
+			Key:          []uint64{1, 2},
+			ShortKey:     []int32{-1},
+			Nested:       &pb3.Nested{Bunny: "Bugs"},
+			RFunny:       []pb3.Message_Humour{pb3.Message_SLAPSTICK},
+			Terrain:      map[string]*pb3.Nested{"t": {Cute: true}},
+			Anything:     an,
+		},
+		&pb3.MessageWithMap{ByteMapping: map[bool][]byte{true: []byte("x")}},
+		&pb3.IntMaps{Maps: []*pb3.IntMap{{Rtt: map[int32]int32{1: 2}}}},
+		ext,
+		&pb.Communique{Union: &pb.Communique_Msg{Msg: &pb.Strings{StringField: proto.String("s")}}},
+		&pb.GoTestRequiredGroupField{Group: &pb.GoTestRequiredGroupField_Group{Field: proto.Int32(3)}},
+	}
+	for _, want := range tests {
+		name := proto.MessageName(want)
+		b, err := proto.TextToBinary(proto.MarshalTextString(want), name)
+		if err != nil {
+			t.Errorf("TextToBinary(%v, %q): %v", want, name, err)
+			continue
+		}
+		got := proto.Clone(want)
+		got.Reset()
+		if err := proto.Unmarshal(b, got); err != nil {
+			t.Errorf("Unmarshal(TextToBinary(%v)): %v", want, err)
+		} else if !proto.Equal(got, want) {
+			t.Errorf("TextToBinary(%v) decodes to %v", want, got)
+		}
+
+		s, err := proto.BinaryToText(b, name)
+		if err != nil {
+			t.Errorf("BinaryToText(%v): %v", want, err)
+		} else if s != proto.MarshalTextString(want) {
+			t.Errorf("BinaryToText(%v) = %q, want %q", want, s, proto.MarshalTextString(want))
+		}
+	}
+}
+
+func TestTranscodeErrors(t *testing.T) {
+	if _, err := proto.TextToBinary(`name: "x"`, "no.such.Message"); err == nil {
+		t.Error("TextToBinary with an unknown type succeeded")
+	}
+	if _, err := proto.BinaryToText(nil, "no.such.Message"); err == nil {
+		t.Error("BinaryToText with an unknown type succeeded")
+	}
+	for _, name := range []string{"test_proto.MessageWithMap.ByteMappingEntry", "test_proto.FOO"} {
+		if _, err := proto.BinaryToText(nil, name); err == nil || !strings.Contains(err.Error(), "not a message type") {
+			t.Errorf("BinaryToText(nil, %q) = %v, want a not a message type error", name, err)
+		}
+	}
+	if _, err := proto.TextToBinary(`no_such_field: 1`, "proto3_proto.Message"); err == nil {
+		t.Error("TextToBinary with an unknown field succeeded")
+	}
+	if _, err := proto.BinaryToText([]byte{0x0a}, "proto3_proto.Message"); err == nil {
+		t.Error("BinaryToText with truncated input succeeded")
+	}
+
+	// Missing required fields are reported, but the result is returned.
+	b, err := proto.TextToBinary(`name: "x"`, "test_proto.MyMessage")
+	if _, ok := err.(*proto.RequiredNotSetError); !ok {
+		t.Errorf("TextToBinary with a missing required field: error = %v, want *RequiredNotSetError", err)
+	}
+	s, err := proto.BinaryToText(b, "test_proto.MyMessage")
+	if _, ok := err.(*proto.RequiredNotSetError); !ok {
+		t.Errorf("BinaryToText with a missing required field: error = %v, want *RequiredNotSetError", err)
+	}
+	if want := "name: \"x\"\n"; s != want {
+		t.Errorf("BinaryToText with a missing required field = %q, want %q", s, want)
+	}
+}
@@ -0,0 +1,446 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxValidateDepth is the deepest nesting of messages that Validate accepts.
+const maxValidateDepth = 10000
+
+// Validate checks that b is a valid wire format encoding of a message of
+// the same type as m, without decoding it. It makes the checks Unmarshal
+// makes: the fields are well formed, string fields of proto3 messages are
+// valid UTF-8, and the required fields of m's message and of each message
+// nested in it are present. Messages nested more than 10000 deep are
+// rejected. m is only used for its type and is not modified.
+//
+// A nil error means that Unmarshal of b into a message of m's type
+// succeeds. Otherwise the error is the one Unmarshal would report, except
+// for the nesting limit, which Unmarshal does not have.
+//
+// unknown reports whether b holds fields that Unmarshal keeps as unknown
+// fields: fields with a number the message does not define, fields whose
+// wire type does not match their definition, and extensions that are not
+// registered.
+func Validate(b []byte, m Message) (unknown bool, err error) {
+	t := reflect.TypeOf(m)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return false, errors.New("proto: Validate called with nil")
+	}
+	err = getValidateInfo(t.Elem()).validate(b, 0, &unknown)
+	return unknown, err
+}
+
+// validateInfo holds the fields of a message type needed to validate its
+// encoding. The fields are computed on first use.
+type validateInfo struct {
+	typ  reflect.Type // type of the message (not pointer to message)
+	once sync.Once
+
+	// fallback is set for message sets and messages that are not generated
+	// structs, which are validated by unmarshaling them.
+	fallback bool
+
+	dense     []*validateField          // fields indexed by small field numbers
+	sparse    map[uint64]*validateField // fields with large field numbers
+	reqFields []string                  // names of required fields
+	reqMask   uint64                    // 1<<len(reqFields)-1
+
+	extensionRanges []ExtensionRange
+}
+
+// validateField describes how to validate one field of a message.
+type validateField struct {
+	name    string // original name, for error messages
+	wire    int    // wire type of a single value
+	packed  bool   // the field may also be encoded as a packed list
+	utf8    bool   // string values must be valid UTF-8
+	int32   bool   // varint values must fit in an int32
+	uint32  bool   // varint values must fit in a uint32
+	zigzag  bool   // varint values are zigzag encoded
+	reqMask uint64 // bit of the field in the required field mask
+
+	sub      *validateInfo  // message or group values
+	key, val *validateField // set for map fields only
+}
+
+var (
+	newUnmarshalerType = reflect.TypeOf((*newUnmarshaler)(nil)).Elem()
+	unmarshalerType    = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+	validateInfoMap  = map[reflect.Type]*validateInfo{}
+	validateInfoLock sync.RWMutex
+)
+
+// getValidateInfo returns the validateInfo for the message type t
+// (not pointer to message).
+func getValidateInfo(t reflect.Type) *validateInfo {
+	validateInfoLock.RLock()
+	u := validateInfoMap[t]
+	validateInfoLock.RUnlock()
+	if u != nil {
+		return u
+	}
+
+	validateInfoLock.Lock()
+	defer validateInfoLock.Unlock()
+	u = validateInfoMap[t]
+	if u == nil {
+		u = &validateInfo{typ: t}
+		validateInfoMap[t] = u
+	}
+	return u
+}
+
+// computeValidateInfo fills in u from the struct fields of its type.
+func (u *validateInfo) computeValidateInfo() {
+	t := u.typ
+	pt := reflect.PtrTo(t)
+	if t.Kind() != reflect.Struct || (!pt.Implements(newUnmarshalerType) && pt.Implements(unmarshalerType)) {
+		u.fallback = true
+		return
+	}
+	if f, ok := t.FieldByName("XXX_InternalExtensions"); ok && f.Tag.Get("protobuf_messageset") == "1" {
+		u.fallback = true
+		return
+	}
+
+	fields := map[uint64]*validateField{}
+	sprop := GetProperties(t)
+	for i, p := range sprop.Prop {
+		if p.Tag <= 0 {
+			continue // not a protobuf field, or a oneof
+		}
+		f := newValidateField(p, t.Field(i).Type)
+		if p.Required {
+			bit := len(u.reqFields)
+			u.reqFields = append(u.reqFields, p.OrigName)
+			f.reqMask = uint64(1) << uint(bit)
+		}
+		fields[uint64(p.Tag)] = f
+	}
+	for _, op := range sprop.OneofTypes {
+		fields[uint64(op.Prop.Tag)] = newValidateField(op.Prop, op.Type.Elem().Field(0).Type)
+	}
+	u.reqMask = uint64(1)<<uint(len(u.reqFields)) - 1
+
+	u.sparse = map[uint64]*validateField{}
+	for tag, f := range fields {
+		if tag < 1024 {
+			for uint64(len(u.dense)) <= tag {
+				u.dense = append(u.dense, nil)
+			}
+			u.dense[tag] = f
+		} else {
+			u.sparse[tag] = f
+		}
+	}
+
+	if e, ok := reflect.Zero(pt).Interface().(interface{ ExtensionRangeArray() []ExtensionRange }); ok {
+		u.extensionRanges = e.ExtensionRangeArray()
+	}
+}
+
+// newValidateField returns the validateField for a field with
+// properties p and Go type t.
+func newValidateField(p *Properties, t reflect.Type) *validateField {
+	f := &validateField{name: p.OrigName, wire: p.WireType}
+	if p.Wire == "group" {
+		f.wire = WireStartGroup
+	}
+	if t.Kind() == reflect.Map {
+		f.key = newValidateField(p.MapKeyProp, t.Key())
+		f.val = newValidateField(p.MapValProp, t.Elem())
+		return f
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+		switch f.wire {
+		case WireVarint, WireFixed32, WireFixed64:
+			f.packed = true
+		}
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		f.sub = getValidateInfo(t)
+	case reflect.String:
+		f.utf8 = p.proto3
+	case reflect.Int32:
+		f.int32 = f.wire == WireVarint
+		f.zigzag = p.Wire == "zigzag32"
+	case reflect.Uint32:
+		f.uint32 = f.wire == WireVarint
+	}
+	return f
+}
+
+// validate validates b as the encoding of a message of type u nested depth
+// deep, setting *unknown if b holds unknown fields.
+func (u *validateInfo) validate(b []byte, depth int, unknown *bool) error {
+	u.once.Do(u.computeValidateInfo)
+	if u.fallback {
+		return Unmarshal(b, reflect.New(u.typ).Interface().(Message))
+	}
+	if depth > maxValidateDepth {
+		return fmt.Errorf("proto: %s: exceeded maximum nesting depth of %d", u.typ, maxValidateDepth)
+	}
+	var reqMask uint64 // bitmask of required fields we've seen.
+	var errLater error
+	for len(b) > 0 {
+		x, n := decodeVarint(b)
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		b = b[n:]
+		tag := x >> 3
+		wire := int(x) & 7
+		if tag == 0 {
+			return fmt.Errorf("proto: %s: illegal tag 0 (wire type %d)", u.typ, wire)
+		}
+
+		var f *validateField
+		if tag < uint64(len(u.dense)) {
+			f = u.dense[tag]
+		} else {
+			f = u.sparse[tag]
+		}
+		if f != nil {
+			rest, err := f.validate(b, wire, depth, unknown)
+			if err == nil {
+				reqMask |= f.reqMask
+				b = rest
+				continue
+			}
+			if r, ok := err.(*RequiredNotSetError); ok {
+				if errLater == nil {
+					errLater = r
+				}
+				reqMask |= f.reqMask
+				b = rest
+				continue
+			}
+			if err != errInternalBadWireType {
+				if err == errInvalidUTF8 {
+					if errLater == nil {
+						fullName := revProtoTypes[reflect.PtrTo(u.typ)] + "." + f.name
+						errLater = &invalidUTF8Error{fullName}
+					}
+					b = rest
+					continue
+				}
+				return err
+			}
+			// Fragments with bad wire type are treated as unknown fields.
+		}
+
+		if f != nil || !u.isRegisteredExtension(tag) {
+			*unknown = true
+		}
+		var err error
+		if b, err = skipField(b, wire); err != nil {
+			return err
+		}
+	}
+	if reqMask != u.reqMask && errLater == nil {
+		// A required field of this message is missing.
+		for _, n := range u.reqFields {
+			if reqMask&1 == 0 {
+				errLater = &RequiredNotSetError{n}
+			}
+			reqMask >>= 1
+		}
+	}
+	return errLater
+}
+
+// isRegisteredExtension reports whether tag is the field number
+// of an extension of u that is registered.
+func (u *validateInfo) isRegisteredExtension(tag uint64) bool {
+	for _, r := range u.extensionRanges {
+		if uint64(r.Start) <= tag && tag <= uint64(r.End) {
+			return extensionMaps[u.typ][int32(tag)] != nil
+		}
+	}
+	return false
+}
+
+// validate validates one value of f with wire type wire at the start of b,
+// in a message nested depth deep. It returns the rest of b, and
+// errInternalBadWireType if the wire type does not match the field.
+func (f *validateField) validate(b []byte, wire, depth int, unknown *bool) ([]byte, error) {
+	if f.key != nil {
+		return f.validateMapEntry(b, wire, depth, unknown)
+	}
+	if wire != f.wire {
+		if f.packed && wire == WireBytes {
+			return f.validatePacked(b)
+		}
+		return b, errInternalBadWireType
+	}
+	switch wire {
+	case WireBytes:
+		x, n := decodeVarint(b)
+		if n == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b = b[n:]
+		if x > uint64(len(b)) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if f.sub != nil {
+			return b[x:], f.subError(f.sub.validate(b[:x], depth+1, unknown))
+		}
+		if f.utf8 && !utf8.Valid(b[:x]) {
+			return b[x:], errInvalidUTF8
+		}
+		return b[x:], nil
+	case WireStartGroup:
+		x, y := findEndGroup(b)
+		if x < 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return b[y:], f.subError(f.sub.validate(b[:x], depth+1, unknown))
+	}
+	return f.validateScalar(b)
+}
+
+// validateScalar validates a single varint or fixed-size value of f.
+func (f *validateField) validateScalar(b []byte) ([]byte, error) {
+	if f.wire != WireVarint {
+		b, err := skipField(b, f.wire)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	x, n := decodeVarint(b)
+	if n == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if f.int32 && (f.zigzag && x > math.MaxUint32 || !f.zigzag && int64(int32(x)) != int64(x)) {
+		return nil, errOverflow
+	}
+	if f.uint32 && x > math.MaxUint32 {
+		return nil, errOverflow
+	}
+	return b[n:], nil
+}
+
+// validatePacked validates a packed list of values of f.
+func (f *validateField) validatePacked(b []byte) ([]byte, error) {
+	x, n := decodeVarint(b)
+	if n == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b = b[n:]
+	if x > uint64(len(b)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	for v := b[:x]; len(v) > 0; {
+		var err error
+		if v, err = f.validateScalar(v); err != nil {
+			return nil, err
+		}
+	}
+	return b[x:], nil
+}
+
+// validateMapEntry validates a map entry of the map field f.
+func (f *validateField) validateMapEntry(b []byte, wire, depth int, unknown *bool) ([]byte, error) {
+	if wire != WireBytes {
+		return nil, fmt.Errorf("proto: bad wiretype for map field: got %d want %d", wire, WireBytes)
+	}
+	x, n := decodeVarint(b)
+	if n == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b = b[n:]
+	if x > uint64(len(b)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	r := b[x:] // unused data to return
+	b = b[:x]  // data for map entry
+
+	var nerr nonFatal
+	for len(b) > 0 {
+		x, n := decodeVarint(b)
+		if n == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		wire := int(x) & 7
+		b = b[n:]
+
+		var err error
+		switch x >> 3 {
+		case 1:
+			b, err = f.key.validate(b, wire, depth, unknown)
+		case 2:
+			b, err = f.val.validate(b, wire, depth, unknown)
+		default:
+			err = errInternalBadWireType // skip unknown tag
+		}
+
+		if nerr.Merge(err) {
+			continue
+		}
+		if err != errInternalBadWireType {
+			return nil, err
+		}
+
+		// Skip past unknown fields.
+		b, err = skipField(b, wire)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nerr.E
+}
+
+// subError qualifies a RequiredNotSetError from a message value of f
+// with the name of f, as Unmarshal does.
+func (f *validateField) subError(err error) error {
+	if r, ok := err.(*RequiredNotSetError); ok {
+		return &RequiredNotSetError{f.name + "." + r.field}
+	}
+	return err
+}
@@ -0,0 +1,305 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SkipValue is used as a return value from a WalkFunc to indicate that
+// the children of the current value are not to be visited.
+// The Post function is not called for a skipped value.
+var SkipValue = errors.New("proto: skip this value")
+
+// StopWalk is used as a return value from a WalkFunc to indicate that
+// the walk should end. Walk returns nil in that case.
+var StopWalk = errors.New("proto: stop walk")
+
+// A WalkStep is a single step in a WalkPath.
+// Exactly one of Field, Index, MapKey or Unknown is set.
+type WalkStep struct {
+	Field   *Properties   // the field entered, or nil
+	Index   int           // the list index entered, or -1
+	MapKey  reflect.Value // the map key entered, if valid
+	Unknown bool          // whether the unknown fields were entered
+}
+
+// A WalkPath is the sequence of steps from the root message to a value.
+// The root message itself has an empty path.
+type WalkPath []WalkStep
+
+// String formats the path using the original .proto field names,
+// like "opt_nested.rpt_nested[2].opt_string".
+// Unknown fields are written as "<unknown>".
+func (p WalkPath) String() string {
+	var b bytes.Buffer
+	for _, s := range p {
+		switch {
+		case s.Field != nil:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(s.Field.OrigName)
+		case s.MapKey.IsValid():
+			if s.MapKey.Kind() == reflect.String {
+				fmt.Fprintf(&b, "[%q]", s.MapKey.String())
+			} else {
+				fmt.Fprintf(&b, "[%v]", s.MapKey.Interface())
+			}
+		case s.Unknown:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString("<unknown>")
+		default:
+			fmt.Fprintf(&b, "[%d]", s.Index)
+		}
+	}
+	return b.String()
+}
+
+// WalkFunc is the type of the function called for each value visited by Walk.
+//
+// The value is the struct field, list element or map value itself, so that
+// field values and list elements may be changed through it. Map values are
+// not addressable; use the parent map value to change them.
+// Messages are passed as pointers to their generated structs.
+//
+// The path must not be retained after the function returns.
+type WalkFunc func(path WalkPath, v reflect.Value) error
+
+// A Walker visits every populated value in a message tree:
+// the message itself, its fields, list elements and map values,
+// recursing into nested messages.
+// Fields are visited in the order they are declared in the generated struct,
+// and map entries in key order. Extensions are not visited.
+type Walker struct {
+	Pre  WalkFunc // called before the children of a value, if non-nil
+	Post WalkFunc // called after the children of a value, if non-nil
+
+	// Unknown reports whether the unknown fields of each message are visited,
+	// as a []byte value, after its known fields.
+	Unknown bool
+}
+
+// Walk visits the values in pb, calling Pre for each value before its
+// children and Post after them.
+// If either function returns an error other than SkipValue or StopWalk,
+// the walk stops and that error is returned.
+func (w *Walker) Walk(pb Message) error {
+	v := reflect.ValueOf(pb)
+	if pb == nil || v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	err := w.visit(nil, v)
+	if err == StopWalk {
+		err = nil
+	}
+	return err
+}
+
+// Walk visits the values in pb in pre-order, calling f for each of them.
+// See Walker for details.
+func Walk(pb Message, f WalkFunc) error {
+	w := Walker{Pre: f}
+	return w.Walk(pb)
+}
+
+// WalkMessages calls f for each message nested in pb, in depth-first order,
+// with the path from pb to the message. This includes the messages in
+// message fields, in the elements of repeated message fields and in the
+// values of maps, but not pb itself. The traversal ends when f returns
+// false. The path must not be retained after f returns.
+func WalkMessages(pb Message, f func(path WalkPath, m Message) bool) {
+	Walk(pb, func(path WalkPath, v reflect.Value) error {
+		if len(path) == 0 || v.Kind() != reflect.Ptr || v.IsNil() {
+			return nil
+		}
+		m, ok := v.Interface().(Message)
+		if ok && !f(path, m) {
+			return StopWalk
+		}
+		return nil
+	})
+}
+
+// ForEachField calls f for each populated field of pb in ascending field
+// number order, until f returns false. It does not descend into nested
+// messages or visit extensions and unknown fields.
+//
+// The value is the struct field itself, as for a WalkFunc: for example,
+// a proto2 optional int32 is passed as a *int32, and a set member of
+// a oneof is passed as the value of that member with its own properties.
+func ForEachField(pb Message, f func(prop *Properties, v reflect.Value) bool) {
+	v := reflect.ValueOf(pb)
+	if pb == nil || v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+	var fields []WalkStep
+	var values []reflect.Value
+	w := Walker{Pre: func(path WalkPath, v reflect.Value) error {
+		if len(path) == 0 {
+			return nil
+		}
+		fields = append(fields, path[0])
+		values = append(values, v)
+		return SkipValue
+	}}
+	w.Walk(pb)
+
+	order := make([]int, len(fields))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return fields[order[i]].Field.Tag < fields[order[j]].Field.Tag
+	})
+	for _, i := range order {
+		if !f(fields[i].Field, values[i]) {
+			return
+		}
+	}
+}
+
+func (w *Walker) visit(path WalkPath, v reflect.Value) error {
+	if w.Pre != nil {
+		switch err := w.Pre(path, v); err {
+		case nil:
+		case SkipValue:
+			return nil
+		default:
+			return err
+		}
+	}
+
+	switch {
+	case v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct:
+		if err := w.visitFields(path, v.Elem()); err != nil {
+			return err
+		}
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < v.Len(); i++ {
+			if err := w.visit(append(path, WalkStep{Index: i}), v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case v.Kind() == reflect.Map:
+		keys := v.MapKeys()
+		sort.Sort(mapKeys(keys))
+		for _, k := range keys {
+			if err := w.visit(append(path, WalkStep{Index: -1, MapKey: k}), v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if w.Post != nil {
+		if err := w.Post(path, v); err != nil && err != SkipValue {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Walker) visitFields(path WalkPath, sv reflect.Value) error {
+	st := sv.Type()
+	sprop := GetProperties(st)
+	for i := 0; i < sv.NumField(); i++ {
+		f := st.Field(i)
+		fv := sv.Field(i)
+		if strings.HasPrefix(f.Name, "XXX_") {
+			if f.Name == "XXX_unrecognized" && w.Unknown && fv.Len() > 0 {
+				if err := w.visit(append(path, WalkStep{Index: -1, Unknown: true}), fv); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		prop := sprop.Prop[i]
+		if f.Tag.Get("protobuf_oneof") != "" {
+			// The field is an interface holding a pointer to a wrapper struct
+			// whose only field is the value of the set case.
+			if fv.IsNil() {
+				continue
+			}
+			wv := fv.Elem()
+			prop = nil
+			for _, oop := range sprop.OneofTypes {
+				if oop.Type == wv.Type() {
+					prop = oop.Prop
+					break
+				}
+			}
+			if prop == nil {
+				continue
+			}
+			fv = wv.Elem().Field(0)
+		} else if f.Tag.Get("protobuf") == "" || !populated(fv, prop) {
+			continue
+		}
+		if err := w.visit(append(path, WalkStep{Field: prop, Index: -1}), fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// populated reports whether the field value v is set.
+func populated(v reflect.Value, prop *Properties) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil()
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && !prop.proto3 {
+			// A proto2 bytes field is set if it is non-nil.
+			return !v.IsNil()
+		}
+		return v.Len() > 0
+	case reflect.Map, reflect.String:
+		return v.Len() > 0
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int32, reflect.Int64:
+		return v.Int() != 0
+	case reflect.Uint32, reflect.Uint64:
+		return v.Uint() != 0
+	case reflect.Float32, reflect.Float64:
+		return math.Float64bits(v.Float()) != 0
+	}
+	return true
+}
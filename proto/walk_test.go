@@ -0,0 +1,408 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package proto_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	proto3pb "github.com/golang/protobuf/proto/proto3_proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
+)
+
+// walkPaths returns the paths visited by w on m, with "pre " or "post "
+// prefixes when both functions are requested.
+func walkPaths(t *testing.T, m proto.Message, pre, post bool, unknown bool) []string {
+	var got []string
+	w := proto.Walker{Unknown: unknown}
+	if pre {
+		w.Pre = func(p proto.WalkPath, v reflect.Value) error {
+			got = append(got, prefix(pre && post, "pre ")+p.String())
+			return nil
+		}
+	}
+	if post {
+		w.Post = func(p proto.WalkPath, v reflect.Value) error {
+			got = append(got, prefix(pre && post, "post ")+p.String())
+			return nil
+		}
+	}
+	if err := w.Walk(m); err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	return got
+}
+
+func prefix(ok bool, s string) string {
+	if ok {
+		return s
+	}
+	return ""
+}
+
+func TestWalkPaths(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   proto.Message
+		want []string
+	}{{
+		desc: "proto2",
+		in: &pb.MyMessage{
+			Count: proto.Int32(42),
+			Pet:   []string{"bunny", "kitty"},
+			Inner: &pb.InnerMessage{Host: proto.String("h")},
+			Others: []*pb.OtherMessage{
+				{Key: proto.Int64(1)},
+				{Inner: &pb.InnerMessage{Port: proto.Int32(1)}},
+			},
+			Somegroup: &pb.MyMessage_SomeGroup{GroupField: proto.Int32(8)},
+			RepBytes:  [][]byte{nil},
+		},
+		want: []string{
+			"",
+			"count",
+			"pet",
+			"pet[0]",
+			"pet[1]",
+			"inner",
+			"inner.host",
+			"others",
+			"others[0]",
+			"others[0].key",
+			"others[1]",
+			"others[1].inner",
+			"others[1].inner.port",
+			"SomeGroup",
+			"SomeGroup.group_field",
+			"rep_bytes",
+			"rep_bytes[0]",
+		},
+	}, {
+		desc: "proto3",
+		in: &proto3pb.Message{
+			HeightInCm: 0,
+			Score:      1.5,
+			Data:       []byte{},
+			Terrain: map[string]*proto3pb.Nested{
+				"b": {Cute: true},
+				"a": {},
+			},
+			Children: []*proto3pb.Message{{}, {Name: "c"}},
+		},
+		want: []string{
+			"",
+			"score",
+			`terrain`,
+			`terrain["a"]`,
+			`terrain["b"]`,
+			`terrain["b"].cute`,
+			"children",
+			"children[0]",
+			"children[1]",
+			"children[1].name",
+		},
+	}, {
+		desc: "int map",
+		in:   &pb.MessageWithMap{NameMapping: map[int32]string{2: "b", 1: "a"}},
+		want: []string{"", "name_mapping", "name_mapping[1]", "name_mapping[2]"},
+	}, {
+		desc: "oneof",
+		in:   &pb.Communique{Union: &pb.Communique_Msg{&pb.Strings{StringField: proto.String("s")}}},
+		want: []string{"", "msg", "msg.string_field"},
+	}}
+	for _, tt := range tests {
+		got := walkPaths(t, tt.in, true, false, false)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestWalkOrder(t *testing.T) {
+	m := &pb.MyMessage{
+		Count: proto.Int32(1),
+		Inner: &pb.InnerMessage{Host: proto.String("h")},
+	}
+	got := walkPaths(t, m, true, true, false)
+	want := []string{
+		"pre ",
+		"pre count",
+		"post count",
+		"pre inner",
+		"pre inner.host",
+		"post inner.host",
+		"post inner",
+		"post ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	got = walkPaths(t, m, false, true, false)
+	want = []string{"count", "inner.host", "inner", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("post-order: got %q\nwant %q", got, want)
+	}
+}
+
+func TestWalkSkipAndStop(t *testing.T) {
+	m := &pb.MyMessage{
+		Count:  proto.Int32(1),
+		Inner:  &pb.InnerMessage{Host: proto.String("h")},
+		Others: []*pb.OtherMessage{{Key: proto.Int64(1)}},
+	}
+
+	var got []string
+	err := proto.Walk(m, func(p proto.WalkPath, v reflect.Value) error {
+		got = append(got, p.String())
+		if p.String() == "inner" {
+			return proto.SkipValue
+		}
+		return nil
+	})
+	want := []string{"", "count", "inner", "others", "others[0]", "others[0].key"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("SkipValue: got %q, %v\nwant %q, nil", got, err, want)
+	}
+
+	got = nil
+	err = proto.Walk(m, func(p proto.WalkPath, v reflect.Value) error {
+		got = append(got, p.String())
+		if p.String() == "inner.host" {
+			return proto.StopWalk
+		}
+		return nil
+	})
+	want = []string{"", "count", "inner", "inner.host"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("StopWalk: got %q, %v\nwant %q, nil", got, err, want)
+	}
+
+	errFail := errors.New("fail")
+	err = proto.Walk(m, func(p proto.WalkPath, v reflect.Value) error {
+		if p.String() == "count" {
+			return errFail
+		}
+		return nil
+	})
+	if err != errFail {
+		t.Errorf("Walk returned %v, want %v", err, errFail)
+	}
+}
+
+func TestWalkMutate(t *testing.T) {
+	m := &pb.MyMessage{
+		Count: proto.Int32(1),
+		Name:  proto.String("dave"),
+		Pet:   []string{"bunny"},
+		Inner: &pb.InnerMessage{Host: proto.String("host")},
+	}
+	err := proto.Walk(m, func(p proto.WalkPath, v reflect.Value) error {
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString(strings.ToUpper(v.String()))
+		case reflect.Ptr:
+			if v.Elem().Kind() == reflect.String {
+				v.Elem().SetString(strings.ToUpper(v.Elem().String()))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	want := &pb.MyMessage{
+		Count: proto.Int32(1),
+		Name:  proto.String("DAVE"),
+		Pet:   []string{"BUNNY"},
+		Inner: &pb.InnerMessage{Host: proto.String("HOST")},
+	}
+	if !proto.Equal(m, want) {
+		t.Errorf("got %v, want %v", m, want)
+	}
+}
+
+func TestWalkUnknown(t *testing.T) {
+	m := &pb.MyMessage{
+		Count: proto.Int32(1),
+		Inner: &pb.InnerMessage{
+			Host:             proto.String("h"),
+			XXX_unrecognized: []byte{0xa0, 0x01, 0x01}, // field 20, varint 1
+		},
+	}
+	got := walkPaths(t, m, true, false, true)
+	want := []string{"", "count", "inner", "inner.host", "inner.<unknown>"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+	got = walkPaths(t, m, true, false, false)
+	want = []string{"", "count", "inner", "inner.host"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("without Unknown: got %q\nwant %q", got, want)
+	}
+}
+
+func TestForEachField(t *testing.T) {
+	m := &proto3pb.Message{
+		Name:     "name",
+		Score:    1.5,
+		Key:      []uint64{1},
+		ShortKey: []int32{2},
+		Nested:   &proto3pb.Nested{Bunny: "bunny"},
+		RFunny:   []proto3pb.Message_Humour{proto3pb.Message_PUNS},
+	}
+	want := map[int32]interface{}{
+		1:  m.Name,
+		5:  m.Key,
+		6:  m.Nested,
+		9:  m.Score,
+		16: m.RFunny,
+		19: m.ShortKey,
+	}
+	var nums []int32
+	proto.ForEachField(m, func(prop *proto.Properties, v reflect.Value) bool {
+		nums = append(nums, int32(prop.Tag))
+		if w := want[int32(prop.Tag)]; !reflect.DeepEqual(v.Interface(), w) {
+			t.Errorf("field %s = %v, want %v", prop.OrigName, v.Interface(), w)
+		}
+		return true
+	})
+	if wantNums := []int32{1, 5, 6, 9, 16, 19}; !reflect.DeepEqual(nums, wantNums) {
+		t.Errorf("ForEachField visited fields %v, want %v", nums, wantNums)
+	}
+
+	// Returning false stops the iteration.
+	nums = nil
+	proto.ForEachField(m, func(prop *proto.Properties, v reflect.Value) bool {
+		nums = append(nums, int32(prop.Tag))
+		return len(nums) < 2
+	})
+	if wantNums := []int32{1, 5}; !reflect.DeepEqual(nums, wantNums) {
+		t.Errorf("stopped ForEachField visited fields %v, want %v", nums, wantNums)
+	}
+
+	// Oneof members are visited with their own properties.
+	c := &pb.Communique{MakeMeCry: proto.Bool(true), Union: &pb.Communique_Number{Number: 0}}
+	var names []string
+	var vals []interface{}
+	proto.ForEachField(c, func(prop *proto.Properties, v reflect.Value) bool {
+		names = append(names, prop.OrigName)
+		vals = append(vals, v.Interface())
+		return true
+	})
+	if want := []string{"make_me_cry", "number"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ForEachField on oneof visited %q, want %q", names, want)
+	}
+	if want := []interface{}{c.MakeMeCry, int32(0)}; !reflect.DeepEqual(vals, want) {
+		t.Errorf("ForEachField on oneof produced values %v, want %v", vals, want)
+	}
+
+	for _, m := range []proto.Message{&proto3pb.Message{}, &pb.GoTest{}, (*pb.GoTest)(nil), nil} {
+		proto.ForEachField(m, func(prop *proto.Properties, v reflect.Value) bool {
+			t.Errorf("ForEachField(%T) visited field %s of an empty message", m, prop.OrigName)
+			return true
+		})
+	}
+}
+
+func TestWalkMessages(t *testing.T) {
+	deepest := &proto3pb.Nested{Bunny: "deep"}
+	m := &proto3pb.Message{
+		Name:   "root",
+		Nested: &proto3pb.Nested{Bunny: "bunny"},
+		Terrain: map[string]*proto3pb.Nested{
+			"b": {Cute: true},
+			"a": {},
+		},
+		Submessage: &proto3pb.Message{
+			Submessage: &proto3pb.Message{Nested: deepest},
+		},
+		Children: []*proto3pb.Message{
+			{Name: "first"},
+			{Nested: &proto3pb.Nested{}},
+		},
+	}
+	want := []string{
+		"nested",
+		`terrain["a"]`,
+		`terrain["b"]`,
+		"submessage",
+		"submessage.submessage",
+		"submessage.submessage.nested",
+		"children[0]",
+		"children[1]",
+		"children[1].nested",
+	}
+	var got []string
+	proto.WalkMessages(m, func(path proto.WalkPath, sub proto.Message) bool {
+		got = append(got, path.String())
+		switch path.String() {
+		case "submessage.submessage.nested":
+			if sub != deepest {
+				t.Errorf("WalkMessages passed %v for %s, want %v", sub, path, deepest)
+			}
+		case `terrain["b"]`:
+			if sub != m.Terrain["b"] {
+				t.Errorf("WalkMessages passed %v for %s, want %v", sub, path, m.Terrain["b"])
+			}
+		case "children[0]":
+			if sub != m.Children[0] {
+				t.Errorf("WalkMessages passed %v for %s, want %v", sub, path, m.Children[0])
+			}
+		}
+		return true
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkMessages visited:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Returning false stops the traversal.
+	got = nil
+	proto.WalkMessages(m, func(path proto.WalkPath, sub proto.Message) bool {
+		got = append(got, path.String())
+		return path.String() != "submessage"
+	})
+	if !reflect.DeepEqual(got, want[:4]) {
+		t.Errorf("stopped WalkMessages visited %q, want %q", got, want[:4])
+	}
+
+	for _, m := range []proto.Message{&proto3pb.Message{Name: "leaf"}, (*proto3pb.Message)(nil), nil} {
+		proto.WalkMessages(m, func(path proto.WalkPath, sub proto.Message) bool {
+			t.Errorf("WalkMessages(%v) visited %s", m, path)
+			return true
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package protobase64_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/golang/protobuf/proto/proto3_proto"
+	"github.com/golang/protobuf/protobase64"
+)
+
+func TestRoundTrip(t *testing.T) {
+	msgs := []*pb.Message{
+		{},
+		{Name: "a"},
+		{Name: "bc"},
+		{Name: "def", Hilarity: pb.Message_PUNS},
+		// Bytes that encode to '-' and '_' in the URL-safe alphabet.
+		{Data: []byte{0xfb, 0xff, 0xbf}, Key: []uint64{1, 2, 3}},
+	}
+	for _, want := range msgs {
+		s, err := protobase64.Marshal(want)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %v", want, err)
+		}
+		if i := strings.IndexFunc(s, func(r rune) bool {
+			return !('A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '-' || r == '_')
+		}); i >= 0 {
+			t.Errorf("Marshal(%v) = %q, contains %q", want, s, s[i])
+		}
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Errorf("Marshal(%v) = %q: %v", want, s, err)
+		}
+		wire, err := proto.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, wire) {
+			t.Errorf("Marshal(%v) decodes to %x, want %x", want, b, wire)
+		}
+
+		for _, s := range []string{s, base64.URLEncoding.EncodeToString(wire)} {
+			got := &pb.Message{Name: "stale"}
+			if err := protobase64.Unmarshal(s, got); err != nil {
+				t.Errorf("Unmarshal(%q): %v", s, err)
+				continue
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("Unmarshal(%q) = %v, want %v", s, got, want)
+			}
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, s := range []string{
+		"+w",   // standard alphabet
+		"a",    // impossible length
+		"CgE=", // truncated message
+	} {
+		if err := protobase64.Unmarshal(s, new(pb.Message)); err == nil {
+			t.Errorf("Unmarshal(%q) succeeded, want error", s)
+		}
+	}
+}
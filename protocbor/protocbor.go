@@ -0,0 +1,439 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package protocbor marshals and unmarshals protocol buffers in the Concise
+Binary Object Representation (CBOR) defined by RFC 8949.
+
+The encoding follows the proto3 JSON mapping implemented by package jsonpb,
+with each JSON value replaced by its CBOR equivalent: messages are maps
+keyed by the lowerCamelCase field names, repeated fields are arrays, and
+well-known types such as Timestamp, Duration and Any use their JSON forms.
+As in JSON, 64-bit integers are text strings and bytes fields are base64
+text strings, so that the output can be decoded without the schema.
+
+Unmarshal additionally accepts any integer or floating-point encoding for
+numbers, CBOR byte strings for bytes fields, indefinite-length items, and
+tagged items, whose tags are ignored.
+*/
+package protocbor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/internal/jsontree"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// CBOR major types.
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// maxDepth is the maximum nesting of arrays, maps and tags in the input.
+const maxDepth = 10000
+
+// Marshal returns the CBOR encoding of m.
+// Map keys are written in the order jsonpb writes them, and every item
+// has a definite length.
+func Marshal(m proto.Message) ([]byte, error) {
+	js, err := (&jsonpb.Marshaler{}).MarshalToString(m)
+	if err != nil {
+		return nil, err
+	}
+	v, err := jsontree.Parse(js)
+	if err != nil {
+		return nil, err
+	}
+	var e encoder
+	if err := e.value(v); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal parses the CBOR-encoded data b into m.
+// m is reset before unmarshaling.
+func Unmarshal(b []byte, m proto.Message) error {
+	var js bytes.Buffer
+	d := decoder{b: b}
+	if err := d.value(&js, 0); err != nil {
+		return err
+	}
+	if d.i != len(b) {
+		return fmt.Errorf("protocbor: %d bytes of trailing data", len(b)-d.i)
+	}
+	return (&jsonpb.Unmarshaler{}).Unmarshal(&js, m)
+}
+
+type encoder struct {
+	buf []byte
+}
+
+// head appends the initial bytes of an item of the given major type
+// with argument n, using the shortest encoding.
+func (e *encoder) head(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, major|26, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(n))
+	default:
+		e.buf = append(e.buf, major|27, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], n)
+	}
+}
+
+func (e *encoder) text(s string) {
+	e.head(majorText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) value(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, majorSimple<<5|22)
+	case bool:
+		if v {
+			e.buf = append(e.buf, majorSimple<<5|21)
+		} else {
+			e.buf = append(e.buf, majorSimple<<5|20)
+		}
+	case string:
+		e.text(v)
+	case json.Number:
+		return e.number(v)
+	case []interface{}:
+		e.head(majorArray, uint64(len(v)))
+		for _, x := range v {
+			if err := e.value(x); err != nil {
+				return err
+			}
+		}
+	case []jsontree.Member:
+		e.head(majorMap, uint64(len(v)))
+		for _, m := range v {
+			e.text(m.Key.(string))
+			if err := e.value(m.Val); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("protocbor: unexpected JSON value %T", v)
+	}
+	return nil
+}
+
+// number appends the JSON number n as an integer if it is one that fits
+// in 64 bits, and as the narrowest floating-point number that holds it
+// exactly otherwise.
+func (e *encoder) number(n json.Number) error {
+	x, err := jsontree.Number(n)
+	if err != nil {
+		return fmt.Errorf("protocbor: %v", err)
+	}
+	switch x := x.(type) {
+	case int64:
+		if x >= 0 {
+			e.head(majorUint, uint64(x))
+		} else {
+			e.head(majorNegInt, uint64(-1-x))
+		}
+	case uint64:
+		e.head(majorUint, x)
+	case float32:
+		e.buf = append(e.buf, majorSimple<<5|26, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], math.Float32bits(x))
+	case float64:
+		e.buf = append(e.buf, majorSimple<<5|27, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], math.Float64bits(x))
+	}
+	return nil
+}
+
+var errTruncated = errors.New("protocbor: unexpected end of input")
+
+// indefinite is the argument reported by head for indefinite-length items.
+const indefinite = math.MaxUint64
+
+// A decoder translates CBOR to JSON.
+type decoder struct {
+	b []byte
+	i int
+}
+
+func (d *decoder) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("protocbor: offset %d: %s", d.i, fmt.Sprintf(format, a...))
+}
+
+// head reads the initial bytes of an item and returns its major type,
+// additional information and argument. For indefinite-length items the
+// argument is indefinite.
+func (d *decoder) head() (major, info byte, arg uint64, err error) {
+	if d.i >= len(d.b) {
+		return 0, 0, 0, errTruncated
+	}
+	major, info = d.b[d.i]>>5, d.b[d.i]&31
+	d.i++
+	var n int
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		n = 1 << (info - 24)
+	case info == 31:
+		return major, info, indefinite, nil
+	default:
+		return 0, 0, 0, d.errorf("reserved additional information %d", info)
+	}
+	if len(d.b)-d.i < n {
+		return 0, 0, 0, errTruncated
+	}
+	for _, c := range d.b[d.i : d.i+n] {
+		arg = arg<<8 | uint64(c)
+	}
+	d.i += n
+	return major, info, arg, nil
+}
+
+// isBreak reports whether the next byte is the break stop code, and
+// consumes it if so.
+func (d *decoder) isBreak() (bool, error) {
+	if d.i >= len(d.b) {
+		return false, errTruncated
+	}
+	if d.b[d.i] == 0xff {
+		d.i++
+		return true, nil
+	}
+	return false, nil
+}
+
+// str reads the contents of a byte or text string of the given major type
+// whose head has been read.
+func (d *decoder) str(major byte, arg uint64) ([]byte, error) {
+	if arg != indefinite {
+		if arg > uint64(len(d.b)-d.i) {
+			return nil, errTruncated
+		}
+		s := d.b[d.i : d.i+int(arg)]
+		d.i += int(arg)
+		return s, nil
+	}
+	var s []byte
+	for {
+		if brk, err := d.isBreak(); err != nil || brk {
+			return s, err
+		}
+		m, _, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || n == indefinite {
+			return nil, d.errorf("invalid chunk in indefinite-length string")
+		}
+		c, err := d.str(major, n)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, c...)
+	}
+}
+
+// value reads one item and writes it to w as JSON.
+func (d *decoder) value(w *bytes.Buffer, depth int) error {
+	if depth > maxDepth {
+		return d.errorf("exceeded maximum nesting depth %d", maxDepth)
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case majorUint:
+		w.WriteString(strconv.FormatUint(arg, 10))
+	case majorNegInt:
+		n := new(big.Int).SetUint64(arg)
+		w.WriteString(n.Neg(n.Add(n, big.NewInt(1))).String())
+	case majorBytes:
+		s, err := d.str(major, arg)
+		if err != nil {
+			return err
+		}
+		w.WriteByte('"')
+		w.WriteString(base64.StdEncoding.EncodeToString(s))
+		w.WriteByte('"')
+	case majorText:
+		s, err := d.str(major, arg)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(s) {
+			return d.errorf("text string is not valid UTF-8")
+		}
+		b, err := json.Marshal(string(s))
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+	case majorArray:
+		w.WriteByte('[')
+		for i := uint64(0); arg == indefinite || i < arg; i++ {
+			if arg == indefinite {
+				if brk, err := d.isBreak(); err != nil {
+					return err
+				} else if brk {
+					break
+				}
+			}
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := d.value(w, depth+1); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	case majorMap:
+		w.WriteByte('{')
+		for i := uint64(0); arg == indefinite || i < arg; i++ {
+			if arg == indefinite {
+				if brk, err := d.isBreak(); err != nil {
+					return err
+				} else if brk {
+					break
+				}
+			}
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if d.i < len(d.b) && d.b[d.i]>>5 != majorText {
+				return d.errorf("map key is not a text string")
+			}
+			if err := d.value(w, depth+1); err != nil {
+				return err
+			}
+			w.WriteByte(':')
+			if err := d.value(w, depth+1); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('}')
+	case majorTag:
+		if arg == indefinite {
+			return d.errorf("invalid indefinite-length tag")
+		}
+		return d.value(w, depth+1)
+	case majorSimple:
+		return d.simple(w, info, arg)
+	}
+	return nil
+}
+
+// simple writes a simple value or floating-point number as JSON.
+func (d *decoder) simple(w *bytes.Buffer, info byte, arg uint64) error {
+	var f float64
+	switch info {
+	case 20:
+		w.WriteString("false")
+		return nil
+	case 21:
+		w.WriteString("true")
+		return nil
+	case 22, 23: // null, undefined
+		w.WriteString("null")
+		return nil
+	case 25:
+		f = halfToFloat(uint16(arg))
+	case 26:
+		f = float64(math.Float32frombits(uint32(arg)))
+	case 27:
+		f = math.Float64frombits(arg)
+	default:
+		return d.errorf("unsupported simple value %d", arg)
+	}
+	switch {
+	case math.IsNaN(f):
+		w.WriteString(`"NaN"`)
+	case math.IsInf(f, 1):
+		w.WriteString(`"Infinity"`)
+	case math.IsInf(f, -1):
+		w.WriteString(`"-Infinity"`)
+	default:
+		w.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return nil
+}
+
+// halfToFloat converts an IEEE 754 half-precision number to a float64.
+func halfToFloat(h uint16) float64 {
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
@@ -0,0 +1,274 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package protocbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	durpb "github.com/golang/protobuf/ptypes/duration"
+	stpb "github.com/golang/protobuf/ptypes/struct"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	wpb "github.com/golang/protobuf/ptypes/wrappers"
+)
+
+func TestRoundTrip(t *testing.T) {
+	an, err := ptypes.MarshalAny(&pb.Simple{OString: proto.String("inside")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := &pb.Real{Value: proto.Float64(3)}
+	if err := proto.SetExtension(real, pb.E_Name, proto.String("ext")); err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.SetExtension(real, pb.E_Complex_RealExtension, &pb.Complex{Imaginary: proto.Float64(-1)}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []proto.Message{
+		&pb.Simple{
+			OBool:   proto.Bool(true),
+			OInt32:  proto.Int32(-32),
+			OInt64:  proto.Int64(math.MinInt64),
+			OUint32: proto.Uint32(math.MaxUint32),
+			OUint64: proto.Uint64(math.MaxUint64),
+			OSint32: proto.Int32(math.MinInt32),
+			OSint64: proto.Int64(math.MaxInt64),
+			OFloat:  proto.Float32(1.5),
+			ODouble: proto.Float64(0.1),
+			OString: proto.String("héllo\x00\"<>"),
+			OBytes:  []byte{0, 1, 0xfe, 0xff},
+		},
+		&pb.Repeats{
+			RBool:   []bool{true, false},
+			RInt32:  []int32{-1, 0, 1 << 30},
+			RInt64:  []int64{-1 << 40, 1 << 40},
+			RUint32: []uint32{0, 24, 256, 65536},
+			RUint64: []uint64{math.MaxUint64},
+			RSint32: []int32{-5},
+			RSint64: []int64{-6},
+			RFloat:  []float32{-2.25, 1e30},
+			RDouble: []float64{1e300, -1e-300},
+			RString: []string{"", "a"},
+			RBytes:  [][]byte{{}, []byte("b")},
+		},
+		&pb.NonFinites{
+			FNan:  proto.Float32(float32(math.NaN())),
+			FPinf: proto.Float32(float32(math.Inf(1))),
+			DNinf: proto.Float64(math.Inf(-1)),
+		},
+		&pb.Widget{
+			Color:    pb.Widget_BLUE.Enum(),
+			RColor:   []pb.Widget_Color{pb.Widget_RED, pb.Widget_GREEN},
+			Simple:   &pb.Simple{OInt32: proto.Int32(1)},
+			RSimple:  []*pb.Simple{{OString: proto.String("x")}, {}},
+			Repeats:  &pb.Repeats{RString: []string{"y"}},
+			RRepeats: []*pb.Repeats{{RInt32: []int32{1}}},
+		},
+		&pb.Maps{
+			MInt64Str:   map[int64]string{-1: "minus one", 1 << 50: "big"},
+			MBoolSimple: map[bool]*pb.Simple{true: {OBool: proto.Bool(true)}},
+		},
+		&pb.MsgWithOneof{Union: &pb.MsgWithOneof_Salary{Salary: 31000}},
+		real,
+		&pb.KnownTypes{
+			An:  an,
+			Dur: &durpb.Duration{Seconds: 3, Nanos: 1000},
+			St: &stpb.Struct{Fields: map[string]*stpb.Value{
+				"n": {Kind: &stpb.Value_NumberValue{NumberValue: 2.5}},
+				"l": {Kind: &stpb.Value_ListValue{ListValue: &stpb.ListValue{Values: []*stpb.Value{
+					{Kind: &stpb.Value_NullValue{}},
+					{Kind: &stpb.Value_BoolValue{BoolValue: true}},
+				}}}},
+			}},
+			Ts:    &tspb.Timestamp{Seconds: 14e8, Nanos: 21e6},
+			Dbl:   &wpb.DoubleValue{Value: 1.25},
+			I64:   &wpb.Int64Value{Value: -3},
+			U32:   &wpb.UInt32Value{Value: 4},
+			Bool:  &wpb.BoolValue{Value: true},
+			Str:   &wpb.StringValue{Value: "s"},
+			Bytes: &wpb.BytesValue{Value: []byte("wow")},
+		},
+	}
+	for _, want := range tests {
+		b, err := Marshal(want)
+		if err != nil {
+			t.Errorf("Marshal(%v): %v", want, err)
+			continue
+		}
+		got := proto.Clone(want)
+		got.Reset()
+		if err := Unmarshal(b, got); err != nil {
+			t.Errorf("Unmarshal(Marshal(%v)) [%x]: %v", want, b, err)
+			continue
+		}
+		if !proto.Equal(got, want) && !nonFinitesEqual(got, want) {
+			t.Errorf("round trip of %v = %v", want, got)
+		}
+	}
+}
+
+// nonFinitesEqual compares NonFinites messages, treating NaNs as equal.
+func nonFinitesEqual(a, b proto.Message) bool {
+	x, ok1 := a.(*pb.NonFinites)
+	y, ok2 := b.(*pb.NonFinites)
+	return ok1 && ok2 && math.IsNaN(float64(x.GetFNan())) && math.IsNaN(float64(y.GetFNan())) &&
+		x.GetFPinf() == y.GetFPinf() && x.GetDNinf() == y.GetDNinf()
+}
+
+func TestMarshalEncoding(t *testing.T) {
+	tests := []struct {
+		m    proto.Message
+		want string // hex
+	}{
+		{&pb.Simple{}, "a0"},
+		{&pb.Simple{OInt32: proto.Int32(0)}, "a1" + "666f496e743332" + "00"},
+		{&pb.Simple{OInt32: proto.Int32(23)}, "a1" + "666f496e743332" + "17"},
+		{&pb.Simple{OInt32: proto.Int32(24)}, "a1" + "666f496e743332" + "1818"},
+		{&pb.Simple{OInt32: proto.Int32(1000)}, "a1" + "666f496e743332" + "1903e8"},
+		{&pb.Simple{OInt32: proto.Int32(1000000)}, "a1" + "666f496e743332" + "1a000f4240"},
+		{&pb.Simple{OInt32: proto.Int32(-100)}, "a1" + "666f496e743332" + "3863"},
+		{&pb.Simple{OBool: proto.Bool(false)}, "a1" + "656f426f6f6c" + "f4"},
+		{&pb.Simple{ODouble: proto.Float64(1.5)}, "a1" + "676f446f75626c65" + "fa3fc00000"},
+		{&pb.Simple{ODouble: proto.Float64(1.1)}, "a1" + "676f446f75626c65" + "fb3ff199999999999a"},
+		{&pb.Simple{OInt64: proto.Int64(1)}, "a1" + "666f496e743634" + "6131"},
+		{&pb.Repeats{RInt32: []int32{1, 2}}, "a1" + "6672496e743332" + "820102"},
+		{&wpb.StringValue{Value: "a"}, "6161"},
+		{&stpb.Value{Kind: &stpb.Value_NullValue{}}, "f6"},
+	}
+	for _, tt := range tests {
+		b, err := Marshal(tt.m)
+		if err != nil {
+			t.Errorf("Marshal(%v): %v", tt.m, err)
+			continue
+		}
+		if got := hex.EncodeToString(b); got != tt.want {
+			t.Errorf("Marshal(%v) = %s, want %s", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeToJSON(t *testing.T) {
+	// Examples from RFC 8949, Appendix A.
+	tests := []struct {
+		in   string // hex
+		want string // JSON
+	}{
+		{"00", "0"},
+		{"1b000000e8d4a51000", "1000000000000"},
+		{"1bffffffffffffffff", "18446744073709551615"},
+		{"3bffffffffffffffff", "-18446744073709551616"},
+		{"3903e7", "-1000"},
+		{"f90000", "0"},
+		{"f98000", "-0"},
+		{"f93c00", "1"},
+		{"f93e00", "1.5"},
+		{"f97bff", "65504"},
+		{"f90001", "5.960464477539063e-08"},
+		{"f9c400", "-4"},
+		{"fa47c35000", "100000"},
+		{"fb7e37e43c8800759c", "1e+300"},
+		{"f97c00", `"Infinity"`},
+		{"f97e00", `"NaN"`},
+		{"fbfff0000000000000", `"-Infinity"`},
+		{"f4", "false"},
+		{"f5", "true"},
+		{"f6", "null"},
+		{"f7", "null"},
+		{"c11a514b67b0", "1363896240"},
+		{"4401020304", `"AQIDBA=="`},
+		{"6449455446", `"IETF"`},
+		{"62225c", `"\"\\"`},
+		{"80", "[]"},
+		{"83010203", "[1,2,3]"},
+		{"a26161016162820203", `{"a":1,"b":[2,3]}`},
+		{"5f42010243030405ff", `"AQIDBAU="`},
+		{"7f657374726561646d696e67ff", `"streaming"`},
+		{"9fff", "[]"},
+		{"9f018202039f0405ffff", "[1,[2,3],[4,5]]"},
+		{"bf61610161629f0203ffff", `{"a":1,"b":[2,3]}`},
+	}
+	for _, tt := range tests {
+		in, err := hex.DecodeString(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var w bytes.Buffer
+		d := decoder{b: in}
+		if err := d.value(&w, 0); err != nil {
+			t.Errorf("decoding %s: %v", tt.in, err)
+			continue
+		}
+		if d.i != len(in) {
+			t.Errorf("decoding %s consumed %d of %d bytes", tt.in, d.i, len(in))
+		}
+		if got := w.String(); got != tt.want {
+			t.Errorf("decoding %s = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		in   string // hex
+		want string
+	}{
+		{"", "unexpected end"},
+		{"18", "unexpected end"},
+		{"62ff", "unexpected end"},
+		{"a1", "unexpected end"},
+		{"9f01", "unexpected end"},
+		{"1c", "reserved"},
+		{"a10102", "map key is not a text string"},
+		{"61ff", "not valid UTF-8"},
+		{"5f6161ff", "invalid chunk"},
+		{"f0", "unsupported simple value"},
+		{"a0a0", "trailing data"},
+		{strings.Repeat("81", maxDepth+2) + "00", "nesting depth"},
+		{"a1" + "666f496e743332" + "6161", "invalid"}, // string for an int32 field
+	}
+	for _, tt := range tests {
+		in, err := hex.DecodeString(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Unmarshal(in, new(pb.Simple))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Unmarshal(%.20s) = %v, want error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package protodelim marshals and unmarshals streams of protocol buffer
+messages, each framed by its length as a 4-byte big-endian integer.
+This is the framing used by gRPC, without the compression flag byte,
+and is commonly used to send messages over sockets and pipes.
+*/
+package protodelim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// prefixSize is the size of the length prefix of each message.
+const prefixSize = 4
+
+// MarshalTo writes the length-prefixed wire encoding of m to w.
+// It returns the number of bytes written, including the prefix.
+func MarshalTo(w io.Writer, m proto.Message) (int, error) {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return 0, err
+	}
+	if uint64(len(b)) > math.MaxUint32 {
+		return 0, fmt.Errorf("protodelim: message of %d bytes is too large to frame", len(b))
+	}
+	buf := make([]byte, prefixSize+len(b))
+	binary.BigEndian.PutUint32(buf, uint32(len(b)))
+	copy(buf[prefixSize:], b)
+	return w.Write(buf)
+}
+
+// UnmarshalFrom reads one length-prefixed message from r into m.
+// m is reset before the message is decoded.
+//
+// It returns io.EOF if r is at the end of the stream before any bytes of
+// the next message are read, and io.ErrUnexpectedEOF if the stream ends
+// partway through a message.
+func UnmarshalFrom(r io.Reader, m proto.Message) error {
+	var prefix [prefixSize]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return err
+	}
+	n := int64(binary.BigEndian.Uint32(prefix[:]))
+
+	// Grow the buffer as the data arrives rather than trusting the
+	// prefix, so that a corrupt length cannot force a huge allocation.
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return proto.Unmarshal(buf.Bytes(), m)
+}
@@ -0,0 +1,105 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package protodelim_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/golang/protobuf/proto/proto3_proto"
+	"github.com/golang/protobuf/protodelim"
+)
+
+func TestRoundTrip(t *testing.T) {
+	msgs := []*pb.Message{
+		{Name: "first", Hilarity: pb.Message_PUNS},
+		{}, // zero-length
+		{Name: "third", Key: []uint64{1, 2, 3}, Data: []byte("data")},
+	}
+	var buf bytes.Buffer
+	for _, m := range msgs {
+		n, err := protodelim.MarshalTo(&buf, m)
+		if err != nil {
+			t.Fatalf("MarshalTo(%v): %v", m, err)
+		}
+		if want := 4 + proto.Size(m); n != want {
+			t.Errorf("MarshalTo(%v) = %d bytes, want %d", m, n, want)
+		}
+	}
+	for _, want := range msgs {
+		got := &pb.Message{Name: "stale"}
+		if err := protodelim.UnmarshalFrom(&buf, got); err != nil {
+			t.Fatalf("UnmarshalFrom: %v", err)
+		}
+		if !proto.Equal(got, want) {
+			t.Errorf("UnmarshalFrom = %v, want %v", got, want)
+		}
+	}
+	if err := protodelim.UnmarshalFrom(&buf, new(pb.Message)); err != io.EOF {
+		t.Errorf("UnmarshalFrom at end of stream = %v, want io.EOF", err)
+	}
+}
+
+func TestBigEndianPrefix(t *testing.T) {
+	m := &pb.Message{Data: make([]byte, 300)}
+	var buf bytes.Buffer
+	if _, err := protodelim.MarshalTo(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	size := proto.Size(m)
+	want := []byte{0, 0, byte(size >> 8), byte(size)}
+	if got := buf.Bytes()[:4]; !bytes.Equal(got, want) {
+		t.Errorf("length prefix = %x, want %x", got, want)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := protodelim.MarshalTo(&buf, &pb.Message{Name: "truncated"}); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	for _, n := range []int{1, 3, 4, len(b) - 1} {
+		err := protodelim.UnmarshalFrom(bytes.NewReader(b[:n]), new(pb.Message))
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("UnmarshalFrom(%d of %d bytes) = %v, want io.ErrUnexpectedEOF", n, len(b), err)
+		}
+	}
+
+	// A corrupt length does not cause the whole length to be allocated.
+	huge := []byte{0xff, 0xff, 0xff, 0xff, 0x0a}
+	if err := protodelim.UnmarshalFrom(bytes.NewReader(huge), new(pb.Message)); err != io.ErrUnexpectedEOF {
+		t.Errorf("UnmarshalFrom(%x) = %v, want io.ErrUnexpectedEOF", huge, err)
+	}
+}
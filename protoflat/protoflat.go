@@ -0,0 +1,256 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package protoflat marshals and unmarshals protocol buffer messages as flat
+maps of string keys to string values, suitable for environment variables
+and key-value stores.
+
+Each populated scalar field is one entry keyed by its path from the root
+message: field names as written in the .proto file, joined by '.' for
+nested messages, with an index suffix for elements of repeated fields and
+a key suffix for entries of map fields. For example:
+
+	name                  "Gopher"
+	nested.bunny          "Flopsy"
+	key[0]                "1"
+	terrain[home].bunny   "Mopsy"
+
+Values use the Go syntax of package strconv, enums use their value names,
+and bytes use standard base64. Messages without any populated fields and
+extensions are not represented. Map keys may not contain ']'.
+*/
+package protoflat
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/internal/textfields"
+	"github.com/golang/protobuf/proto"
+)
+
+// Marshal returns the flattened form of m.
+func Marshal(m proto.Message) (map[string]string, error) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("protoflat: unsupported message type %T", m)
+	}
+	kv := make(map[string]string)
+	if v.IsNil() {
+		return kv, nil
+	}
+	if err := flattenStruct(kv, "", v.Elem()); err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
+
+func flattenStruct(kv map[string]string, prefix string, sv reflect.Value) error {
+	return textfields.Range(sv, func(prop *proto.Properties, v reflect.Value, oneof bool) error {
+		if oneof {
+			return flattenValue(kv, prefix+prop.OrigName, prop, v)
+		}
+		return flattenField(kv, prefix+prop.OrigName, prop, v)
+	})
+}
+
+func flattenField(kv map[string]string, key string, prop *proto.Properties, v reflect.Value) error {
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < v.Len(); i++ {
+			if err := flattenValue(kv, key+"["+strconv.Itoa(i)+"]", prop, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case v.Kind() == reflect.Map:
+		for _, k := range v.MapKeys() {
+			ks := textfields.FormatScalar(k, prop.MapKeyProp)
+			if strings.Contains(ks, "]") {
+				return fmt.Errorf("protoflat: %s: map key %q contains ']'", key, ks)
+			}
+			if err := flattenValue(kv, key+"["+ks+"]", prop.MapValProp, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case textfields.IsSet(v):
+		return flattenValue(kv, key, prop, v)
+	}
+	return nil
+}
+
+func flattenValue(kv map[string]string, key string, prop *proto.Properties, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+		if v.Kind() == reflect.Struct {
+			return flattenStruct(kv, key+".", v)
+		}
+	}
+	kv[key] = textfields.FormatScalar(v, prop)
+	return nil
+}
+
+// Unmarshal parses the flattened form kv into m.
+// m is reset before unmarshaling.
+//
+// Every key must name a scalar field; a key ending at a message field is
+// an error. Indexes of repeated fields must be less than len(kv), and
+// missing elements are left as zero values.
+func Unmarshal(kv map[string]string, m proto.Message) error {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct || v.IsNil() {
+		return fmt.Errorf("protoflat: unsupported message type %T", m)
+	}
+	m.Reset()
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	u := unmarshaler{maxIndex: len(kv)}
+	for _, k := range keys {
+		u.key = k
+		if err := u.setPath(v.Elem(), k, kv[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type unmarshaler struct {
+	key      string // the key being unmarshaled, for errors
+	maxIndex int    // bounds repeated field indexes, to limit allocation
+}
+
+func (u *unmarshaler) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("protoflat: key %q: %s", u.key, fmt.Sprintf(format, a...))
+}
+
+// setPath sets the field of the struct sv named by path to val.
+func (u *unmarshaler) setPath(sv reflect.Value, path, val string) error {
+	n := strings.IndexAny(path, ".[")
+	if n < 0 {
+		n = len(path)
+	}
+	name, rest := path[:n], path[n:]
+
+	st := sv.Type()
+	fv, prop := textfields.Field(sv, name)
+	if prop == nil {
+		return u.errorf("no field %q in %v", name, st)
+	}
+
+	repeated := fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8
+	if !strings.HasPrefix(rest, "[") {
+		if repeated || fv.Kind() == reflect.Map {
+			return u.errorf("field %q of %v needs an index", name, st)
+		}
+		return u.setValue(fv, prop, rest, val)
+	}
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return u.errorf("missing ']'")
+	}
+	index, rest := rest[1:end], rest[end+1:]
+	switch {
+	case repeated:
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 || i >= u.maxIndex {
+			return u.errorf("invalid index %q", index)
+		}
+		for fv.Len() <= i {
+			elem := reflect.Zero(fv.Type().Elem())
+			if elem.Kind() == reflect.Ptr {
+				elem = reflect.New(elem.Type().Elem())
+			}
+			fv.Set(reflect.Append(fv, elem))
+		}
+		return u.setValue(fv.Index(i), prop, rest, val)
+	case fv.Kind() == reflect.Map:
+		mt := fv.Type()
+		k := reflect.New(mt.Key()).Elem()
+		if err := u.parseScalar(k, prop.MapKeyProp, index); err != nil {
+			return err
+		}
+		if fv.IsNil() {
+			fv.Set(reflect.MakeMap(mt))
+		}
+		elem := reflect.New(mt.Elem()).Elem()
+		if old := fv.MapIndex(k); old.IsValid() {
+			elem.Set(old)
+		}
+		if err := u.setValue(elem, prop.MapValProp, rest, val); err != nil {
+			return err
+		}
+		fv.SetMapIndex(k, elem)
+		return nil
+	}
+	return u.errorf("field %q of %v is not repeated", name, st)
+}
+
+// setValue sets v, a field or element, to val, or if v is a message, sets
+// the field named by rest within it.
+func (u *unmarshaler) setValue(v reflect.Value, prop *proto.Properties, rest, val string) error {
+	if v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct {
+		if !strings.HasPrefix(rest, ".") {
+			return u.errorf("message field needs a subfield")
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return u.setPath(v.Elem(), rest[1:], val)
+	}
+	if rest != "" {
+		return u.errorf("unexpected %q after scalar field", rest)
+	}
+	if v.Kind() == reflect.Ptr {
+		nv := reflect.New(v.Type().Elem())
+		if err := u.parseScalar(nv.Elem(), prop, val); err != nil {
+			return err
+		}
+		v.Set(nv)
+		return nil
+	}
+	return u.parseScalar(v, prop, val)
+}
+
+func (u *unmarshaler) parseScalar(v reflect.Value, prop *proto.Properties, s string) error {
+	if err := textfields.ParseScalar(v, prop, s); err != nil {
+		return u.errorf("%v", err)
+	}
+	return nil
+}
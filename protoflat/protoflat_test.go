@@ -0,0 +1,174 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package protoflat_test
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb3 "github.com/golang/protobuf/proto/proto3_proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
+	"github.com/golang/protobuf/protoflat"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		m    proto.Message
+		want map[string]string
+	}{{
+		m: &pb3.Message{
+			Name:         "Gopher",
+			Hilarity:     pb3.Message_PUNS,
+			HeightInCm:   math.MaxUint32,
+			Data:         []byte{0, 0xff},
+			ResultCount:  math.MinInt64,
+			TrueScotsman: true,
+			Score:        0.25,
+			Key:          []uint64{1, 2},
+			Nested:       &pb3.Nested{Bunny: "Flopsy", Cute: true},
+			RFunny:       []pb3.Message_Humour{pb3.Message_SLAPSTICK, pb3.Message_UNKNOWN},
+			Terrain:      map[string]*pb3.Nested{"home": {Bunny: "Mopsy"}},
+			Proto2Field:  &pb.SubDefaults{N: proto.Int64(0)},
+			Children:     []*pb3.Message{{Name: "a"}, {Submessage: &pb3.Message{Name: "b"}}},
+			StringMap:    map[string]string{"x.y": "z", "": "empty"},
+		},
+		want: map[string]string{
+			"name":                        "Gopher",
+			"hilarity":                    "PUNS",
+			"height_in_cm":                "4294967295",
+			"data":                        "AP8=",
+			"result_count":                "-9223372036854775808",
+			"true_scotsman":               "true",
+			"score":                       "0.25",
+			"key[0]":                      "1",
+			"key[1]":                      "2",
+			"nested.bunny":                "Flopsy",
+			"nested.cute":                 "true",
+			"r_funny[0]":                  "SLAPSTICK",
+			"r_funny[1]":                  "UNKNOWN",
+			"terrain[home].bunny":         "Mopsy",
+			"proto2_field.n":              "0",
+			"children[0].name":            "a",
+			"children[1].submessage.name": "b",
+			"string_map[x.y]":             "z",
+			"string_map[]":                "empty",
+		},
+	}, {
+		m: &pb.GoTestField{Label: proto.String(""), Type: proto.String("t")},
+		want: map[string]string{
+			"Label": "",
+			"Type":  "t",
+		},
+	}, {
+		m:    &pb.Communique{MakeMeCry: proto.Bool(false), Union: &pb.Communique_Number{Number: 0}},
+		want: map[string]string{"make_me_cry": "false", "number": "0"},
+	}, {
+		m:    &pb.Communique{Union: &pb.Communique_Msg{Msg: &pb.Strings{StringField: proto.String("s")}}},
+		want: map[string]string{"msg.string_field": "s"},
+	}, {
+		m:    &pb.Communique{Union: &pb.Communique_Col{Col: pb.MyMessage_GREEN}},
+		want: map[string]string{"col": "GREEN"},
+	}}
+	for _, tt := range tests {
+		got, err := protoflat.Marshal(tt.m)
+		if err != nil {
+			t.Errorf("Marshal(%v): %v", tt.m, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Marshal(%v) = %v, want %v", tt.m, got, tt.want)
+		}
+		m := proto.Clone(tt.m)
+		if err := protoflat.Unmarshal(got, m); err != nil {
+			t.Errorf("Unmarshal(%v): %v", got, err)
+			continue
+		}
+		if !proto.Equal(m, tt.m) {
+			t.Errorf("Unmarshal(%v) = %v, want %v", got, m, tt.m)
+		}
+	}
+}
+
+func TestUnmarshalNumbers(t *testing.T) {
+	// Enums may be given by number, and repeated elements may be missing.
+	got := new(pb3.Message)
+	kv := map[string]string{"hilarity": "1", "key[2]": "5", "children[1].name": "c"}
+	if err := protoflat.Unmarshal(kv, got); err != nil {
+		t.Fatal(err)
+	}
+	want := &pb3.Message{
+		Hilarity: pb3.Message_PUNS,
+		Key:      []uint64{0, 0, 5},
+		Children: []*pb3.Message{{}, {Name: "c"}},
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("Unmarshal(%v) = %v, want %v", kv, got, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		kv   map[string]string
+		want string
+	}{
+		{map[string]string{"nested": "x"}, "message field needs a subfield"},
+		{map[string]string{"nested.": "x"}, `no field ""`},
+		{map[string]string{"nested.hops": "x"}, `no field "hops"`},
+		{map[string]string{"bogus": "x"}, `no field "bogus"`},
+		{map[string]string{"name.first": "x"}, "after scalar field"},
+		{map[string]string{"key": "1"}, "needs an index"},
+		{map[string]string{"key[1]": "1"}, `invalid index "1"`},
+		{map[string]string{"key[-1]": "1"}, `invalid index "-1"`},
+		{map[string]string{"key[0": "1"}, "missing ']'"},
+		{map[string]string{"name[0]": "x"}, "is not repeated"},
+		{map[string]string{"key[0]": "-1"}, "invalid value"},
+		{map[string]string{"score": "high"}, "invalid value"},
+		{map[string]string{"hilarity": "FUNNY"}, "invalid value"},
+		{map[string]string{"data": "!"}, "invalid value"},
+	}
+	for _, tt := range tests {
+		err := protoflat.Unmarshal(tt.kv, new(pb3.Message))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Unmarshal(%v) = %v, want error containing %q", tt.kv, err, tt.want)
+		}
+	}
+}
+
+func TestMarshalInvalidMapKey(t *testing.T) {
+	m := &pb3.Message{StringMap: map[string]string{"a]b": "c"}}
+	if _, err := protoflat.Marshal(m); err == nil {
+		t.Errorf("Marshal(%v) succeeded, want error", m)
+	}
+}
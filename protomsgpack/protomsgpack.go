@@ -0,0 +1,596 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package protomsgpack marshals and unmarshals protocol buffers in the
+MessagePack format (https://msgpack.org).
+
+The encoding follows the proto3 JSON mapping implemented by package jsonpb,
+with each JSON value replaced by its MessagePack equivalent: messages are
+maps keyed by the lowerCamelCase field names, repeated fields are arrays,
+and well-known types such as Timestamp, Duration and Any use their JSON
+forms. As in JSON, 64-bit integers are strings and bytes fields are base64
+strings. Marshaler.UseFieldNumbers replaces the field names of messages
+with their field numbers for a more compact encoding.
+
+Unmarshal accepts either form of key, any integer or floating-point
+encoding for numbers, and MessagePack binary values for bytes fields.
+Extension types are not supported.
+*/
+package protomsgpack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/internal/jsontree"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// maxDepth is the maximum nesting of arrays and maps in the input.
+const maxDepth = 10000
+
+// Marshaler is a configurable object for converting protocol buffers to
+// MessagePack.
+type Marshaler struct {
+	// Whether to key the fields of messages by their field numbers rather
+	// than their JSON names. Fields of well-known types, map keys and
+	// extensions are still keyed by name.
+	UseFieldNumbers bool
+}
+
+// Marshal returns the MessagePack encoding of pb, keyed by field names.
+func Marshal(pb proto.Message) ([]byte, error) {
+	return new(Marshaler).Marshal(pb)
+}
+
+// Marshal returns the MessagePack encoding of pb.
+func (m *Marshaler) Marshal(pb proto.Message) ([]byte, error) {
+	js, err := (&jsonpb.Marshaler{}).MarshalToString(pb)
+	if err != nil {
+		return nil, err
+	}
+	v, err := jsontree.Parse(js)
+	if err != nil {
+		return nil, err
+	}
+	if m.UseFieldNumbers {
+		numberKeys(v, reflect.TypeOf(pb))
+	}
+	var e encoder
+	if err := e.value(v); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal parses the MessagePack-encoded data b into pb.
+// pb is reset before unmarshaling.
+func Unmarshal(b []byte, pb proto.Message) error {
+	d := decoder{b: b}
+	v, err := d.value(0)
+	if err != nil {
+		return err
+	}
+	if d.i != len(b) {
+		return fmt.Errorf("protomsgpack: %d bytes of trailing data", len(b)-d.i)
+	}
+	if err := nameKeys(v, reflect.TypeOf(pb)); err != nil {
+		return err
+	}
+	var js bytes.Buffer
+	if err := writeJSON(&js, v); err != nil {
+		return err
+	}
+	return (&jsonpb.Unmarshaler{}).Unmarshal(&js, pb)
+}
+
+type wkt interface {
+	XXX_WellKnownType() string
+}
+
+// messageFields returns the fields of the message type t, a pointer to a
+// generated struct, keyed by JSON name. It returns nil for well-known types
+// and other types that do not use the generic JSON form of a message.
+func messageFields(t reflect.Type) map[string]*fieldInfo {
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	if _, ok := reflect.Zero(t).Interface().(wkt); ok {
+		return nil
+	}
+	fields := make(map[string]*fieldInfo)
+	sprops := proto.GetProperties(t.Elem())
+	for i, prop := range sprops.Prop {
+		if prop.Tag != 0 {
+			fields[jsonName(prop)] = &fieldInfo{prop.Tag, t.Elem().Field(i).Type}
+		}
+	}
+	for _, oop := range sprops.OneofTypes {
+		fields[jsonName(oop.Prop)] = &fieldInfo{oop.Prop.Tag, oop.Type.Elem().Field(0).Type}
+	}
+	return fields
+}
+
+type fieldInfo struct {
+	num int
+	typ reflect.Type
+}
+
+// jsonName returns the name jsonpb uses for a field.
+func jsonName(prop *proto.Properties) string {
+	if prop.JSONName != "" {
+		return prop.JSONName
+	}
+	return prop.OrigName
+}
+
+// numberKeys replaces the field names of the message value v, of type t,
+// and of its nested messages with field numbers.
+func numberKeys(v interface{}, t reflect.Type) {
+	o, ok := v.([]jsontree.Member)
+	fields := messageFields(t)
+	if !ok || fields == nil {
+		return
+	}
+	for i := range o {
+		f := fields[o[i].Key.(string)]
+		if f == nil {
+			continue // an extension
+		}
+		o[i].Key = int64(f.num)
+		forEachMessage(o[i].Val, f.typ, numberKeys)
+	}
+}
+
+// nameKeys replaces the integer keys of the message value v, of type t,
+// and of its nested messages with field names.
+func nameKeys(v interface{}, t reflect.Type) error {
+	o, ok := v.([]jsontree.Member)
+	fields := messageFields(t)
+	if !ok || fields == nil {
+		return nil
+	}
+	byNum := make(map[int64]string, len(fields))
+	for name, f := range fields {
+		byNum[int64(f.num)] = name
+	}
+	for i := range o {
+		var name string
+		switch k := o[i].Key.(type) {
+		case string:
+			name = k
+		case int64:
+			if name, ok = byNum[k]; !ok {
+				return fmt.Errorf("protomsgpack: %v has no field number %d", t.Elem(), k)
+			}
+		case uint64:
+			return fmt.Errorf("protomsgpack: %v has no field number %d", t.Elem(), k)
+		}
+		o[i].Key = name
+		if f := fields[name]; f != nil {
+			var err error
+			forEachMessage(o[i].Val, f.typ, func(v interface{}, t reflect.Type) {
+				if err == nil {
+					err = nameKeys(v, t)
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// forEachMessage calls f for each message held by the value v of a field
+// of Go type t, with the message's type.
+func forEachMessage(v interface{}, t reflect.Type, f func(interface{}, reflect.Type)) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		f(v, t)
+	case reflect.Slice:
+		if a, ok := v.([]interface{}); ok && t.Elem().Kind() == reflect.Ptr {
+			for _, e := range a {
+				f(e, t.Elem())
+			}
+		}
+	case reflect.Map:
+		if o, ok := v.([]jsontree.Member); ok && t.Elem().Kind() == reflect.Ptr {
+			for _, m := range o {
+				f(m.Val, t.Elem())
+			}
+		}
+	}
+}
+
+type encoder struct {
+	buf []byte
+}
+
+// head appends the type byte for an item whose size class is chosen by n:
+// fix is used with n or'ed in if n < fixLimit, and otherwise the first
+// of codes whose size fits n, followed by n in that size.
+func (e *encoder) head(n uint64, fix byte, fixLimit uint64, c8, c16, c32 byte) {
+	switch {
+	case n < fixLimit:
+		e.buf = append(e.buf, fix|byte(n))
+	case c8 != 0 && n <= math.MaxUint8:
+		e.buf = append(e.buf, c8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, c16, byte(n>>8), byte(n))
+	default:
+		e.buf = append(e.buf, c32, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(n))
+	}
+}
+
+func (e *encoder) str(s string) error {
+	if uint64(len(s)) > math.MaxUint32 {
+		return errors.New("protomsgpack: string too long")
+	}
+	e.head(uint64(len(s)), 0xa0, 32, 0xd9, 0xda, 0xdb)
+	e.buf = append(e.buf, s...)
+	return nil
+}
+
+func (e *encoder) uint(n uint64) {
+	switch {
+	case n < 0x80:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(n))
+	default:
+		e.buf = append(e.buf, 0xcf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], n)
+	}
+}
+
+func (e *encoder) int(n int64) {
+	switch {
+	case n >= 0:
+		e.uint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1, byte(n>>8), byte(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(n))
+	default:
+		e.buf = append(e.buf, 0xd3, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], uint64(n))
+	}
+}
+
+func (e *encoder) value(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case string:
+		return e.str(v)
+	case int64:
+		e.int(v)
+	case json.Number:
+		return e.number(v)
+	case []interface{}:
+		e.head(uint64(len(v)), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, x := range v {
+			if err := e.value(x); err != nil {
+				return err
+			}
+		}
+	case []jsontree.Member:
+		e.head(uint64(len(v)), 0x80, 16, 0, 0xde, 0xdf)
+		for _, m := range v {
+			if err := e.value(m.Key); err != nil {
+				return err
+			}
+			if err := e.value(m.Val); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("protomsgpack: unexpected value %T", v)
+	}
+	return nil
+}
+
+// number appends the JSON number n as an integer if it is one that fits
+// in 64 bits, and as the narrowest floating-point number that holds it
+// exactly otherwise.
+func (e *encoder) number(n json.Number) error {
+	x, err := jsontree.Number(n)
+	if err != nil {
+		return fmt.Errorf("protomsgpack: %v", err)
+	}
+	switch x := x.(type) {
+	case int64:
+		e.int(x)
+	case uint64:
+		e.uint(x)
+	case float32:
+		e.buf = append(e.buf, 0xca, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], math.Float32bits(x))
+	case float64:
+		e.buf = append(e.buf, 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], math.Float64bits(x))
+	}
+	return nil
+}
+
+var errTruncated = errors.New("protomsgpack: unexpected end of input")
+
+// A decoder parses MessagePack into nil, bool, int64, uint64, float64,
+// string, []byte, []interface{} and []jsontree.Member values.
+type decoder struct {
+	b []byte
+	i int
+}
+
+func (d *decoder) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("protomsgpack: offset %d: %s", d.i, fmt.Sprintf(format, a...))
+}
+
+// uint reads an n-byte big-endian unsigned integer.
+func (d *decoder) uint(n int) (uint64, error) {
+	if len(d.b)-d.i < n {
+		return 0, errTruncated
+	}
+	var x uint64
+	for _, c := range d.b[d.i : d.i+n] {
+		x = x<<8 | uint64(c)
+	}
+	d.i += n
+	return x, nil
+}
+
+// bytes reads n bytes.
+func (d *decoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)-d.i) {
+		return nil, errTruncated
+	}
+	b := d.b[d.i : d.i+int(n)]
+	d.i += int(n)
+	return b, nil
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, d.errorf("exceeded maximum nesting depth %d", maxDepth)
+	}
+	if d.i >= len(d.b) {
+		return nil, errTruncated
+	}
+	c := d.b[d.i]
+	d.i++
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.mapItems(uint64(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.arrayItems(uint64(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.str(uint64(c & 0x1f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6: // bin 8, 16, 32
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.bytes(n)
+	case 0xca:
+		x, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(x))), err
+	case 0xcb:
+		x, err := d.uint(8)
+		return math.Float64frombits(x), err
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint 8, 16, 32, 64
+		x, err := d.uint(1 << (c - 0xcc))
+		if x <= math.MaxInt64 {
+			return int64(x), err
+		}
+		return x, err
+	case 0xd0, 0xd1, 0xd2, 0xd3: // int 8, 16, 32, 64
+		size := uint(1) << (c - 0xd0)
+		x, err := d.uint(int(size))
+		shift := 64 - 8*size
+		return int64(x<<shift) >> shift, err
+	case 0xd9, 0xda, 0xdb: // str 8, 16, 32
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd: // array 16, 32
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.arrayItems(n, depth)
+	case 0xde, 0xdf: // map 16, 32
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapItems(n, depth)
+	}
+	d.i--
+	return nil, d.errorf("unsupported type byte %#x", c)
+}
+
+func (d *decoder) str(n uint64) (string, error) {
+	b, err := d.bytes(n)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", d.errorf("string is not valid UTF-8")
+	}
+	return string(b), nil
+}
+
+func (d *decoder) arrayItems(n uint64, depth int) (interface{}, error) {
+	a := []interface{}{}
+	for i := uint64(0); i < n; i++ {
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func (d *decoder) mapItems(n uint64, depth int) (interface{}, error) {
+	o := []jsontree.Member{}
+	for i := uint64(0); i < n; i++ {
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch k.(type) {
+		case string, int64, uint64:
+		default:
+			return nil, d.errorf("map key of type %T is not a string or integer", k)
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, jsontree.Member{Key: k, Val: v})
+	}
+	return o, nil
+}
+
+// writeJSON writes a decoded value as JSON. Integer map keys are written
+// as decimal strings.
+func writeJSON(w *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		w.WriteString("null")
+	case bool:
+		w.WriteString(strconv.FormatBool(v))
+	case int64:
+		w.WriteString(strconv.FormatInt(v, 10))
+	case uint64:
+		w.WriteString(strconv.FormatUint(v, 10))
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			w.WriteString(`"NaN"`)
+		case math.IsInf(v, 1):
+			w.WriteString(`"Infinity"`)
+		case math.IsInf(v, -1):
+			w.WriteString(`"-Infinity"`)
+		default:
+			w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	case string:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+	case []byte:
+		w.WriteByte('"')
+		w.WriteString(base64.StdEncoding.EncodeToString(v))
+		w.WriteByte('"')
+	case []interface{}:
+		w.WriteByte('[')
+		for i, x := range v {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeJSON(w, x); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	case []jsontree.Member:
+		w.WriteByte('{')
+		for i, m := range v {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			key := m.Key
+			switch k := key.(type) {
+			case int64:
+				key = strconv.FormatInt(k, 10)
+			case uint64:
+				key = strconv.FormatUint(k, 10)
+			}
+			if err := writeJSON(w, key); err != nil {
+				return err
+			}
+			w.WriteByte(':')
+			if err := writeJSON(w, m.Val); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('}')
+	default:
+		return fmt.Errorf("protomsgpack: unexpected value %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package protomsgpack
+
+import (
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	durpb "github.com/golang/protobuf/ptypes/duration"
+	stpb "github.com/golang/protobuf/ptypes/struct"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	wpb "github.com/golang/protobuf/ptypes/wrappers"
+)
+
+func TestRoundTrip(t *testing.T) {
+	an, err := ptypes.MarshalAny(&pb.Simple{OString: proto.String("inside")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := &pb.Real{Value: proto.Float64(3)}
+	if err := proto.SetExtension(real, pb.E_Name, proto.String("ext")); err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.SetExtension(real, pb.E_Complex_RealExtension, &pb.Complex{Imaginary: proto.Float64(-1)}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []proto.Message{
+		&pb.Simple{
+			OBool:   proto.Bool(true),
+			OInt32:  proto.Int32(-32),
+			OInt64:  proto.Int64(math.MinInt64),
+			OUint32: proto.Uint32(math.MaxUint32),
+			OUint64: proto.Uint64(math.MaxUint64),
+			OSint32: proto.Int32(math.MinInt32),
+			OSint64: proto.Int64(math.MaxInt64),
+			OFloat:  proto.Float32(1.5),
+			ODouble: proto.Float64(0.1),
+			OString: proto.String("héllo\x00\"<>" + strings.Repeat("x", 300)),
+			OBytes:  []byte{0, 1, 0xfe, 0xff},
+		},
+		&pb.Repeats{
+			RBool:   []bool{true, false},
+			RInt32:  []int32{-1, -33, -129, -40000, 0, 1 << 30},
+			RInt64:  []int64{-1 << 40, 1 << 40},
+			RUint32: []uint32{0, 127, 128, 256, 65536},
+			RUint64: []uint64{math.MaxUint64},
+			RSint32: []int32{-5},
+			RSint64: []int64{-6},
+			RFloat:  []float32{-2.25, 1e30},
+			RDouble: []float64{1e300, -1e-300},
+			RString: []string{"", "a"},
+			RBytes:  [][]byte{{}, []byte("b")},
+		},
+		&pb.NonFinites{
+			FNan:  proto.Float32(float32(math.NaN())),
+			FPinf: proto.Float32(float32(math.Inf(1))),
+			DNinf: proto.Float64(math.Inf(-1)),
+		},
+		&pb.Widget{
+			Color:    pb.Widget_BLUE.Enum(),
+			RColor:   []pb.Widget_Color{pb.Widget_RED, pb.Widget_GREEN},
+			Simple:   &pb.Simple{OInt32: proto.Int32(1)},
+			RSimple:  []*pb.Simple{{OString: proto.String("x")}, {}},
+			Repeats:  &pb.Repeats{RString: []string{"y"}},
+			RRepeats: []*pb.Repeats{{RInt32: []int32{1}}},
+		},
+		&pb.Maps{
+			MInt64Str:   map[int64]string{-1: "minus one", 1 << 50: "big"},
+			MBoolSimple: map[bool]*pb.Simple{true: {OBool: proto.Bool(true)}},
+		},
+		&pb.MsgWithOneof{Union: &pb.MsgWithOneof_Salary{Salary: 31000}},
+		&pb.MsgWithOneof{Union: &pb.MsgWithOneof_MsgWithRequired{
+			MsgWithRequired: &pb.MsgWithRequired{Str: proto.String("req")},
+		}},
+		real,
+		&pb.KnownTypes{
+			An:  an,
+			Dur: &durpb.Duration{Seconds: 3, Nanos: 1000},
+			St: &stpb.Struct{Fields: map[string]*stpb.Value{
+				"n": {Kind: &stpb.Value_NumberValue{NumberValue: 2.5}},
+				"l": {Kind: &stpb.Value_ListValue{ListValue: &stpb.ListValue{Values: []*stpb.Value{
+					{Kind: &stpb.Value_NullValue{}},
+					{Kind: &stpb.Value_BoolValue{BoolValue: true}},
+				}}}},
+			}},
+			Ts:    &tspb.Timestamp{Seconds: 14e8, Nanos: 21e6},
+			Dbl:   &wpb.DoubleValue{Value: 1.25},
+			I64:   &wpb.Int64Value{Value: -3},
+			U32:   &wpb.UInt32Value{Value: 4},
+			Bool:  &wpb.BoolValue{Value: true},
+			Str:   &wpb.StringValue{Value: "s"},
+			Bytes: &wpb.BytesValue{Value: []byte("wow")},
+		},
+	}
+	for _, m := range []*Marshaler{{}, {UseFieldNumbers: true}} {
+		for _, want := range tests {
+			b, err := m.Marshal(want)
+			if err != nil {
+				t.Errorf("%+v.Marshal(%v): %v", m, want, err)
+				continue
+			}
+			got := proto.Clone(want)
+			got.Reset()
+			if err := Unmarshal(b, got); err != nil {
+				t.Errorf("Unmarshal(%+v.Marshal(%v)) [%x]: %v", m, want, b, err)
+				continue
+			}
+			if !proto.Equal(got, want) && !nonFinitesEqual(got, want) {
+				t.Errorf("%+v round trip of %v = %v", m, want, got)
+			}
+		}
+	}
+}
+
+// nonFinitesEqual compares NonFinites messages, treating NaNs as equal.
+func nonFinitesEqual(a, b proto.Message) bool {
+	x, ok1 := a.(*pb.NonFinites)
+	y, ok2 := b.(*pb.NonFinites)
+	return ok1 && ok2 && math.IsNaN(float64(x.GetFNan())) && math.IsNaN(float64(y.GetFNan())) &&
+		x.GetFPinf() == y.GetFPinf() && x.GetDNinf() == y.GetDNinf()
+}
+
+func TestMarshalEncoding(t *testing.T) {
+	tests := []struct {
+		m       proto.Message
+		numbers bool
+		want    string // hex
+	}{
+		{&pb.Simple{}, false, "80"},
+		{&pb.Simple{OInt32: proto.Int32(0)}, false, "81" + "a66f496e743332" + "00"},
+		{&pb.Simple{OInt32: proto.Int32(127)}, false, "81" + "a66f496e743332" + "7f"},
+		{&pb.Simple{OInt32: proto.Int32(128)}, false, "81" + "a66f496e743332" + "cc80"},
+		{&pb.Simple{OInt32: proto.Int32(1000)}, false, "81" + "a66f496e743332" + "cd03e8"},
+		{&pb.Simple{OInt32: proto.Int32(1000000)}, false, "81" + "a66f496e743332" + "ce000f4240"},
+		{&pb.Simple{OInt32: proto.Int32(-32)}, false, "81" + "a66f496e743332" + "e0"},
+		{&pb.Simple{OInt32: proto.Int32(-100)}, false, "81" + "a66f496e743332" + "d09c"},
+		{&pb.Simple{OInt32: proto.Int32(-1000)}, false, "81" + "a66f496e743332" + "d1fc18"},
+		{&pb.Simple{OInt32: proto.Int32(math.MinInt32)}, false, "81" + "a66f496e743332" + "d280000000"},
+		{&pb.Simple{OBool: proto.Bool(false)}, false, "81" + "a56f426f6f6c" + "c2"},
+		{&pb.Simple{ODouble: proto.Float64(1.5)}, false, "81" + "a76f446f75626c65" + "ca3fc00000"},
+		{&pb.Simple{ODouble: proto.Float64(1.1)}, false, "81" + "a76f446f75626c65" + "cb3ff199999999999a"},
+		{&pb.Simple{OInt64: proto.Int64(1)}, false, "81" + "a66f496e743634" + "a131"},
+		{&pb.Simple{OString: proto.String(strings.Repeat("a", 32))}, false,
+			"81" + "a76f537472696e67" + "d920" + strings.Repeat("61", 32)},
+		{&pb.Repeats{RInt32: []int32{1, 2}}, false, "81" + "a672496e743332" + "920102"},
+		{&wpb.StringValue{Value: "a"}, false, "a161"},
+		{&stpb.Value{Kind: &stpb.Value_NullValue{}}, false, "c0"},
+
+		{&pb.Simple{OInt32: proto.Int32(1)}, true, "81" + "02" + "01"},
+		{&pb.Widget{Simple: &pb.Simple{OBool: proto.Bool(true)}}, true, "81" + "0a" + "81" + "01" + "c3"},
+		{&pb.MsgWithOneof{Union: &pb.MsgWithOneof_Salary{Salary: 5}}, true, "81" + "02" + "a135"},
+		{&pb.KnownTypes{Str: &wpb.StringValue{Value: "a"}}, true, "81" + "0a" + "a161"},
+	}
+	for _, tt := range tests {
+		b, err := (&Marshaler{UseFieldNumbers: tt.numbers}).Marshal(tt.m)
+		if err != nil {
+			t.Errorf("Marshal(%v): %v", tt.m, err)
+			continue
+		}
+		if got := hex.EncodeToString(b); got != tt.want {
+			t.Errorf("Marshal(%v) [numbers=%v] = %s, want %s", tt.m, tt.numbers, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalEncodings(t *testing.T) {
+	// Encodings that Marshal does not produce but other MessagePack
+	// implementations may.
+	tests := []struct {
+		in   string // hex
+		want proto.Message
+	}{
+		// Non-minimal integer, string, array and map headers.
+		{"de0001" + "a66f496e743332" + "d30000000000000007", &pb.Simple{OInt32: proto.Int32(7)}},
+		{"81" + "da0006" + "6f496e743332" + "cf0000000000000008", &pb.Simple{OInt32: proto.Int32(8)}},
+		{"81" + "a672496e743332" + "dc0002" + "0102", &pb.Repeats{RInt32: []int32{1, 2}}},
+		{"81" + "a672496e743332" + "dd00000001" + "03", &pb.Repeats{RInt32: []int32{3}}},
+		// Integers for 64-bit fields and binary values for bytes fields.
+		{"81" + "a66f496e743634" + "d3ffffffffffffffff", &pb.Simple{OInt64: proto.Int64(-1)}},
+		{"81" + "a66f4279746573" + "c403" + "010203", &pb.Simple{OBytes: []byte{1, 2, 3}}},
+		{"81" + "a66f4279746573" + "c50001" + "ff", &pb.Simple{OBytes: []byte{0xff}}},
+		// Floating-point encodings of non-finite values.
+		{"81" + "a76f446f75626c65" + "cb7ff0000000000000", &pb.Simple{ODouble: proto.Float64(math.Inf(1))}},
+		{"81" + "a66f466c6f6174" + "caff800000", &pb.Simple{OFloat: proto.Float32(float32(math.Inf(-1)))}},
+		// Original field names and integer map keys.
+		{"81" + "a76f5f696e743332" + "05", &pb.Simple{OInt32: proto.Int32(5)}},
+		{"81" + "a96d496e7436345374" + "72" + "81" + "ff" + "a16d", &pb.Maps{MInt64Str: map[int64]string{-1: "m"}}},
+		// Mixed field numbers and names.
+		{"82" + "02" + "01" + "a56f426f6f6c" + "c3", &pb.Simple{OInt32: proto.Int32(1), OBool: proto.Bool(true)}},
+	}
+	for _, tt := range tests {
+		b, err := hex.DecodeString(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := proto.Clone(tt.want)
+		got.Reset()
+		if err := Unmarshal(b, got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		in   string // hex
+		want string
+	}{
+		{"", "unexpected end of input"},
+		{"81a66f496e743332", "unexpected end of input"},
+		{"81a66f496e743332cd01", "unexpected end of input"},
+		{"8001", "trailing data"},
+		{"81" + "c3" + "01", "is not a string or integer"},
+		{"81" + "63" + "01", "has no field number 99"},
+		{"81" + "a66f496e743332" + "d40100", "unsupported type byte 0xd4"},
+		{"81" + "a66f496e743332" + "c1", "unsupported type byte 0xc1"},
+		{"81" + "a76f537472696e67" + "a1ff", "not valid UTF-8"},
+		{strings.Repeat("91", maxDepth+2), "maximum nesting depth"},
+	}
+	for _, tt := range tests {
+		b, err := hex.DecodeString(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Unmarshal(b, new(pb.Simple))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, want error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package protoxml marshals and unmarshals protocol buffer messages as XML.
+
+A message is written as an element whose children are its populated
+fields, each named by the field's name in the .proto file. Scalar values
+are the text of their element, repeated fields are sibling elements with
+the same name, and map fields are repeated elements with key and value
+children, as in the text format. For example:
+
+	<proto3_proto.Message>
+	  <name>Gopher</name>
+	  <key>1</key>
+	  <key>2</key>
+	  <nested><bunny>Flopsy</bunny></nested>
+	  <terrain><key>home</key><value><bunny>Mopsy</bunny></value></terrain>
+	</proto3_proto.Message>
+
+Numbers use the Go syntax of package strconv, enums use their value names,
+and bytes use standard base64. A google.protobuf.Any whose type is linked
+into the program is written in expanded form: its element has a type
+attribute holding the type URL, and the fields of the contained message
+as children. Extensions are not represented.
+*/
+package protoxml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/internal/textfields"
+	"github.com/golang/protobuf/proto"
+)
+
+// typeAttr is the attribute holding the type URL of an expanded Any.
+const typeAttr = "type"
+
+// maxDepth is the maximum nesting of messages in the input.
+const maxDepth = 10000
+
+// Marshal returns the XML encoding of m. The root element is named by the
+// message's full name.
+func Marshal(m proto.Message) ([]byte, error) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("protoxml: unsupported message type %T", m)
+	}
+	name := proto.MessageName(m)
+	if name == "" {
+		name = "message"
+	}
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	if v.IsNil() {
+		v = reflect.New(v.Type().Elem())
+	}
+	if err := writeMessage(enc, name, v); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type wkt interface {
+	XXX_WellKnownType() string
+}
+
+// anyMessage returns the message contained in the Any pv, if pv is an Any
+// whose contents can be unmarshaled.
+func anyMessage(pv reflect.Value) (typeURL string, m proto.Message) {
+	if w, ok := pv.Interface().(wkt); !ok || w.XXX_WellKnownType() != "Any" {
+		return "", nil
+	}
+	sv := pv.Elem()
+	turl, val := sv.FieldByName("TypeUrl"), sv.FieldByName("Value")
+	if turl.Kind() != reflect.String || val.Kind() != reflect.Slice {
+		return "", nil
+	}
+	mt := proto.MessageType(turl.String()[strings.LastIndex(turl.String(), "/")+1:])
+	if mt == nil {
+		return "", nil
+	}
+	m, ok := reflect.New(mt.Elem()).Interface().(proto.Message)
+	if !ok || proto.Unmarshal(val.Bytes(), m) != nil {
+		return "", nil
+	}
+	return turl.String(), m
+}
+
+// writeMessage writes the message pv, a non-nil pointer to a struct, as
+// the element name.
+func writeMessage(enc *xml.Encoder, name string, pv reflect.Value) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if turl, m := anyMessage(pv); m != nil {
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: typeAttr}, Value: turl}}
+		pv = reflect.ValueOf(m)
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	err := textfields.Range(pv.Elem(), func(prop *proto.Properties, v reflect.Value, oneof bool) error {
+		if oneof {
+			return writeValue(enc, prop.OrigName, prop, v)
+		}
+		return writeField(enc, prop, v)
+	})
+	if err != nil {
+		return err
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func writeField(enc *xml.Encoder, prop *proto.Properties, v reflect.Value) error {
+	name := prop.OrigName
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < v.Len(); i++ {
+			if err := writeValue(enc, name, prop, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case v.Kind() == reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keyLess(keys[i], keys[j]) })
+		for _, k := range keys {
+			start := xml.StartElement{Name: xml.Name{Local: name}}
+			if err := enc.EncodeToken(start); err != nil {
+				return err
+			}
+			if err := writeValue(enc, "key", prop.MapKeyProp, k); err != nil {
+				return err
+			}
+			if err := writeValue(enc, "value", prop.MapValProp, v.MapIndex(k)); err != nil {
+				return err
+			}
+			if err := enc.EncodeToken(start.End()); err != nil {
+				return err
+			}
+		}
+	case textfields.IsSet(v):
+		return writeValue(enc, name, prop, v)
+	}
+	return nil
+}
+
+func writeValue(enc *xml.Encoder, name string, prop *proto.Properties, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return fmt.Errorf("protoxml: nil value in field %s", name)
+		}
+		if v.Elem().Kind() == reflect.Struct {
+			return writeMessage(enc, name, v)
+		}
+		v = v.Elem()
+	}
+	s := textfields.FormatScalar(v, prop)
+	if !isXMLText(s) {
+		return fmt.Errorf("protoxml: field %s has a value that cannot be represented in XML: %q", name, s)
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := enc.EncodeToken(xml.CharData(s)); err != nil {
+		return err
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// isXMLText reports whether s consists of characters allowed in XML 1.0
+// documents.
+func isXMLText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !(r == 0x09 || r == 0x0A || r == 0x0D || r >= 0x20 && r <= 0xD7FF ||
+			r >= 0xE000 && r <= 0xFFFD || r >= 0x10000 && r <= 0x10FFFF) {
+			return false
+		}
+	}
+	return true
+}
+
+// keyLess orders map keys.
+func keyLess(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint32, reflect.Uint64:
+		return a.Uint() < b.Uint()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+	return a.String() < b.String()
+}
+
+// Unmarshal parses the XML-encoded data b into m.
+// m is reset before unmarshaling. The name of the root element is ignored.
+func Unmarshal(b []byte, m proto.Message) error {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct || v.IsNil() {
+		return fmt.Errorf("protoxml: unsupported message type %T", m)
+	}
+	m.Reset()
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("protoxml: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if err := readMessage(d, tok, v, 0); err != nil {
+				return err
+			}
+			return checkEnd(d)
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				return fmt.Errorf("protoxml: unexpected text %q", tok)
+			}
+		}
+	}
+}
+
+// checkEnd reports an error if anything but whitespace, comments and
+// processing instructions follow the root element.
+func checkEnd(d *xml.Decoder) error {
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("protoxml: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return fmt.Errorf("protoxml: unexpected element <%s> after the message", tok.Name.Local)
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				return fmt.Errorf("protoxml: unexpected text %q after the message", tok)
+			}
+		}
+	}
+}
+
+// readMessage reads the children of start into pv, a non-nil pointer to
+// a message struct, through the end of the element. depth is the number
+// of messages start is nested in.
+func readMessage(d *xml.Decoder, start xml.StartElement, pv reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("protoxml: exceeded maximum nesting depth %d", maxDepth)
+	}
+	for _, a := range start.Attr {
+		if a.Name.Space != "" || a.Name.Local != typeAttr {
+			continue
+		}
+		// An expanded Any.
+		if w, ok := pv.Interface().(wkt); !ok || w.XXX_WellKnownType() != "Any" {
+			return fmt.Errorf("protoxml: <%s> has a %s attribute but is not a google.protobuf.Any", start.Name.Local, typeAttr)
+		}
+		mt := proto.MessageType(a.Value[strings.LastIndex(a.Value, "/")+1:])
+		if mt == nil {
+			return fmt.Errorf("protoxml: unknown message type %q", a.Value)
+		}
+		m := reflect.New(mt.Elem())
+		if err := readFields(d, start, m, depth); err != nil {
+			return err
+		}
+		b, err := proto.Marshal(m.Interface().(proto.Message))
+		if err != nil {
+			return err
+		}
+		pv.Elem().FieldByName("TypeUrl").SetString(a.Value)
+		pv.Elem().FieldByName("Value").SetBytes(b)
+		return nil
+	}
+	return readFields(d, start, pv, depth)
+}
+
+func readFields(d *xml.Decoder, start xml.StartElement, pv reflect.Value, depth int) error {
+	sv := pv.Elem()
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("protoxml: %v", err)
+		}
+		var child xml.StartElement
+		switch tok := tok.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				return fmt.Errorf("protoxml: unexpected text %q in <%s>", tok, start.Name.Local)
+			}
+			continue
+		case xml.StartElement:
+			child = tok
+		default:
+			continue
+		}
+
+		fv, prop := textfields.Field(sv, child.Name.Local)
+		if prop == nil {
+			return fmt.Errorf("protoxml: unknown field <%s> in %v", child.Name.Local, sv.Type())
+		}
+
+		switch {
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8:
+			elem := reflect.New(fv.Type().Elem()).Elem()
+			if err := readValue(d, child, prop, elem, depth); err != nil {
+				return err
+			}
+			fv.Set(reflect.Append(fv, elem))
+		case fv.Kind() == reflect.Map:
+			if err := readMapEntry(d, child, prop, fv, depth); err != nil {
+				return err
+			}
+		default:
+			if err := readValue(d, child, prop, fv, depth); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readMapEntry reads a map entry element with key and value children
+// into the map fv.
+func readMapEntry(d *xml.Decoder, start xml.StartElement, prop *proto.Properties, fv reflect.Value, depth int) error {
+	mt := fv.Type()
+	k := reflect.New(mt.Key()).Elem()
+	v := reflect.New(mt.Elem()).Elem()
+	if v.Kind() == reflect.Ptr {
+		// A missing value is an empty message.
+		v.Set(reflect.New(mt.Elem().Elem()))
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("protoxml: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.EndElement:
+			if fv.IsNil() {
+				fv.Set(reflect.MakeMap(mt))
+			}
+			fv.SetMapIndex(k, v)
+			return nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				return fmt.Errorf("protoxml: unexpected text %q in <%s>", tok, start.Name.Local)
+			}
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "key":
+				err = readValue(d, tok, prop.MapKeyProp, k, depth)
+			case "value":
+				err = readValue(d, tok, prop.MapValProp, v, depth)
+			default:
+				err = fmt.Errorf("protoxml: unknown element <%s> in map entry <%s>", tok.Name.Local, start.Name.Local)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readValue reads the element start into v, a field or element of a
+// message at the given depth.
+func readValue(d *xml.Decoder, start xml.StartElement, prop *proto.Properties, v reflect.Value, depth int) error {
+	if v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return readMessage(d, start, v, depth+1)
+	}
+	var text []byte
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("protoxml: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text = append(text, tok...)
+			continue
+		case xml.StartElement:
+			return fmt.Errorf("protoxml: unexpected element <%s> in scalar field <%s>", tok.Name.Local, start.Name.Local)
+		case xml.EndElement:
+		default:
+			continue
+		}
+		break
+	}
+	if v.Kind() == reflect.Ptr {
+		nv := reflect.New(v.Type().Elem())
+		if err := parseScalar(nv.Elem(), prop, string(text)); err != nil {
+			return fmt.Errorf("protoxml: field <%s>: %v", start.Name.Local, err)
+		}
+		v.Set(nv)
+		return nil
+	}
+	if err := parseScalar(v, prop, string(text)); err != nil {
+		return fmt.Errorf("protoxml: field <%s>: %v", start.Name.Local, err)
+	}
+	return nil
+}
+
+// parseScalar sets the scalar v from the text of its element, ignoring
+// surrounding whitespace except in strings.
+func parseScalar(v reflect.Value, prop *proto.Properties, s string) error {
+	if v.Kind() != reflect.String {
+		s = strings.TrimSpace(s)
+	}
+	return textfields.ParseScalar(v, prop, s)
+}
@@ -0,0 +1,214 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package protoxml_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb3 "github.com/golang/protobuf/proto/proto3_proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
+	"github.com/golang/protobuf/protoxml"
+	"github.com/golang/protobuf/ptypes"
+	anypb "github.com/golang/protobuf/ptypes/any"
+)
+
+func TestRoundTrip(t *testing.T) {
+	inner, err := ptypes.MarshalAny(&pb.MyMessage{Count: proto.Int32(4), Name: proto.String("inner")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer, err := ptypes.MarshalAny(&pb3.Message{Name: "outer", Anything: inner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []proto.Message{
+		&pb.GoTest{
+			Kind:                   pb.GoTest_TIME.Enum(),
+			Table:                  proto.String("<table> & \"chairs\"\r\n\t"),
+			Param:                  proto.Int32(math.MinInt32),
+			RequiredField:          &pb.GoTestField{Label: proto.String(""), Type: proto.String(" t ")},
+			RepeatedField:          []*pb.GoTestField{{Label: proto.String("a"), Type: proto.String("b")}, {}},
+			F_BoolRequired:         proto.Bool(true),
+			F_Int64Required:        proto.Int64(math.MinInt64),
+			F_Uint32Required:       proto.Uint32(math.MaxUint32),
+			F_Uint64Required:       proto.Uint64(math.MaxUint64),
+			F_FloatRequired:        proto.Float32(float32(math.Inf(-1))),
+			F_DoubleRequired:       proto.Float64(0.1),
+			F_StringRequired:       proto.String("héllo"),
+			F_BytesRequired:        []byte{0, 1, 0xff},
+			F_Sint32Required:       proto.Int32(-7),
+			F_Sint64Required:       proto.Int64(-8),
+			F_Sfixed32Required:     proto.Int32(-9),
+			F_Sfixed64Required:     proto.Int64(-10),
+			F_Int32Repeated:        []int32{1, -1},
+			F_StringRepeated:       []string{"", "x"},
+			F_BytesRepeated:        [][]byte{{}, []byte("y")},
+			F_DoubleRepeatedPacked: []float64{1e300},
+			Requiredgroup:          &pb.GoTest_RequiredGroup{RequiredField: proto.String("group")},
+			OptionalField:          &pb.GoTestField{},
+		},
+		&pb3.Message{
+			Name:         "Gopher",
+			Hilarity:     pb3.Message_PUNS,
+			HeightInCm:   178,
+			Data:         []byte("data"),
+			ResultCount:  -1,
+			TrueScotsman: true,
+			Score:        0.5,
+			Key:          []uint64{1, 2},
+			ShortKey:     []int32{-3},
+			Nested:       &pb3.Nested{Bunny: "Flopsy"},
+			RFunny:       []pb3.Message_Humour{pb3.Message_SLAPSTICK, pb3.Message_UNKNOWN},
+			Terrain:      map[string]*pb3.Nested{"home": {Bunny: "Mopsy", Cute: true}, "away": {}},
+			Proto2Field:  &pb.SubDefaults{N: proto.Int64(0)},
+			Anything:     outer,
+			ManyThings:   []*anypb.Any{inner, {TypeUrl: "example.com/unknown.Type", Value: []byte{1}}},
+			Children:     []*pb3.Message{{Name: "child"}, {}},
+			StringMap:    map[string]string{"": "empty key", "k": ""},
+		},
+		&pb.Communique{Union: &pb.Communique_Number{Number: 0}},
+		&pb.Communique{Union: &pb.Communique_Msg{Msg: &pb.Strings{}}},
+		&pb.Communique{Union: &pb.Communique_Col{Col: pb.MyMessage_BLUE}},
+		&pb.MessageWithMap{
+			NameMapping: map[int32]string{-1: "minus one", 2: "two"},
+			ByteMapping: map[bool][]byte{true: []byte("yes")},
+		},
+	}
+	for _, want := range tests {
+		b, err := protoxml.Marshal(want)
+		if err != nil {
+			t.Errorf("Marshal(%v): %v", want, err)
+			continue
+		}
+		got := proto.Clone(want)
+		if err := protoxml.Unmarshal(b, got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", b, err)
+			continue
+		}
+		if !proto.Equal(got, want) {
+			t.Errorf("Unmarshal(%s)\n got %v\nwant %v", b, got, want)
+		}
+	}
+}
+
+func TestMarshalFormat(t *testing.T) {
+	inner, err := ptypes.MarshalAny(&pb.MyMessage{Count: proto.Int32(4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		m    proto.Message
+		want string
+	}{
+		{&pb3.Message{}, "<proto3_proto.Message></proto3_proto.Message>"},
+		{
+			&pb3.Message{Name: "a<b", Key: []uint64{1, 2}, Nested: &pb3.Nested{}},
+			"<proto3_proto.Message><name>a&lt;b</name><key>1</key><key>2</key><nested></nested></proto3_proto.Message>",
+		},
+		{
+			&pb3.Message{Terrain: map[string]*pb3.Nested{"b": {Cute: true}, "a": {}}},
+			"<proto3_proto.Message>" +
+				"<terrain><key>a</key><value></value></terrain>" +
+				"<terrain><key>b</key><value><cute>true</cute></value></terrain>" +
+				"</proto3_proto.Message>",
+		},
+		{
+			&pb3.Message{Anything: inner},
+			`<proto3_proto.Message><anything type="type.googleapis.com/test_proto.MyMessage">` +
+				"<count>4</count></anything></proto3_proto.Message>",
+		},
+	}
+	for _, tt := range tests {
+		b, err := protoxml.Marshal(tt.m)
+		if err != nil {
+			t.Errorf("Marshal(%v): %v", tt.m, err)
+			continue
+		}
+		if got := string(b); got != tt.want {
+			t.Errorf("Marshal(%v)\n got %s\nwant %s", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalInvalidText(t *testing.T) {
+	m := &pb3.Message{Name: "nul\x00"}
+	if b, err := protoxml.Marshal(m); err == nil {
+		t.Errorf("Marshal(%v) = %s, want error", m, b)
+	}
+}
+
+func TestUnmarshalDepth(t *testing.T) {
+	const depth = 10000
+	in := "<m>" + strings.Repeat("<submessage>", depth) + "<name>deep</name>" +
+		strings.Repeat("</submessage>", depth) + "</m>"
+	m := new(pb3.Message)
+	if err := protoxml.Unmarshal([]byte(in), m); err != nil {
+		t.Fatalf("Unmarshal at depth %d: %v", depth, err)
+	}
+	for i := 0; i < depth; i++ {
+		m = m.Submessage
+	}
+	if m.GetName() != "deep" {
+		t.Errorf("innermost message = %v, want name deep", m)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "EOF"},
+		{"<m><name>x</name>", "EOF"},
+		{"<m><bogus/></m>", "unknown field <bogus>"},
+		{"<m>text</m>", "unexpected text"},
+		{"<m><name><x/></name></m>", "unexpected element <x> in scalar field"},
+		{"<m><height_in_cm>-1</height_in_cm></m>", "invalid value"},
+		{"<m><hilarity>FUNNY</hilarity></m>", "invalid value"},
+		{"<m><data>!</data></m>", "invalid value"},
+		{"<m><terrain><k/></terrain></m>", "unknown element <k> in map entry"},
+		{`<m><nested type="x"/></m>`, "is not a google.protobuf.Any"},
+		{`<m><anything type="example.com/unknown.Type"/></m>`, "unknown message type"},
+		{"<m></m><m></m>", "after the message"},
+		{"<m>" + strings.Repeat("<submessage>", 10001), "maximum nesting depth"},
+		{"<m>" + strings.Repeat("<children>", 10001), "maximum nesting depth"},
+	}
+	for _, tt := range tests {
+		err := protoxml.Unmarshal([]byte(tt.in), new(pb3.Message))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Unmarshal(%q) = %v, want error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
@@ -46,6 +46,8 @@ import (
 const googleApis = "type.googleapis.com/"
 
 // AnyMessageName returns the name of the message contained in a google.protobuf.Any message.
+// The name is the part of the type URL after the last '/', so any host
+// prefix is ignored; a type URL with no '/' is taken to be a bare name.
 //
 // Note that regular type assertions should be done using the Is
 // function. AnyMessageName is provided for less common use cases like filtering a
@@ -54,22 +56,46 @@ func AnyMessageName(any *any.Any) (string, error) {
 	if any == nil {
 		return "", fmt.Errorf("message is nil")
 	}
-	slash := strings.LastIndex(any.TypeUrl, "/")
-	if slash < 0 {
+	name := any.TypeUrl[strings.LastIndex(any.TypeUrl, "/")+1:]
+	if name == "" {
 		return "", fmt.Errorf("message type url %q is invalid", any.TypeUrl)
 	}
-	return any.TypeUrl[slash+1:], nil
+	return name, nil
 }
 
 // MarshalAny takes the protocol buffer and encodes it into google.protobuf.Any.
+// An empty message is encoded as an empty, non-nil value.
 func MarshalAny(pb proto.Message) (*any.Any, error) {
 	value, err := proto.Marshal(pb)
 	if err != nil {
 		return nil, err
 	}
+	if value == nil {
+		value = []byte{}
+	}
 	return &any.Any{TypeUrl: googleApis + proto.MessageName(pb), Value: value}, nil
 }
 
+// MarshalAnyTo encodes the protocol buffer into the existing
+// google.protobuf.Any dst, replacing its type URL and value.
+// As with proto.Marshal, if pb is missing required fields dst is still
+// filled in and a *proto.RequiredNotSetError is returned.
+func MarshalAnyTo(dst *any.Any, pb proto.Message) error {
+	if dst == nil {
+		return fmt.Errorf("any: destination message is nil")
+	}
+	value, err := proto.Marshal(pb)
+	if _, ok := err.(*proto.RequiredNotSetError); err != nil && !ok {
+		return err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	dst.TypeUrl = googleApis + proto.MessageName(pb)
+	dst.Value = value
+	return err
+}
+
 // DynamicAny is a value that can be passed to UnmarshalAny to automatically
 // allocate a proto.Message for the type specified in a google.protobuf.Any
 // message. The allocated message is stored in the embedded proto.Message.
@@ -83,6 +109,45 @@ type DynamicAny struct {
 	proto.Message
 }
 
+// TypeNotFoundError is returned when the message type named by a
+// google.protobuf.Any message isn't known.
+type TypeNotFoundError struct {
+	Name string // the name of the message type
+}
+
+func (e *TypeNotFoundError) Error() string {
+	return fmt.Sprintf("any: message type %q isn't linked in", e.Name)
+}
+
+// AnyResolver resolves the type URL of a google.protobuf.Any message into
+// a new, empty message of that type. It has the same method as
+// jsonpb.AnyResolver, so a resolver may be shared between the two.
+type AnyResolver interface {
+	Resolve(typeUrl string) (proto.Message, error)
+}
+
+// MultiResolver is an AnyResolver that consults a list of resolvers in
+// order and returns the first message resolved without error. A nil
+// element stands for the types linked into the binary. If every resolver
+// fails, Resolve returns a *TypeNotFoundError.
+type MultiResolver []AnyResolver
+
+// Resolve implements AnyResolver.
+func (mr MultiResolver) Resolve(typeUrl string) (proto.Message, error) {
+	for _, r := range mr {
+		if r == nil {
+			if m, err := Empty(&any.Any{TypeUrl: typeUrl}); err == nil {
+				return m, nil
+			}
+			continue
+		}
+		if m, err := r.Resolve(typeUrl); err == nil {
+			return m, nil
+		}
+	}
+	return nil, &TypeNotFoundError{Name: typeUrl[strings.LastIndex(typeUrl, "/")+1:]}
+}
+
 // Empty returns a new proto.Message of the type specified in a
 // google.protobuf.Any message. It returns an error if corresponding message
 // type isn't linked in.
@@ -94,7 +159,7 @@ func Empty(any *any.Any) (proto.Message, error) {
 
 	t := proto.MessageType(aname)
 	if t == nil {
-		return nil, fmt.Errorf("any: message type %q isn't linked in", aname)
+		return nil, &TypeNotFoundError{Name: aname}
 	}
 	return reflect.New(t.Elem()).Interface().(proto.Message), nil
 }
@@ -102,6 +167,8 @@ func Empty(any *any.Any) (proto.Message, error) {
 // UnmarshalAny parses the protocol buffer representation in a google.protobuf.Any
 // message and places the decoded result in pb. It returns an error if type of
 // contents of Any message does not match type of pb message.
+// An empty or missing value decodes as an empty message, but a missing
+// type URL is an error.
 //
 // pb can be a proto.Message, or a *DynamicAny.
 func UnmarshalAny(any *any.Any, pb proto.Message) error {
@@ -128,7 +195,35 @@ func UnmarshalAny(any *any.Any, pb proto.Message) error {
 	return proto.Unmarshal(any.Value, pb)
 }
 
+// UnmarshalAnyNew allocates a new message of the type named in a
+// google.protobuf.Any message and decodes the Any's value into it.
+// The type is resolved with r, or with the types linked into the binary if
+// r is nil; in that case an unknown type is reported as a
+// *TypeNotFoundError, so that callers can fall back to handling the raw
+// value. Errors from r are returned unchanged.
+func UnmarshalAnyNew(any *any.Any, r AnyResolver) (proto.Message, error) {
+	var m proto.Message
+	var err error
+	if r == nil {
+		m, err = Empty(any)
+	} else if any == nil {
+		err = fmt.Errorf("message is nil")
+	} else {
+		m, err = r.Resolve(any.TypeUrl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := proto.Unmarshal(any.Value, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Is returns true if any value contains a given message type.
+// Only the message name at the end of the type URL is compared, so
+// "type.googleapis.com/pkg.M", "example.com/pkg.M" and "pkg.M" all match
+// a message named pkg.M.
 func Is(any *any.Any, pb proto.Message) bool {
 	// The following is equivalent to AnyMessageName(any) == proto.MessageName(pb),
 	// but it avoids scanning TypeUrl for the slash.
@@ -137,5 +232,6 @@ func Is(any *any.Any, pb proto.Message) bool {
 	}
 	name := proto.MessageName(pb)
 	prefix := len(any.TypeUrl) - len(name)
-	return prefix >= 1 && any.TypeUrl[prefix-1] == '/' && any.TypeUrl[prefix:] == name
+	return name != "" && prefix >= 0 && any.TypeUrl[prefix:] == name &&
+		(prefix == 0 || any.TypeUrl[prefix-1] == '/')
 }
@@ -86,8 +86,8 @@ func TestIsCornerCases(t *testing.T) {
 		t.Errorf("message with nil type url incorrectly claimed to be %q", proto.MessageName(m))
 	}
 	noPrefix := &any.Any{TypeUrl: proto.MessageName(m)}
-	if Is(noPrefix, m) {
-		t.Errorf("message with type url %q incorrectly claimed to be %q", noPrefix.TypeUrl, proto.MessageName(m))
+	if !Is(noPrefix, m) {
+		t.Errorf("message with type url %q didn't satisfy Is for type %q", noPrefix.TypeUrl, proto.MessageName(m))
 	}
 	shortPrefix := &any.Any{TypeUrl: "/" + proto.MessageName(m)}
 	if !Is(shortPrefix, m) {
@@ -95,6 +95,37 @@ func TestIsCornerCases(t *testing.T) {
 	}
 }
 
+func TestIsTypeURLs(t *testing.T) {
+	m := &pb.FileDescriptorProto{}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"type.googleapis.com/google.protobuf.FileDescriptorProto", true},
+		{"foo.example/google.protobuf.FileDescriptorProto", true},
+		{"foo.example/a/b/google.protobuf.FileDescriptorProto", true},
+		{"google.protobuf.FileDescriptorProto", true},
+		{"/google.protobuf.FileDescriptorProto", true},
+		{"protobuf.FileDescriptorProto", false},
+		{"foo.example/protobuf.FileDescriptorProto", false},
+		{"xgoogle.protobuf.FileDescriptorProto", false},
+		{"foo.example/xgoogle.protobuf.FileDescriptorProto", false},
+		{"foo.example.google.protobuf.FileDescriptorProto", false},
+		{"google.protobuf.FileDescriptorProto/", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		a := &any.Any{TypeUrl: tt.url}
+		if got := Is(a, m); got != tt.want {
+			t.Errorf("Is(%q, FileDescriptorProto) = %v, want %v", tt.url, got, tt.want)
+		}
+		name, err := AnyMessageName(a)
+		if got := err == nil && name == proto.MessageName(m); got != tt.want {
+			t.Errorf("AnyMessageName(%q) = %q, %v; want match %v", tt.url, name, err, tt.want)
+		}
+	}
+}
+
 func TestUnmarshalDynamic(t *testing.T) {
 	want := &pb.FileDescriptorProto{Name: proto.String("foo")}
 	a, err := MarshalAny(want)
@@ -139,9 +170,11 @@ func TestEmptyCornerCases(t *testing.T) {
 	}
 	want := &pb.FileDescriptorProto{}
 	noPrefix := &any.Any{TypeUrl: proto.MessageName(want)}
-	_, err = Empty(noPrefix)
-	if err == nil {
-		t.Errorf("expected Empty for any type %q to fail", noPrefix.TypeUrl)
+	if got, err := Empty(noPrefix); err != nil || !proto.Equal(got, want) {
+		t.Errorf("Empty for any type %q = %v, %v; want %v", noPrefix.TypeUrl, got, err, want)
+	}
+	if _, err := Empty(&any.Any{TypeUrl: "type.googleapis.com/"}); err == nil {
+		t.Error("expected Empty for a type url with no name to fail")
 	}
 	shortPrefix := &any.Any{TypeUrl: "/" + proto.MessageName(want)}
 	got, err := Empty(shortPrefix)
@@ -152,3 +185,189 @@ func TestEmptyCornerCases(t *testing.T) {
 		t.Errorf("Empty for any type %q differs, got %q, want %q", shortPrefix.TypeUrl, got, want)
 	}
 }
+
+func TestMarshalAnyTo(t *testing.T) {
+	dst := &any.Any{TypeUrl: "example.com/stale", Value: []byte("stale")}
+	src := &pb.FileDescriptorProto{Name: proto.String("foo.proto")}
+	if err := MarshalAnyTo(dst, src); err != nil {
+		t.Fatalf("MarshalAnyTo: %v", err)
+	}
+	if want := "type.googleapis.com/google.protobuf.FileDescriptorProto"; dst.TypeUrl != want {
+		t.Errorf("TypeUrl = %q, want %q", dst.TypeUrl, want)
+	}
+	got := &pb.FileDescriptorProto{}
+	if err := UnmarshalAny(dst, got); err != nil || !proto.Equal(got, src) {
+		t.Errorf("UnmarshalAny = %v, %v; want %v, nil", got, err, src)
+	}
+
+	if err := UnmarshalAny(dst, &pb.DescriptorProto{}); err == nil {
+		t.Errorf("UnmarshalAny into mismatched type succeeded, want error")
+	}
+	if err := UnmarshalAny(&any.Any{Value: dst.Value}, &pb.FileDescriptorProto{}); err == nil {
+		t.Errorf("UnmarshalAny with empty type URL succeeded, want error")
+	}
+	if err := MarshalAnyTo(nil, src); err == nil {
+		t.Errorf("MarshalAnyTo(nil) succeeded, want error")
+	}
+}
+
+func TestMarshalAnyToPartial(t *testing.T) {
+	// NamePart has required fields; the partial message is still encoded.
+	src := &pb.UninterpretedOption_NamePart{NamePart: proto.String("n")}
+	dst := &any.Any{}
+	err := MarshalAnyTo(dst, src)
+	if _, ok := err.(*proto.RequiredNotSetError); !ok {
+		t.Fatalf("MarshalAnyTo = %v, want *proto.RequiredNotSetError", err)
+	}
+	got := &pb.UninterpretedOption_NamePart{}
+	err = UnmarshalAny(dst, got)
+	if _, ok := err.(*proto.RequiredNotSetError); !ok {
+		t.Errorf("UnmarshalAny = %v, want *proto.RequiredNotSetError", err)
+	}
+	if !proto.Equal(got, src) {
+		t.Errorf("UnmarshalAny = %v, want %v", got, src)
+	}
+}
+
+// mapResolver resolves type URLs from a fixed set of message constructors.
+type mapResolver map[string]func() proto.Message
+
+func (r mapResolver) Resolve(typeUrl string) (proto.Message, error) {
+	if f, ok := r[typeUrl]; ok {
+		return f(), nil
+	}
+	return nil, &TypeNotFoundError{Name: typeUrl}
+}
+
+func TestUnmarshalAnyNew(t *testing.T) {
+	src := &pb.FileDescriptorProto{Name: proto.String("foo.proto")}
+	a, err := MarshalAny(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Types linked into the binary.
+	got, err := UnmarshalAnyNew(a, nil)
+	if err != nil || !proto.Equal(got, src) {
+		t.Errorf("UnmarshalAnyNew(%v, nil) = %v, %v; want %v, nil", a, got, err, src)
+	}
+
+	// A private resolver, which may use type URLs unknown to the proto package.
+	r := mapResolver{
+		"example.com/private.Type": func() proto.Message { return &pb.FileDescriptorProto{} },
+	}
+	private := &any.Any{TypeUrl: "example.com/private.Type", Value: a.Value}
+	got, err = UnmarshalAnyNew(private, r)
+	if err != nil || !proto.Equal(got, src) {
+		t.Errorf("UnmarshalAnyNew(%v, r) = %v, %v; want %v, nil", private, got, err, src)
+	}
+	if _, err := UnmarshalAnyNew(a, r); err == nil {
+		t.Errorf("UnmarshalAnyNew(%v, r) succeeded for a type unknown to r", a)
+	}
+
+	// Unknown types are reported as *TypeNotFoundError.
+	for _, tt := range []struct {
+		any  *any.Any
+		r    AnyResolver
+		name string
+	}{
+		{&any.Any{TypeUrl: "type.googleapis.com/no.such.Type"}, nil, "no.such.Type"},
+		{&any.Any{TypeUrl: "example.com/no.such.Type"}, r, "example.com/no.such.Type"},
+	} {
+		_, err := UnmarshalAnyNew(tt.any, tt.r)
+		if e, ok := err.(*TypeNotFoundError); !ok || e.Name != tt.name {
+			t.Errorf("UnmarshalAnyNew(%v) error = %v, want *TypeNotFoundError for %q", tt.any, err, tt.name)
+		}
+	}
+
+	if _, err := UnmarshalAnyNew(nil, r); err == nil {
+		t.Errorf("UnmarshalAnyNew(nil, r) succeeded, want error")
+	}
+	bad := &any.Any{TypeUrl: a.TypeUrl, Value: []byte{0x0a, 0x05}}
+	if _, err := UnmarshalAnyNew(bad, nil); err == nil {
+		t.Errorf("UnmarshalAnyNew with truncated value succeeded, want error")
+	}
+}
+
+func TestMultiResolver(t *testing.T) {
+	first := mapResolver{
+		"example.com/first.Type": func() proto.Message { return &pb.FileDescriptorProto{} },
+	}
+	second := mapResolver{
+		"example.com/second.Type": func() proto.Message { return &pb.DescriptorProto{} },
+		"example.com/first.Type":  func() proto.Message { return &pb.EnumDescriptorProto{} },
+	}
+	mr := MultiResolver{first, second, nil}
+
+	for _, tt := range []struct {
+		url  string
+		want proto.Message
+	}{
+		{"example.com/first.Type", &pb.FileDescriptorProto{}},
+		{"example.com/second.Type", &pb.DescriptorProto{}}, // only in the second resolver
+		{"type.googleapis.com/google.protobuf.FieldDescriptorProto", &pb.FieldDescriptorProto{}},
+	} {
+		got, err := mr.Resolve(tt.url)
+		if err != nil {
+			t.Errorf("Resolve(%q): %v", tt.url, err)
+			continue
+		}
+		if proto.MessageName(got) != proto.MessageName(tt.want) {
+			t.Errorf("Resolve(%q) = %T, want %T", tt.url, got, tt.want)
+		}
+	}
+
+	_, err := mr.Resolve("example.com/no.such.Type")
+	if e, ok := err.(*TypeNotFoundError); !ok || e.Name != "no.such.Type" {
+		t.Errorf("Resolve of unknown type error = %v, want *TypeNotFoundError for %q", err, "no.such.Type")
+	}
+	if _, err := (MultiResolver{}).Resolve("type.googleapis.com/google.protobuf.FieldDescriptorProto"); err == nil {
+		t.Errorf("empty MultiResolver resolved a type")
+	}
+}
+
+func TestAnyEmptyValue(t *testing.T) {
+	// An empty message encodes as an empty, but present, value.
+	a, err := MarshalAny(&pb.FileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Value == nil || len(a.Value) != 0 {
+		t.Errorf("MarshalAny(empty message).Value = %#v, want empty non-nil slice", a.Value)
+	}
+	dst := &any.Any{}
+	if err := MarshalAnyTo(dst, &pb.FileOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Value == nil || len(dst.Value) != 0 {
+		t.Errorf("MarshalAnyTo(empty message).Value = %#v, want empty non-nil slice", dst.Value)
+	}
+
+	tests := []struct {
+		desc    string
+		any     *any.Any
+		wantErr bool
+	}{
+		{"empty value", &any.Any{TypeUrl: a.TypeUrl, Value: []byte{}}, false},
+		{"nil value", &any.Any{TypeUrl: a.TypeUrl}, false},
+		{"missing type URL", &any.Any{Value: []byte{}}, true},
+		{"missing type URL and value", &any.Any{}, true},
+	}
+	for _, tt := range tests {
+		got := &pb.FileOptions{JavaPackage: proto.String("stale")}
+		err := UnmarshalAny(tt.any, got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: UnmarshalAny = %v, want error %v", tt.desc, err, tt.wantErr)
+		}
+		if err == nil && !proto.Equal(got, &pb.FileOptions{}) {
+			t.Errorf("%s: UnmarshalAny = %v, want empty message", tt.desc, got)
+		}
+		m, err := UnmarshalAnyNew(tt.any, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: UnmarshalAnyNew = %v, want error %v", tt.desc, err, tt.wantErr)
+		}
+		if err == nil && !proto.Equal(m, &pb.FileOptions{}) {
+			t.Errorf("%s: UnmarshalAnyNew = %v, want empty message", tt.desc, m)
+		}
+	}
+}
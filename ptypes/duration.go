@@ -35,39 +35,18 @@ package ptypes
 // and time.Duration.
 
 import (
-	"errors"
 	"fmt"
 	"time"
 
 	durpb "github.com/golang/protobuf/ptypes/duration"
 )
 
-const (
-	// Range of a durpb.Duration in seconds, as specified in
-	// google/protobuf/duration.proto. This is about 10,000 years in seconds.
-	maxSeconds = int64(10000 * 365.25 * 24 * 60 * 60)
-	minSeconds = -maxSeconds
-)
-
 // validateDuration determines whether the durpb.Duration is valid according to the
 // definition in google/protobuf/duration.proto. A valid durpb.Duration
 // may still be too large to fit into a time.Duration (the range of durpb.Duration
 // is about 10,000 years, and the range of time.Duration is about 290).
 func validateDuration(d *durpb.Duration) error {
-	if d == nil {
-		return errors.New("duration: nil Duration")
-	}
-	if d.Seconds < minSeconds || d.Seconds > maxSeconds {
-		return fmt.Errorf("duration: %v: seconds out of range", d)
-	}
-	if d.Nanos <= -1e9 || d.Nanos >= 1e9 {
-		return fmt.Errorf("duration: %v: nanos out of range", d)
-	}
-	// Seconds and Nanos must have the same sign, unless d.Nanos is zero.
-	if (d.Seconds < 0 && d.Nanos > 0) || (d.Seconds > 0 && d.Nanos < 0) {
-		return fmt.Errorf("duration: %v: seconds and nanos have different signs", d)
-	}
-	return nil
+	return d.CheckValid()
 }
 
 // Duration converts a durpb.Duration to a time.Duration. Duration
@@ -92,11 +71,5 @@ func Duration(p *durpb.Duration) (time.Duration, error) {
 
 // DurationProto converts a time.Duration to a durpb.Duration.
 func DurationProto(d time.Duration) *durpb.Duration {
-	nanos := d.Nanoseconds()
-	secs := nanos / 1e9
-	nanos -= secs * 1e9
-	return &durpb.Duration{
-		Seconds: secs,
-		Nanos:   int32(nanos),
-	}
+	return durpb.New(d)
 }
@@ -0,0 +1,108 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package duration
+
+// This file adds conversions between Duration and time.Duration
+// to the generated Duration type.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// Range of a Duration in seconds, as specified in
+	// google/protobuf/duration.proto. This is about 10,000 years in seconds.
+	maxSeconds = int64(10000 * 365.25 * 24 * 60 * 60)
+	minSeconds = -maxSeconds
+)
+
+// New returns a Duration for d.
+func New(d time.Duration) *Duration {
+	nanos := d.Nanoseconds()
+	secs := nanos / 1e9
+	nanos -= secs * 1e9
+	return &Duration{Seconds: secs, Nanos: int32(nanos)}
+}
+
+// AsDuration converts x to a time.Duration. The range of a Duration is
+// about 10,000 years, while a time.Duration covers about 290 years, so
+// a Duration outside that range is saturated to math.MaxInt64 or
+// math.MinInt64 nanoseconds. AsDuration does not check that x is valid;
+// use CheckValid for that. A nil Duration converts to zero.
+func (x *Duration) AsDuration() time.Duration {
+	secs := x.GetSeconds()
+	nanos := x.GetNanos()
+	d := time.Duration(secs) * time.Second
+	overflow := d/time.Second != time.Duration(secs)
+	d += time.Duration(nanos) * time.Nanosecond
+	overflow = overflow || (secs < 0 && nanos < 0 && d > 0)
+	overflow = overflow || (secs > 0 && nanos > 0 && d < 0)
+	if overflow {
+		switch {
+		case secs < 0:
+			return time.Duration(math.MinInt64)
+		case secs > 0:
+			return time.Duration(math.MaxInt64)
+		}
+	}
+	return d
+}
+
+// IsValid reports whether x is a valid Duration, as defined by
+// google/protobuf/duration.proto. A nil Duration is not valid.
+func (x *Duration) IsValid() bool {
+	return x.CheckValid() == nil
+}
+
+// CheckValid returns an error describing why x is not a valid Duration,
+// or nil if it is valid. A valid Duration has seconds within about
+// ±10,000 years, nanos within ±999,999,999, and no sign mismatch between
+// seconds and nanos.
+func (x *Duration) CheckValid() error {
+	if x == nil {
+		return errors.New("duration: nil Duration")
+	}
+	if x.Seconds < minSeconds || x.Seconds > maxSeconds {
+		return fmt.Errorf("duration: %v: seconds out of range", x)
+	}
+	if x.Nanos <= -1e9 || x.Nanos >= 1e9 {
+		return fmt.Errorf("duration: %v: nanos out of range", x)
+	}
+	// Seconds and Nanos must have the same sign, unless x.Nanos is zero.
+	if (x.Seconds < 0 && x.Nanos > 0) || (x.Seconds > 0 && x.Nanos < 0) {
+		return fmt.Errorf("duration: %v: seconds and nanos have different signs", x)
+	}
+	return nil
+}
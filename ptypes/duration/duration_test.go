@@ -0,0 +1,140 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package duration_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	durpb "github.com/golang/protobuf/ptypes/duration"
+)
+
+const (
+	// Range of a Duration in seconds, as specified in
+	// google/protobuf/duration.proto.
+	maxSeconds = int64(10000 * 365.25 * 24 * 60 * 60)
+	minSeconds = -maxSeconds
+
+	minGoSeconds = math.MinInt64 / int64(1e9)
+	maxGoSeconds = math.MaxInt64 / int64(1e9)
+)
+
+var durationTests = []struct {
+	proto   *durpb.Duration
+	isValid bool
+	inRange bool // representable as a time.Duration
+	dur     time.Duration
+}{
+	{&durpb.Duration{Seconds: 0, Nanos: 0}, true, true, 0},
+	{&durpb.Duration{Seconds: 100, Nanos: 987}, true, true, 100*time.Second + 987},
+	{&durpb.Duration{Seconds: -100, Nanos: -987}, true, true, -(100*time.Second + 987)},
+	// The largest and smallest durations representable in Go.
+	{&durpb.Duration{Seconds: maxGoSeconds, Nanos: int32(math.MaxInt64 - 1e9*maxGoSeconds)}, true, true, math.MaxInt64},
+	{&durpb.Duration{Seconds: minGoSeconds, Nanos: int32(math.MinInt64 - 1e9*minGoSeconds)}, true, true, math.MinInt64},
+	// One nanosecond past them.
+	{&durpb.Duration{Seconds: maxGoSeconds, Nanos: int32(math.MaxInt64-1e9*maxGoSeconds) + 1}, true, false, 0},
+	{&durpb.Duration{Seconds: minGoSeconds, Nanos: int32(math.MinInt64-1e9*minGoSeconds) - 1}, true, false, 0},
+	// One second past them.
+	{&durpb.Duration{Seconds: maxGoSeconds + 1, Nanos: int32(math.MaxInt64 - 1e9*maxGoSeconds)}, true, false, 0},
+	{&durpb.Duration{Seconds: minGoSeconds - 1, Nanos: int32(math.MinInt64 - 1e9*minGoSeconds)}, true, false, 0},
+	// The largest and smallest valid durations.
+	{&durpb.Duration{Seconds: maxSeconds, Nanos: 1e9 - 1}, true, false, 0},
+	{&durpb.Duration{Seconds: minSeconds, Nanos: -(1e9 - 1)}, true, false, 0},
+	{nil, false, false, 0},
+	{&durpb.Duration{Seconds: -100, Nanos: 987}, false, false, 0},
+	{&durpb.Duration{Seconds: maxSeconds + 1, Nanos: 0}, false, false, 0},
+}
+
+func TestCheckValid(t *testing.T) {
+	for _, test := range durationTests {
+		if got := test.proto.IsValid(); got != test.isValid {
+			t.Errorf("%v.IsValid() = %t, want %t", test.proto, got, test.isValid)
+		}
+		if got := test.proto.CheckValid() == nil; got != test.isValid {
+			t.Errorf("%v.CheckValid() ok = %t, want %t", test.proto, got, test.isValid)
+		}
+	}
+
+	tests := []struct {
+		proto *durpb.Duration
+		want  string
+	}{
+		{nil, "nil Duration"},
+		{&durpb.Duration{Seconds: maxSeconds + 1}, "seconds out of range"},
+		{&durpb.Duration{Seconds: minSeconds - 1}, "seconds out of range"},
+		{&durpb.Duration{Nanos: 1e9}, "nanos out of range"},
+		{&durpb.Duration{Nanos: -1e9}, "nanos out of range"},
+		{&durpb.Duration{Seconds: -1, Nanos: 1}, "different signs"},
+		{&durpb.Duration{Seconds: 1, Nanos: -1}, "different signs"},
+	}
+	for _, test := range tests {
+		err := test.proto.CheckValid()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v.CheckValid() = %v, want error containing %q", test.proto, err, test.want)
+		}
+	}
+}
+
+func TestAsDuration(t *testing.T) {
+	for _, test := range durationTests {
+		if !test.isValid {
+			continue
+		}
+		want := test.dur
+		if !test.inRange {
+			want = math.MaxInt64
+			if test.proto.Seconds < 0 {
+				want = math.MinInt64
+			}
+		}
+		if got := test.proto.AsDuration(); got != want {
+			t.Errorf("%v.AsDuration() = %v, want %v", test.proto, got, want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, test := range durationTests {
+		if test.isValid && test.inRange {
+			got := durpb.New(test.dur)
+			if !proto.Equal(got, test.proto) {
+				t.Errorf("New(%v) = %v, want %v", test.dur, got, test.proto)
+			}
+			if d := got.AsDuration(); d != test.dur {
+				t.Errorf("New(%v).AsDuration() = %v", test.dur, d)
+			}
+		}
+	}
+}
@@ -41,6 +41,11 @@ import (
 )
 
 const (
+	// Range of a durpb.Duration in seconds, as specified in
+	// google/protobuf/duration.proto.
+	maxSeconds = int64(10000 * 365.25 * 24 * 60 * 60)
+	minSeconds = -maxSeconds
+
 	minGoSeconds = math.MinInt64 / int64(1e9)
 	maxGoSeconds = math.MaxInt64 / int64(1e9)
 )
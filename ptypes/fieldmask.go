@@ -0,0 +1,411 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package ptypes
+
+// This file implements helpers for field mask paths, as held in the paths
+// field of google.protobuf.FieldMask. A path is a dot-separated sequence of
+// the original .proto field names, like "inner.host".
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// ValidateFieldPaths checks that each path names a field of pb's message
+// type. Every field in a path except the last must be a singular message
+// field; a repeated or map field may only be the last element of a path.
+// Extensions cannot be named in a path. An empty set of paths is valid.
+func ValidateFieldPaths(pb proto.Message, paths ...string) error {
+	t := reflect.TypeOf(pb)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("fieldmask: %T is not a generated message", pb)
+	}
+	for _, path := range paths {
+		if err := validateFieldPath(t, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateFieldPath(t reflect.Type, path string) error {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		ft, ok := fieldType(t.Elem(), name)
+		if !ok {
+			return fmt.Errorf("fieldmask: invalid path %q: %v has no field %q", path, t.Elem(), name)
+		}
+		if i == len(names)-1 {
+			break
+		}
+		if ft.Kind() != reflect.Ptr || ft.Elem().Kind() != reflect.Struct {
+			return fmt.Errorf("fieldmask: invalid path %q: field %q is not a singular message field", path, name)
+		}
+		t = ft
+	}
+	return nil
+}
+
+// fieldType returns the Go type of the field of the message struct st
+// with the given original name.
+func fieldType(st reflect.Type, name string) (reflect.Type, bool) {
+	sprops := proto.GetProperties(st)
+	if i := fieldIndex(sprops, name); i >= 0 {
+		return st.Field(i).Type, true
+	}
+	if oop, ok := sprops.OneofTypes[name]; ok {
+		return oop.Type.Elem().Field(0).Type, true
+	}
+	return nil, false
+}
+
+// FieldPathsToJSON converts paths of .proto field names, like
+// "inner_message.host_name", to the JSON names of the same fields, like
+// "innerMessage.hostName", as used in the JSON form of a FieldMask.
+// JSON names are those chosen by protoc, so json_name options are honored.
+// The paths must be valid for pb, as checked by ValidateFieldPaths.
+func FieldPathsToJSON(pb proto.Message, paths ...string) ([]string, error) {
+	return convertFieldPaths(pb, paths, protoFieldName, jsonFieldName)
+}
+
+// FieldPathsFromJSON converts paths of JSON field names to paths of .proto
+// field names. It is the inverse of FieldPathsToJSON. Names are matched
+// exactly, so paths that differ only in case name different fields.
+// It returns an error if a name matches no field or more than one.
+func FieldPathsFromJSON(pb proto.Message, paths ...string) ([]string, error) {
+	return convertFieldPaths(pb, paths, jsonFieldName, protoFieldName)
+}
+
+func convertFieldPaths(pb proto.Message, paths []string, from, to func(*proto.Properties) string) ([]string, error) {
+	t := reflect.TypeOf(pb)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("fieldmask: %T is not a generated message", pb)
+	}
+	out := make([]string, len(paths))
+	for i, path := range paths {
+		names := strings.Split(path, ".")
+		mt := t
+		for j, name := range names {
+			var match *proto.Properties
+			var ft reflect.Type
+			sprops := proto.GetProperties(mt.Elem())
+			for k, prop := range sprops.Prop {
+				if prop.Tag != 0 && from(prop) == name {
+					if match != nil {
+						return nil, fmt.Errorf("fieldmask: invalid path %q: %q is ambiguous in %v", path, name, mt.Elem())
+					}
+					match, ft = prop, mt.Elem().Field(k).Type
+				}
+			}
+			for _, oop := range sprops.OneofTypes {
+				if from(oop.Prop) == name {
+					if match != nil {
+						return nil, fmt.Errorf("fieldmask: invalid path %q: %q is ambiguous in %v", path, name, mt.Elem())
+					}
+					match, ft = oop.Prop, oop.Type.Elem().Field(0).Type
+				}
+			}
+			if match == nil {
+				return nil, fmt.Errorf("fieldmask: invalid path %q: %v has no field %q", path, mt.Elem(), name)
+			}
+			names[j] = to(match)
+			if j < len(names)-1 {
+				if ft.Kind() != reflect.Ptr || ft.Elem().Kind() != reflect.Struct {
+					return nil, fmt.Errorf("fieldmask: invalid path %q: field %q is not a singular message field", path, name)
+				}
+				mt = ft
+			}
+		}
+		out[i] = strings.Join(names, ".")
+	}
+	return out, nil
+}
+
+// protoFieldName returns the .proto name of a field, as used in paths.
+// Group fields are named by their lowercased group name.
+func protoFieldName(prop *proto.Properties) string {
+	if prop.Wire == "group" {
+		return strings.ToLower(prop.OrigName)
+	}
+	return prop.OrigName
+}
+
+// jsonFieldName returns the JSON name of a field.
+func jsonFieldName(prop *proto.Properties) string {
+	if prop.JSONName != "" {
+		return prop.JSONName
+	}
+	return protoFieldName(prop)
+}
+
+// UnionFieldPaths returns the sorted paths that are in any of the given
+// sets of paths. A path subsumes the paths below it, so the union of
+// "a" and "a.b" is "a".
+func UnionFieldPaths(x, y []string, more ...[]string) []string {
+	var all []string
+	all = append(all, x...)
+	all = append(all, y...)
+	for _, m := range more {
+		all = append(all, m...)
+	}
+	return NormalizeFieldPaths(all)
+}
+
+// IntersectFieldPaths returns the sorted paths that are in all of the
+// given sets of paths. A path subsumes the paths below it, so the
+// intersection of "a" and "a.b" is "a.b".
+func IntersectFieldPaths(x, y []string, more ...[]string) []string {
+	out := intersectFieldPaths(x, y)
+	for _, m := range more {
+		out = intersectFieldPaths(out, m)
+	}
+	return out
+}
+
+func intersectFieldPaths(x, y []string) []string {
+	var out []string
+	for _, p := range x {
+		for _, q := range y {
+			switch {
+			case p == q || strings.HasPrefix(q, p+"."):
+				out = append(out, q)
+			case strings.HasPrefix(p, q+"."):
+				out = append(out, p)
+			}
+		}
+	}
+	return NormalizeFieldPaths(out)
+}
+
+// NormalizeFieldPaths sorts paths and removes duplicates and
+// paths subsumed by another path.
+func NormalizeFieldPaths(paths []string) []string {
+	set := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		set[p] = true
+	}
+	out := []string{}
+	for p := range set {
+		if !hasParentPath(set, p) {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// hasParentPath reports whether a proper prefix of path is in set.
+func hasParentPath(set map[string]bool, path string) bool {
+	for i := strings.LastIndex(path, "."); i >= 0; i = strings.LastIndex(path[:i], ".") {
+		if set[path[:i]] {
+			return true
+		}
+	}
+	return false
+}
+
+// PruneFields clears every field of pb that is not covered by paths,
+// including unknown fields and extensions. A path naming a repeated or
+// map field keeps the whole field; a path into a nested message keeps only
+// the named fields of that message. For a oneof, only the members named
+// by paths are kept.
+func PruneFields(pb proto.Message, paths ...string) error {
+	if err := ValidateFieldPaths(pb, paths...); err != nil {
+		return err
+	}
+	if reflect.ValueOf(pb).IsNil() {
+		return fmt.Errorf("fieldmask: nil message")
+	}
+	pruneFields(reflect.ValueOf(pb).Elem(), newFieldTree(paths))
+	return nil
+}
+
+// ExtractFields copies the fields of src covered by paths into dst, which
+// must have the same type, creating nested messages in dst as needed.
+// Fields of dst covered by paths that are unset in src are cleared; other
+// fields of dst are left unchanged. Repeated and map fields are copied
+// whole, replacing the contents of dst. The copied values do not share
+// memory with src.
+func ExtractFields(dst, src proto.Message, paths ...string) error {
+	if reflect.TypeOf(dst) != reflect.TypeOf(src) {
+		return fmt.Errorf("fieldmask: mismatched message types %T and %T", dst, src)
+	}
+	if err := ValidateFieldPaths(src, paths...); err != nil {
+		return err
+	}
+	if reflect.ValueOf(dst).IsNil() || reflect.ValueOf(src).IsNil() {
+		return fmt.Errorf("fieldmask: nil message")
+	}
+	src = proto.Clone(src)
+	extractFields(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), newFieldTree(paths))
+	return nil
+}
+
+// A fieldTree holds a set of paths keyed by their first field name.
+// A nil subtree covers the whole field.
+type fieldTree map[string]fieldTree
+
+func newFieldTree(paths []string) fieldTree {
+	t := fieldTree{}
+	for _, path := range NormalizeFieldPaths(paths) {
+		n := t
+		names := strings.Split(path, ".")
+		for _, name := range names[:len(names)-1] {
+			if n[name] == nil {
+				n[name] = fieldTree{}
+			}
+			n = n[name]
+		}
+		n[names[len(names)-1]] = nil
+	}
+	return t
+}
+
+func pruneFields(sv reflect.Value, t fieldTree) {
+	st := sv.Type()
+	sprops := proto.GetProperties(st)
+	for i := 0; i < sv.NumField(); i++ {
+		f := st.Field(i)
+		fv := sv.Field(i)
+		name := sprops.Prop[i].OrigName
+		if sprops.Prop[i].Wire == "group" {
+			name = strings.ToLower(name)
+		}
+		switch {
+		case strings.HasPrefix(f.Name, "XXX_"):
+			switch f.Name {
+			case "XXX_unrecognized", "XXX_InternalExtensions", "XXX_extensions":
+				fv.Set(reflect.Zero(f.Type))
+			}
+			continue
+		case f.Tag.Get("protobuf_oneof") != "":
+			if fv.IsNil() {
+				continue
+			}
+			name = oneofName(sprops, fv.Elem().Type())
+			fv = fv.Elem().Elem().Field(0)
+			if sub, ok := t[name]; !ok {
+				sv.Field(i).Set(reflect.Zero(f.Type))
+				continue
+			} else if sub == nil {
+				continue
+			}
+		}
+		sub, ok := t[name]
+		switch {
+		case !ok:
+			fv.Set(reflect.Zero(fv.Type()))
+		case sub != nil && fv.Kind() == reflect.Ptr && !fv.IsNil():
+			pruneFields(fv.Elem(), sub)
+		}
+	}
+}
+
+func extractFields(dv, sv reflect.Value, t fieldTree) {
+	sprops := proto.GetProperties(sv.Type())
+	for name, sub := range t {
+		if oop, ok := sprops.OneofTypes[name]; ok {
+			extractOneof(dv.Field(oop.Field), sv.Field(oop.Field), oop.Type, sub)
+			continue
+		}
+		i := fieldIndex(sprops, name)
+		extractField(dv.Field(i), sv.Field(i), sub)
+	}
+}
+
+// extractField copies the field value s into d. If sub is not nil, s and d
+// are message pointers and only the fields in sub are copied.
+func extractField(d, s reflect.Value, sub fieldTree) {
+	switch {
+	case sub == nil:
+		d.Set(s)
+	case !s.IsNil():
+		if d.IsNil() {
+			d.Set(reflect.New(s.Type().Elem()))
+		}
+		extractFields(d.Elem(), s.Elem(), sub)
+	case !d.IsNil():
+		// Clear the covered fields of d.
+		extractFields(d.Elem(), reflect.New(s.Type().Elem()).Elem(), sub)
+	}
+}
+
+// extractOneof copies the oneof member with wrapper type wt from the oneof
+// field s into d.
+func extractOneof(d, s reflect.Value, wt reflect.Type, sub fieldTree) {
+	sSet := !s.IsNil() && s.Elem().Type() == wt
+	dSet := !d.IsNil() && d.Elem().Type() == wt
+	switch {
+	case sub == nil && sSet:
+		d.Set(s)
+	case sub == nil && dSet:
+		d.Set(reflect.Zero(d.Type()))
+	case sSet:
+		if !dSet {
+			d.Set(reflect.New(wt.Elem()))
+		}
+		extractField(d.Elem().Elem().Field(0), s.Elem().Elem().Field(0), sub)
+	case dSet:
+		dm := d.Elem().Elem().Field(0)
+		extractField(dm, reflect.Zero(dm.Type()), sub)
+	}
+}
+
+// fieldIndex returns the index of the struct field with the given
+// .proto field name, or -1. Group fields are named by their lowercased
+// group name, not by the message name kept in their properties.
+func fieldIndex(sprops *proto.StructProperties, name string) int {
+	for i, prop := range sprops.Prop {
+		if prop.Tag == 0 {
+			continue
+		}
+		if protoFieldName(prop) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// oneofName returns the original name of the oneof member with wrapper
+// type wt.
+func oneofName(sprops *proto.StructProperties, wt reflect.Type) string {
+	for name, oop := range sprops.OneofTypes {
+		if oop.Type == wt {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,461 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package ptypes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/golang/protobuf/proto/test_proto"
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestValidateFieldPaths(t *testing.T) {
+	m := &descpb.FileDescriptorProto{}
+	for _, path := range []string{
+		"name",
+		"options",
+		"options.java_package",
+		"source_code_info.location",
+		"message_type",
+		"options.uninterpreted_option",
+	} {
+		if err := ValidateFieldPaths(m, path); err != nil {
+			t.Errorf("ValidateFieldPaths(%q): %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		path, want string
+	}{
+		{"", `has no field ""`},
+		{"nope", `has no field "nope"`},
+		{"Name", `has no field "Name"`},
+		{"options.nope", `descriptor.FileOptions has no field "nope"`},
+		{"options.", `has no field ""`},
+		{"name.x", `field "name" is not a singular message field`},
+		{"message_type.name", `field "message_type" is not a singular message field`},
+		{"XXX_unrecognized", `has no field "XXX_unrecognized"`},
+		{"options.[google.protobuf.go_package]", `has no field "[google"`},
+	}
+	for _, tt := range tests {
+		err := ValidateFieldPaths(m, "name", tt.path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ValidateFieldPaths(%q) = %v, want error containing %q", tt.path, err, tt.want)
+		}
+	}
+
+	// Groups are named by their field name, not their message name.
+	if err := ValidateFieldPaths(&pb.MyMessage{}, "somegroup.group_field"); err != nil {
+		t.Errorf("ValidateFieldPaths(%q): %v", "somegroup.group_field", err)
+	}
+	if err := ValidateFieldPaths(&pb.MyMessage{}, "SomeGroup"); err == nil {
+		t.Errorf("ValidateFieldPaths(%q) succeeded, want error", "SomeGroup")
+	}
+	if err := ValidateFieldPaths(m); err != nil {
+		t.Errorf("ValidateFieldPaths with no paths: %v", err)
+	}
+	if err := ValidateFieldPaths(nil, "name"); err == nil {
+		t.Errorf("ValidateFieldPaths(nil) succeeded, want error")
+	}
+}
+
+// jsonNameMessage is a message with json_name options and names that
+// differ only in case.
+type jsonNameMessage struct {
+	FooBar   *string          `protobuf:"bytes,1,opt,name=foo_bar,json=custom"`
+	Foobar   *string          `protobuf:"bytes,2,opt,name=foobar"`
+	FooBar_3 *string          `protobuf:"bytes,3,opt,name=FooBar,json=FooBar"`
+	Field_1  *string          `protobuf:"bytes,4,opt,name=field_1,json=field1"`
+	Inner    *jsonNameMessage `protobuf:"bytes,5,opt,name=inner_msg,json=innerMsg"`
+}
+
+func (m *jsonNameMessage) Reset()         { *m = jsonNameMessage{} }
+func (m *jsonNameMessage) String() string { return proto.CompactTextString(m) }
+func (*jsonNameMessage) ProtoMessage()    {}
+
+// ambiguousJSONMessage has two fields with the same JSON name.
+type ambiguousJSONMessage struct {
+	A *string `protobuf:"bytes,1,opt,name=a,json=x"`
+	B *string `protobuf:"bytes,2,opt,name=b,json=x"`
+}
+
+func (m *ambiguousJSONMessage) Reset()         { *m = ambiguousJSONMessage{} }
+func (m *ambiguousJSONMessage) String() string { return proto.CompactTextString(m) }
+func (*ambiguousJSONMessage) ProtoMessage()    {}
+
+func TestFieldPathsJSON(t *testing.T) {
+	tests := []struct {
+		m         proto.Message
+		proto, js []string
+	}{{
+		m:     &descpb.FileDescriptorProto{},
+		proto: []string{"name", "message_type", "options.java_multiple_files", "source_code_info.location"},
+		js:    []string{"name", "messageType", "options.javaMultipleFiles", "sourceCodeInfo.location"},
+	}, {
+		m:     &pb.MyMessage{},
+		proto: []string{"we_must_go_deeper.leo_finally_won_an_oscar.host", "somegroup.group_field", "rep_bytes"},
+		js:    []string{"weMustGoDeeper.leoFinallyWonAnOscar.host", "somegroup.groupField", "repBytes"},
+	}, {
+		m:     &pb.Communique{},
+		proto: []string{"make_me_cry", "msg.string_field"},
+		js:    []string{"makeMeCry", "msg.stringField"},
+	}, {
+		m:     &jsonNameMessage{},
+		proto: []string{"foo_bar", "foobar", "FooBar", "field_1", "inner_msg.inner_msg.foo_bar"},
+		js:    []string{"custom", "foobar", "FooBar", "field1", "innerMsg.innerMsg.custom"},
+	}}
+	for _, tt := range tests {
+		js, err := FieldPathsToJSON(tt.m, tt.proto...)
+		if err != nil {
+			t.Errorf("FieldPathsToJSON(%T, %q): %v", tt.m, tt.proto, err)
+		} else if !reflect.DeepEqual(js, tt.js) {
+			t.Errorf("FieldPathsToJSON(%T, %q) = %q, want %q", tt.m, tt.proto, js, tt.js)
+		}
+		paths, err := FieldPathsFromJSON(tt.m, tt.js...)
+		if err != nil {
+			t.Errorf("FieldPathsFromJSON(%T, %q): %v", tt.m, tt.js, err)
+		} else if !reflect.DeepEqual(paths, tt.proto) {
+			t.Errorf("FieldPathsFromJSON(%T, %q) = %q, want %q", tt.m, tt.js, paths, tt.proto)
+		}
+	}
+
+	errTests := []struct {
+		m    proto.Message
+		path string
+		want string
+	}{
+		{&jsonNameMessage{}, "fooBar", `has no field "fooBar"`},
+		{&jsonNameMessage{}, "foo_bar", `has no field "foo_bar"`},
+		{&jsonNameMessage{}, "innerMsg.field_1", `has no field "field_1"`},
+		{&jsonNameMessage{}, "custom.foobar", "not a singular message field"},
+		{&ambiguousJSONMessage{}, "x", `"x" is ambiguous`},
+	}
+	for _, tt := range errTests {
+		if _, err := FieldPathsFromJSON(tt.m, tt.path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("FieldPathsFromJSON(%T, %q) error = %v, want %q", tt.m, tt.path, err, tt.want)
+		}
+	}
+	if _, err := FieldPathsToJSON(&jsonNameMessage{}, "custom"); err == nil {
+		t.Errorf("FieldPathsToJSON accepted a JSON name")
+	}
+}
+
+func TestNormalizeFieldPaths(t *testing.T) {
+	tests := []struct {
+		in, want []string
+	}{
+		{nil, []string{}},
+		{[]string{"b", "a", "b"}, []string{"a", "b"}},
+		{[]string{"a.b.c", "a.b", "a.bc"}, []string{"a.b", "a.bc"}},
+		{[]string{"a.b", "a"}, []string{"a"}},
+		{[]string{"a.b", "a.c", "ab"}, []string{"a.b", "a.c", "ab"}},
+		{[]string{"x.y.z", "x"}, []string{"x"}},
+	}
+	for _, tt := range tests {
+		got := NormalizeFieldPaths(tt.in)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeFieldPaths(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if again := NormalizeFieldPaths(got); !reflect.DeepEqual(again, got) {
+			t.Errorf("NormalizeFieldPaths(%q) = %q, not idempotent", got, again)
+		}
+		rev := make([]string, len(tt.in))
+		for i, p := range tt.in {
+			rev[len(rev)-1-i] = p
+		}
+		if got := NormalizeFieldPaths(rev); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeFieldPaths(%q) = %q, want %q", rev, got, tt.want)
+		}
+	}
+}
+
+func TestUnionFieldPaths(t *testing.T) {
+	tests := []struct {
+		x, y []string
+		more [][]string
+		want []string
+	}{
+		{nil, nil, nil, []string{}},
+		{[]string{"b", "a"}, []string{"a", "c"}, nil, []string{"a", "b", "c"}},
+		{[]string{"a.b"}, []string{"a"}, nil, []string{"a"}},
+		{[]string{"a.b.c", "a.bc"}, []string{"a.b"}, nil, []string{"a.b", "a.bc"}},
+		{[]string{"ab.c"}, []string{"a"}, nil, []string{"a", "ab.c"}},
+		{[]string{"x"}, []string{"y"}, [][]string{{"z.a"}, {"z"}}, []string{"x", "y", "z"}},
+	}
+	for _, tt := range tests {
+		if got := UnionFieldPaths(tt.x, tt.y, tt.more...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("UnionFieldPaths(%q, %q, %q) = %q, want %q", tt.x, tt.y, tt.more, got, tt.want)
+		}
+	}
+}
+
+func TestIntersectFieldPaths(t *testing.T) {
+	tests := []struct {
+		x, y []string
+		more [][]string
+		want []string
+	}{
+		{nil, []string{"a"}, nil, []string{}},
+		{[]string{"a", "b"}, []string{"b", "c"}, nil, []string{"b"}},
+		{[]string{"a"}, []string{"a.b", "a.c.d", "ab"}, nil, []string{"a.b", "a.c.d"}},
+		{[]string{"a.b.c"}, []string{"a"}, nil, []string{"a.b.c"}},
+		{[]string{"a", "a.b"}, []string{"a.b"}, nil, []string{"a.b"}},
+		{[]string{"a", "b"}, []string{"a", "b"}, [][]string{{"b.c"}}, []string{"b.c"}},
+	}
+	for _, tt := range tests {
+		if got := IntersectFieldPaths(tt.x, tt.y, tt.more...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("IntersectFieldPaths(%q, %q, %q) = %q, want %q", tt.x, tt.y, tt.more, got, tt.want)
+		}
+	}
+}
+
+func maskTestMessage() *pb.MyMessage {
+	m := &pb.MyMessage{
+		Count: proto.Int32(1),
+		Name:  proto.String("name"),
+		Pet:   []string{"dog"},
+		Inner: &pb.InnerMessage{Host: proto.String("host"), Port: proto.Int32(80)},
+		Others: []*pb.OtherMessage{
+			{Key: proto.Int64(1), Inner: &pb.InnerMessage{Host: proto.String("o")}},
+		},
+		WeMustGoDeeper: &pb.RequiredInnerMessage{
+			LeoFinallyWonAnOscar: &pb.InnerMessage{Host: proto.String("leo"), Port: proto.Int32(1)},
+		},
+		XXX_unrecognized: []byte{0x98, 0x06, 0x01}, // field 99, varint 1
+	}
+	if err := proto.SetExtension(m, pb.E_Ext_Number, proto.Int32(7)); err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func TestPruneFields(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  *pb.MyMessage
+	}{{
+		paths: nil,
+		want:  &pb.MyMessage{},
+	}, {
+		paths: []string{"count", "pet", "others"},
+		want: &pb.MyMessage{
+			Count: proto.Int32(1),
+			Pet:   []string{"dog"},
+			Others: []*pb.OtherMessage{
+				{Key: proto.Int64(1), Inner: &pb.InnerMessage{Host: proto.String("o")}},
+			},
+		},
+	}, {
+		paths: []string{"inner.port", "we_must_go_deeper.leo_finally_won_an_oscar.host"},
+		want: &pb.MyMessage{
+			Inner: &pb.InnerMessage{Port: proto.Int32(80)},
+			WeMustGoDeeper: &pb.RequiredInnerMessage{
+				LeoFinallyWonAnOscar: &pb.InnerMessage{Host: proto.String("leo")},
+			},
+		},
+	}, {
+		// Paths to unset fields keep nothing.
+		paths: []string{"quote", "somegroup", "inner.connected"},
+		want:  &pb.MyMessage{Inner: &pb.InnerMessage{}},
+	}}
+	for _, tt := range tests {
+		m := maskTestMessage()
+		if err := PruneFields(m, tt.paths...); err != nil {
+			t.Errorf("PruneFields(%q): %v", tt.paths, err)
+			continue
+		}
+		if !proto.Equal(m, tt.want) {
+			t.Errorf("PruneFields(%q) = %v, want %v", tt.paths, m, tt.want)
+		}
+	}
+
+	if err := PruneFields(maskTestMessage(), "nope"); err == nil {
+		t.Errorf("PruneFields with an invalid path succeeded, want error")
+	}
+	if err := PruneFields((*pb.MyMessage)(nil), "count"); err == nil {
+		t.Errorf("PruneFields with a nil message succeeded, want error")
+	}
+}
+
+func TestPruneFieldsOneof(t *testing.T) {
+	newMsg := func() *pb.Oneof {
+		return &pb.Oneof{
+			Union:   &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Label: proto.String("l"), Type: proto.String("t")}},
+			Tormato: &pb.Oneof_Value{Value: 1},
+		}
+	}
+	tests := []struct {
+		paths []string
+		want  *pb.Oneof
+	}{
+		{[]string{"F_Message"}, &pb.Oneof{Union: newMsg().Union}},
+		{[]string{"F_Message.Label", "value"}, &pb.Oneof{
+			Union:   &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Label: proto.String("l")}},
+			Tormato: &pb.Oneof_Value{Value: 1},
+		}},
+		{[]string{"F_String", "value"}, &pb.Oneof{Tormato: &pb.Oneof_Value{Value: 1}}},
+	}
+	for _, tt := range tests {
+		m := newMsg()
+		if err := PruneFields(m, tt.paths...); err != nil {
+			t.Errorf("PruneFields(%q): %v", tt.paths, err)
+			continue
+		}
+		if !proto.Equal(m, tt.want) {
+			t.Errorf("PruneFields(%q) = %v, want %v", tt.paths, m, tt.want)
+		}
+	}
+}
+
+func TestExtractFields(t *testing.T) {
+	newDst := func() *pb.MyMessage {
+		return &pb.MyMessage{
+			Count: proto.Int32(2),
+			Quote: proto.String("quote"),
+			Pet:   []string{"cat", "cow"},
+			Inner: &pb.InnerMessage{Host: proto.String("dst"), Connected: proto.Bool(true)},
+		}
+	}
+	tests := []struct {
+		paths []string
+		want  *pb.MyMessage
+	}{{
+		paths: nil,
+		want:  newDst(),
+	}, {
+		// Repeated fields are replaced, not appended to.
+		paths: []string{"count", "pet", "others"},
+		want: &pb.MyMessage{
+			Count: proto.Int32(1),
+			Quote: proto.String("quote"),
+			Pet:   []string{"dog"},
+			Inner: &pb.InnerMessage{Host: proto.String("dst"), Connected: proto.Bool(true)},
+			Others: []*pb.OtherMessage{
+				{Key: proto.Int64(1), Inner: &pb.InnerMessage{Host: proto.String("o")}},
+			},
+		},
+	}, {
+		// Unset fields in src clear the fields of dst.
+		paths: []string{"quote", "inner.connected", "inner.port"},
+		want: &pb.MyMessage{
+			Count: proto.Int32(2),
+			Pet:   []string{"cat", "cow"},
+			Inner: &pb.InnerMessage{Host: proto.String("dst"), Port: proto.Int32(80)},
+		},
+	}, {
+		// Nested messages are created as needed.
+		paths: []string{"we_must_go_deeper.leo_finally_won_an_oscar.port", "somegroup.group_field"},
+		want: func() *pb.MyMessage {
+			m := newDst()
+			m.WeMustGoDeeper = &pb.RequiredInnerMessage{
+				LeoFinallyWonAnOscar: &pb.InnerMessage{Port: proto.Int32(1)},
+			}
+			return m
+		}(),
+	}}
+	for _, tt := range tests {
+		dst, src := newDst(), maskTestMessage()
+		if err := ExtractFields(dst, src, tt.paths...); err != nil {
+			t.Errorf("ExtractFields(%q): %v", tt.paths, err)
+			continue
+		}
+		if !proto.Equal(dst, tt.want) {
+			t.Errorf("ExtractFields(%q) = %v, want %v", tt.paths, dst, tt.want)
+		}
+		if !proto.Equal(src, maskTestMessage()) {
+			t.Errorf("ExtractFields(%q) modified src: %v", tt.paths, src)
+		}
+	}
+
+	// The copied values do not share memory with src.
+	dst, src := &pb.MyMessage{}, maskTestMessage()
+	if err := ExtractFields(dst, src, "inner", "pet"); err != nil {
+		t.Fatal(err)
+	}
+	dst.Inner.Host = proto.String("changed")
+	dst.Pet[0] = "changed"
+	if !proto.Equal(src, maskTestMessage()) {
+		t.Errorf("changing the extracted fields modified src: %v", src)
+	}
+
+	if err := ExtractFields(&pb.InnerMessage{}, src, "host"); err == nil {
+		t.Errorf("ExtractFields with mismatched types succeeded, want error")
+	}
+	if err := ExtractFields(dst, src, "inner.nope"); err == nil {
+		t.Errorf("ExtractFields with an invalid path succeeded, want error")
+	}
+}
+
+func TestExtractFieldsOneof(t *testing.T) {
+	src := &pb.Oneof{Union: &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Label: proto.String("l"), Type: proto.String("t")}}}
+	tests := []struct {
+		dst   *pb.Oneof
+		paths []string
+		want  *pb.Oneof
+	}{
+		// The member set in src replaces the member set in dst.
+		{&pb.Oneof{Union: &pb.Oneof_F_String{F_String: "s"}}, []string{"F_Message"}, src},
+		// A member unset in src is cleared in dst.
+		{&pb.Oneof{Union: &pb.Oneof_F_String{F_String: "s"}}, []string{"F_String"}, &pb.Oneof{}},
+		// A member that is not masked is left alone.
+		{&pb.Oneof{Union: &pb.Oneof_F_String{F_String: "s"}}, []string{"F_Int32"}, &pb.Oneof{Union: &pb.Oneof_F_String{F_String: "s"}}},
+		{&pb.Oneof{}, []string{"F_Message.Type"}, &pb.Oneof{Union: &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Type: proto.String("t")}}}},
+		{
+			&pb.Oneof{Union: &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Label: proto.String("x"), Type: proto.String("y")}}},
+			[]string{"F_Message.Label"},
+			&pb.Oneof{Union: &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Label: proto.String("l"), Type: proto.String("y")}}},
+		},
+	}
+	for _, tt := range tests {
+		dst := proto.Clone(tt.dst).(*pb.Oneof)
+		if err := ExtractFields(dst, src, tt.paths...); err != nil {
+			t.Errorf("ExtractFields(%v, %q): %v", tt.dst, tt.paths, err)
+			continue
+		}
+		if !proto.Equal(dst, tt.want) {
+			t.Errorf("ExtractFields(%v, %q) = %v, want %v", tt.dst, tt.paths, dst, tt.want)
+		}
+	}
+
+	// A masked member unset in src clears the nested fields of dst.
+	dst := &pb.Oneof{Union: &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Label: proto.String("x"), Type: proto.String("y")}}}
+	if err := ExtractFields(dst, &pb.Oneof{}, "F_Message.Label"); err != nil {
+		t.Fatal(err)
+	}
+	want := &pb.Oneof{Union: &pb.Oneof_F_Message{F_Message: &pb.GoTestField{Type: proto.String("y")}}}
+	if !proto.Equal(dst, want) {
+		t.Errorf("ExtractFields from empty src = %v, want %v", dst, want)
+	}
+}
@@ -0,0 +1,666 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package ptypes
+
+// This file implements conversions between Go values and google.protobuf.Value.
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+// ValueProto converts a Go value to a structpb.Value, recursing into maps
+// and slices. The supported types are:
+//
+//	nil                     NullValue
+//	bool                    BoolValue
+//	int*, uint*, float*     NumberValue
+//	string                  StringValue
+//	[]byte                  StringValue, base64-encoded
+//	map[string]interface{}  StructValue
+//	[]interface{}           ListValue
+//
+// Numbers are stored as float64, so integers with a magnitude above 2^53
+// may lose precision. ValueProto returns an error for NaN and infinite
+// numbers, which cannot be represented in JSON, for strings that are not
+// valid UTF-8, and for values of any other type.
+func ValueProto(v interface{}) (*structpb.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return NullValueProto(), nil
+	case bool:
+		return BoolValueProto(v), nil
+	case int:
+		return numberValue(float64(v))
+	case int8:
+		return numberValue(float64(v))
+	case int16:
+		return numberValue(float64(v))
+	case int32:
+		return numberValue(float64(v))
+	case int64:
+		return numberValue(float64(v))
+	case uint:
+		return numberValue(float64(v))
+	case uint8:
+		return numberValue(float64(v))
+	case uint16:
+		return numberValue(float64(v))
+	case uint32:
+		return numberValue(float64(v))
+	case uint64:
+		return numberValue(float64(v))
+	case float32:
+		return numberValue(float64(v))
+	case float64:
+		return numberValue(v)
+	case string:
+		if !utf8.ValidString(v) {
+			return nil, fmt.Errorf("value: string %q is not valid UTF-8", v)
+		}
+		return StringValueProto(v), nil
+	case []byte:
+		return StringValueProto(base64.StdEncoding.EncodeToString(v)), nil
+	case map[string]interface{}:
+		s := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(v))}
+		for k, e := range v {
+			if !utf8.ValidString(k) {
+				return nil, fmt.Errorf("value: map key %q is not valid UTF-8", k)
+			}
+			ev, err := ValueProto(e)
+			if err != nil {
+				return nil, err
+			}
+			s.Fields[k] = ev
+		}
+		return StructValueProto(s), nil
+	case []interface{}:
+		l, err := ListProto(v)
+		if err != nil {
+			return nil, err
+		}
+		return ListValueProto(l), nil
+	}
+	return nil, fmt.Errorf("value: unsupported type %T", v)
+}
+
+// ListProto converts a slice of Go values to a structpb.ListValue,
+// converting each element with ValueProto.
+func ListProto(v []interface{}) (*structpb.ListValue, error) {
+	l := &structpb.ListValue{Values: make([]*structpb.Value, len(v))}
+	for i, e := range v {
+		ev, err := ValueProto(e)
+		if err != nil {
+			return nil, err
+		}
+		l.Values[i] = ev
+	}
+	return l, nil
+}
+
+// StructProto converts a Go struct, or a pointer to one, to a
+// structpb.Struct. Exported fields are converted following the rules of
+// encoding/json: the json tag, if present, names the field, a tag of "-"
+// skips it, and "omitempty" skips it when it holds an empty value. The
+// fields of untagged embedded structs are promoted into the parent; when
+// several fields have the same name, the shallowest one wins, a tagged
+// field wins over an untagged one at the same depth, and otherwise the
+// fields are all dropped. Unexported fields are ignored.
+//
+// Field values are converted as by ValueProto, with the addition that
+// nested structs, pointers, slices, arrays and maps with string keys are
+// converted recursively. A value that implements json.Marshaler is
+// converted from the JSON it produces, and one that implements
+// encoding.TextMarshaler from its text as a string, so for example a
+// time.Time converts to a string. Nil pointers, interfaces, slices and
+// maps convert to NullValue, as they do in JSON.
+func StructProto(v interface{}) (*structpb.Struct, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() && !isMarshaler(rv) {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct && !isMarshaler(rv) {
+		return nil, fmt.Errorf("value: StructProto of non-struct type %T", v)
+	}
+	if isMarshaler(rv) {
+		sv, err := reflectValueProto(rv)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := sv.GetKind().(*structpb.Value_StructValue); ok {
+			return s.StructValue, nil
+		}
+		return nil, fmt.Errorf("value: StructProto of non-struct type %T", v)
+	}
+	s := &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+	if err := structFields(s, rv); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isMarshaler reports whether rv, or a pointer to it if it is addressable,
+// implements json.Marshaler or encoding.TextMarshaler.
+func isMarshaler(rv reflect.Value) bool {
+	if !rv.IsValid() || !rv.CanInterface() {
+		return false
+	}
+	t := rv.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return true
+	}
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() {
+		pt := reflect.PtrTo(t)
+		return pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType)
+	}
+	return false
+}
+
+// marshalerValueProto converts rv, for which isMarshaler is true, from
+// the JSON or text it marshals to, preferring json.Marshaler as
+// encoding/json does.
+func marshalerValueProto(rv reflect.Value) (*structpb.Value, error) {
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() && !rv.Type().Implements(jsonMarshalerType) && !rv.Type().Implements(textMarshalerType) {
+		rv = rv.Addr()
+	}
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return NullValueProto(), nil
+	}
+	if m, ok := rv.Interface().(json.Marshaler); ok {
+		b, err := m.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("value: %v: %v", rv.Type(), err)
+		}
+		var x interface{}
+		if err := json.Unmarshal(b, &x); err != nil {
+			return nil, fmt.Errorf("value: %v: invalid JSON from MarshalJSON: %v", rv.Type(), err)
+		}
+		return ValueProto(x)
+	}
+	b, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return nil, fmt.Errorf("value: %v: %v", rv.Type(), err)
+	}
+	return ValueProto(string(b))
+}
+
+// structFields adds the fields of the struct rv to s.
+func structFields(s *structpb.Struct, rv reflect.Value) error {
+	for _, f := range jsonFields(rv.Type()) {
+		fv, ok := fieldByIndex(rv, f.index)
+		if !ok {
+			continue // in a nil embedded struct pointer
+		}
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		v, err := reflectValueProto(fv)
+		if err != nil {
+			return fmt.Errorf("%v (field %s)", err, f.goName)
+		}
+		s.Fields[f.name] = v
+	}
+	return nil
+}
+
+// fieldByIndex returns the nested field of rv with the given index
+// sequence, and false if it is reached through a nil pointer.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+// A jsonField is a field of a struct, or of a struct embedded in it,
+// as encoding/json would encode it.
+type jsonField struct {
+	name      string
+	goName    string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	typ       reflect.Type
+}
+
+// jsonFields returns the fields encoding/json would encode for the
+// struct type t, in the same order, resolving names shared by promoted
+// fields of embedded structs the way encoding/json does.
+func jsonFields(t reflect.Type) []jsonField {
+	// Walk the embedded structs breadth first, one depth at a time.
+	var fields []jsonField
+	current := []jsonField{}
+	next := []jsonField{{typ: t}}
+	var count, nextCount map[reflect.Type]int
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				unexported := sf.PkgPath != ""
+				if sf.Anonymous {
+					et := sf.Type
+					if et.Kind() == reflect.Ptr {
+						et = et.Elem()
+					}
+					if unexported && et.Kind() != reflect.Struct {
+						continue
+					}
+				} else if unexported {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts := tag, ""
+				if j := strings.Index(tag, ","); j >= 0 {
+					name, opts = tag[:j], tag[j:]
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					field := jsonField{
+						name:      name,
+						goName:    sf.Name,
+						index:     index,
+						tagged:    name != "",
+						omitEmpty: strings.Contains(opts, ",omitempty"),
+						typ:       ft,
+					}
+					if field.name == "" {
+						field.name = sf.Name
+					}
+					fields = append(fields, field)
+					if count[f.typ] > 1 {
+						// The struct is embedded more than once at this
+						// depth, so its fields conflict with themselves.
+						fields = append(fields, field)
+					}
+					continue
+				}
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, jsonField{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	// Keep the dominant field for each name.
+	sort.Slice(fields, func(i, j int) bool {
+		x, y := fields[i], fields[j]
+		if x.name != y.name {
+			return x.name < y.name
+		}
+		if len(x.index) != len(y.index) {
+			return len(x.index) < len(y.index)
+		}
+		if x.tagged != y.tagged {
+			return x.tagged
+		}
+		return indexLess(x.index, y.index)
+	})
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		// The first field dominates unless the next one is as shallow
+		// and as tagged, in which case the name is ambiguous.
+		if j-i == 1 || len(fields[i].index) != len(fields[i+1].index) || fields[i].tagged != fields[i+1].tagged {
+			out = append(out, fields[i])
+		}
+		i = j
+	}
+	sort.Slice(out, func(i, j int) bool { return indexLess(out[i].index, out[j].index) })
+	return out
+}
+
+// indexLess orders field index sequences by position in the struct.
+func indexLess(x, y []int) bool {
+	for k, xk := range x {
+		if k >= len(y) {
+			return false
+		}
+		if xk != y[k] {
+			return xk < y[k]
+		}
+	}
+	return len(x) < len(y)
+}
+
+// reflectValueProto converts rv to a structpb.Value for StructProto.
+func reflectValueProto(rv reflect.Value) (*structpb.Value, error) {
+	if isMarshaler(rv) {
+		return marshalerValueProto(rv)
+	}
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return NullValueProto(), nil
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return NullValueProto(), nil
+		}
+		return reflectValueProto(rv.Elem())
+	case reflect.Struct:
+		s := &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+		if err := structFields(s, rv); err != nil {
+			return nil, err
+		}
+		return StructValueProto(s), nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("value: unsupported type %v", rv.Type())
+		}
+		if rv.IsNil() {
+			return NullValueProto(), nil
+		}
+		s := &structpb.Struct{Fields: make(map[string]*structpb.Value, rv.Len())}
+		for _, k := range rv.MapKeys() {
+			if !utf8.ValidString(k.String()) {
+				return nil, fmt.Errorf("value: map key %q is not valid UTF-8", k.String())
+			}
+			v, err := reflectValueProto(rv.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			s.Fields[k.String()] = v
+		}
+		return StructValueProto(s), nil
+	case reflect.Slice:
+		if rv.IsNil() {
+			return NullValueProto(), nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return ValueProto(rv.Bytes())
+		}
+		fallthrough
+	case reflect.Array:
+		l := &structpb.ListValue{Values: make([]*structpb.Value, rv.Len())}
+		for i := range l.Values {
+			v, err := reflectValueProto(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			l.Values[i] = v
+		}
+		return ListValueProto(l), nil
+	case reflect.Bool:
+		return BoolValueProto(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return numberValue(float64(rv.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return numberValue(float64(rv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return numberValue(rv.Float())
+	case reflect.String:
+		return ValueProto(rv.String())
+	}
+	return nil, fmt.Errorf("value: unsupported type %v", rv.Type())
+}
+
+// isEmptyValue reports whether v is empty in the sense of the json
+// "omitempty" option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func numberValue(f float64) (*structpb.Value, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("value: %v is not a finite number", f)
+	}
+	return NumberValueProto(f), nil
+}
+
+// NullValueProto returns a structpb.Value holding NullValue.
+func NullValueProto() *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_NullValue{NullValue: structpb.NullValue_NULL_VALUE}}
+}
+
+// BoolValueProto returns a structpb.Value holding the bool b.
+func BoolValueProto(b bool) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: b}}
+}
+
+// NumberValueProto returns a structpb.Value holding the number f.
+// Unlike ValueProto, it does not reject NaN or infinite numbers.
+func NumberValueProto(f float64) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: f}}
+}
+
+// StringValueProto returns a structpb.Value holding the string s.
+// Unlike ValueProto, it does not check that s is valid UTF-8.
+func StringValueProto(s string) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: s}}
+}
+
+// StructValueProto returns a structpb.Value holding the struct s.
+func StructValueProto(s *structpb.Struct) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: s}}
+}
+
+// ListValueProto returns a structpb.Value holding the list l.
+func ListValueProto(l *structpb.ListValue) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: l}}
+}
+
+// Value converts a structpb.Value to a Go value. It is the inverse of
+// ValueProto for values that can be represented in JSON:
+// NullValue, a nil Value and a Value with no kind set convert to nil,
+// NumberValue to float64 (NaN and infinities are passed through unchanged),
+// StringValue to string, BoolValue to bool,
+// StructValue to map[string]interface{} and ListValue to []interface{}.
+func Value(v *structpb.Value) interface{} {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		return k.NumberValue
+	case *structpb.Value_StringValue:
+		return k.StringValue
+	case *structpb.Value_BoolValue:
+		return k.BoolValue
+	case *structpb.Value_StructValue:
+		return StructMap(k.StructValue)
+	case *structpb.Value_ListValue:
+		return ListSlice(k.ListValue)
+	}
+	return nil
+}
+
+// StructMap converts a structpb.Struct to a map, converting each field
+// with Value. A nil Struct converts to an empty map.
+func StructMap(s *structpb.Struct) map[string]interface{} {
+	m := make(map[string]interface{}, len(s.GetFields()))
+	for k, v := range s.GetFields() {
+		m[k] = Value(v)
+	}
+	return m
+}
+
+// ListSlice converts a structpb.ListValue to a slice, converting each
+// element with Value. A nil ListValue converts to an empty slice.
+func ListSlice(l *structpb.ListValue) []interface{} {
+	s := make([]interface{}, len(l.GetValues()))
+	for i, v := range l.GetValues() {
+		s[i] = Value(v)
+	}
+	return s
+}
+
+// ValueEqual reports whether two structpb.Values hold the same JSON value.
+// Values of different kinds are never equal, so the number 1 differs from
+// the string "1". Lists are compared element by element and structs are
+// compared field by field, independent of map order. As in JSON, a NaN
+// number is not equal to anything, including itself.
+// A nil Value is equal only to a nil Value or one with no kind set.
+func ValueEqual(a, b *structpb.Value) bool {
+	switch ak := a.GetKind().(type) {
+	case nil:
+		return b.GetKind() == nil
+	case *structpb.Value_NullValue:
+		_, ok := b.GetKind().(*structpb.Value_NullValue)
+		return ok
+	case *structpb.Value_NumberValue:
+		bk, ok := b.GetKind().(*structpb.Value_NumberValue)
+		return ok && ak.NumberValue == bk.NumberValue
+	case *structpb.Value_StringValue:
+		bk, ok := b.GetKind().(*structpb.Value_StringValue)
+		return ok && ak.StringValue == bk.StringValue
+	case *structpb.Value_BoolValue:
+		bk, ok := b.GetKind().(*structpb.Value_BoolValue)
+		return ok && ak.BoolValue == bk.BoolValue
+	case *structpb.Value_StructValue:
+		bk, ok := b.GetKind().(*structpb.Value_StructValue)
+		return ok && StructEqual(ak.StructValue, bk.StructValue)
+	case *structpb.Value_ListValue:
+		bk, ok := b.GetKind().(*structpb.Value_ListValue)
+		return ok && ListEqual(ak.ListValue, bk.ListValue)
+	}
+	return false
+}
+
+// StructEqual reports whether two structpb.Structs have the same set of
+// fields with equal values, as defined by ValueEqual.
+// A nil Struct is equal to an empty one.
+func StructEqual(a, b *structpb.Struct) bool {
+	af, bf := a.GetFields(), b.GetFields()
+	if len(af) != len(bf) {
+		return false
+	}
+	for k, av := range af {
+		bv, ok := bf[k]
+		if !ok || !ValueEqual(av, bv) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListEqual reports whether two structpb.ListValues have the same length
+// and equal elements in the same order, as defined by ValueEqual.
+// A nil ListValue is equal to an empty one.
+func ListEqual(a, b *structpb.ListValue) bool {
+	av, bv := a.GetValues(), b.GetValues()
+	if len(av) != len(bv) {
+		return false
+	}
+	for i := range av {
+		if !ValueEqual(av[i], bv[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MergeStruct merges src into dst with the semantics of a JSON merge patch
+// (RFC 7396): for each field of src, a NullValue deletes the field from
+// dst, a struct merges recursively into the struct held by dst (replacing
+// any other kind of value), and any other value, including a list,
+// replaces the field in dst. As in a merge patch, NullValues nested in a
+// struct that dst does not already hold are dropped.
+// Values are copied from src, so dst does not alias any part of src.
+// As with proto.Merge, a nil src is a no-op and a nil dst panics.
+func MergeStruct(dst, src *structpb.Struct) {
+	if dst == nil {
+		panic("value: MergeStruct with nil destination")
+	}
+	for k, sv := range src.GetFields() {
+		if sv == nil {
+			continue
+		}
+		if _, ok := sv.GetKind().(*structpb.Value_NullValue); ok {
+			delete(dst.Fields, k)
+			continue
+		}
+		if dst.Fields == nil {
+			dst.Fields = make(map[string]*structpb.Value)
+		}
+		if sk, ok := sv.GetKind().(*structpb.Value_StructValue); ok {
+			dk, ok := dst.Fields[k].GetKind().(*structpb.Value_StructValue)
+			if !ok || dk.StructValue == nil {
+				dk = &structpb.Value_StructValue{StructValue: &structpb.Struct{}}
+				dst.Fields[k] = &structpb.Value{Kind: dk}
+			}
+			MergeStruct(dk.StructValue, sk.StructValue)
+			continue
+		}
+		dst.Fields[k] = proto.Clone(sv).(*structpb.Value)
+	}
+}
@@ -0,0 +1,614 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package ptypes
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+func numberVal(f float64) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: f}}
+}
+
+func stringVal(s string) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: s}}
+}
+
+func listVal(vs ...*structpb.Value) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{Values: vs}}}
+}
+
+func structVal(fields map[string]*structpb.Value) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: fields}}}
+}
+
+func TestValueProto(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want *structpb.Value
+	}{
+		{nil, &structpb.Value{Kind: &structpb.Value_NullValue{}}},
+		{true, &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: true}}},
+		{int(-1), numberVal(-1)},
+		{int8(-8), numberVal(-8)},
+		{int16(-16), numberVal(-16)},
+		{int32(-32), numberVal(-32)},
+		{int64(-64), numberVal(-64)},
+		{uint(1), numberVal(1)},
+		{uint8(8), numberVal(8)},
+		{uint16(16), numberVal(16)},
+		{uint32(32), numberVal(32)},
+		{uint64(64), numberVal(64)},
+		{float32(1.5), numberVal(1.5)},
+		{float64(-2.5), numberVal(-2.5)},
+		{"hello", stringVal("hello")},
+		{[]byte("\x00\xff"), stringVal("AP8=")},
+		{[]interface{}{}, listVal()},
+		{map[string]interface{}{}, structVal(map[string]*structpb.Value{})},
+		{
+			map[string]interface{}{
+				"a": []interface{}{1, "x", nil},
+				"b": map[string]interface{}{"c": map[string]interface{}{"d": []interface{}{[]interface{}{true}}}},
+			},
+			structVal(map[string]*structpb.Value{
+				"a": listVal(numberVal(1), stringVal("x"), &structpb.Value{Kind: &structpb.Value_NullValue{}}),
+				"b": structVal(map[string]*structpb.Value{
+					"c": structVal(map[string]*structpb.Value{
+						"d": listVal(listVal(&structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: true}})),
+					}),
+				}),
+			}),
+		},
+		// Integers above 2^53 are rounded to the nearest float64.
+		{int64(1<<53 + 1), numberVal(1 << 53)},
+		{uint64(math.MaxUint64), numberVal(1 << 64)},
+	}
+	for _, tt := range tests {
+		got, err := ValueProto(tt.in)
+		if err != nil {
+			t.Errorf("ValueProto(%#v) error: %v", tt.in, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("ValueProto(%#v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValueProtoDeep(t *testing.T) {
+	var in interface{} = "leaf"
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			in = []interface{}{in}
+		} else {
+			in = map[string]interface{}{"k": in}
+		}
+	}
+	v, err := ValueProto(in)
+	if err != nil {
+		t.Fatalf("ValueProto error: %v", err)
+	}
+	for i := 99; i >= 0; i-- {
+		if i%2 == 0 {
+			v = v.GetListValue().GetValues()[0]
+		} else {
+			v = v.GetStructValue().GetFields()["k"]
+		}
+	}
+	if got := v.GetStringValue(); got != "leaf" {
+		t.Errorf("innermost value = %q, want %q", got, "leaf")
+	}
+}
+
+func TestValueProtoErrors(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{math.NaN(), "not a finite number"},
+		{math.Inf(1), "not a finite number"},
+		{float32(math.Inf(-1)), "not a finite number"},
+		{"\xff", "not valid UTF-8"},
+		{map[string]interface{}{"\xff": 1}, "not valid UTF-8"},
+		{[]interface{}{1, math.NaN()}, "not a finite number"},
+		{map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{math.Inf(1)}}}, "not a finite number"},
+		{map[string]interface{}{"a": []interface{}{make(chan int)}}, "unsupported type chan int"},
+		{func() {}, "unsupported type func()"},
+		{struct{}{}, "unsupported type struct {}"},
+		{[]string{"a"}, "unsupported type []string"},
+	}
+	for _, tt := range tests {
+		_, err := ValueProto(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ValueProto(%T) error = %v, want error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestValueConstructors(t *testing.T) {
+	got := StructValueProto(&structpb.Struct{Fields: map[string]*structpb.Value{
+		"null":   NullValueProto(),
+		"ok":     BoolValueProto(true),
+		"count":  NumberValueProto(3),
+		"name":   StringValueProto("n"),
+		"tags":   ListValueProto(&structpb.ListValue{Values: []*structpb.Value{StringValueProto("a"), NumberValueProto(-1)}}),
+		"nested": StructValueProto(&structpb.Struct{}),
+	}})
+	want := &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: map[string]*structpb.Value{
+		"null":  {Kind: &structpb.Value_NullValue{NullValue: structpb.NullValue_NULL_VALUE}},
+		"ok":    {Kind: &structpb.Value_BoolValue{BoolValue: true}},
+		"count": {Kind: &structpb.Value_NumberValue{NumberValue: 3}},
+		"name":  {Kind: &structpb.Value_StringValue{StringValue: "n"}},
+		"tags": {Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{Values: []*structpb.Value{
+			{Kind: &structpb.Value_StringValue{StringValue: "a"}},
+			{Kind: &structpb.Value_NumberValue{NumberValue: -1}},
+		}}}},
+		"nested": {Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{}}},
+	}}}}
+	if !proto.Equal(got, want) {
+		t.Errorf("constructed value = %v, want %v", got, want)
+	}
+
+	if v := NumberValueProto(math.NaN()); !math.IsNaN(v.GetNumberValue()) {
+		t.Errorf("NumberValueProto(NaN) = %v, want NaN", v)
+	}
+}
+
+func TestListProto(t *testing.T) {
+	got, err := ListProto([]interface{}{1, "a", nil, []interface{}{true}})
+	if err != nil {
+		t.Fatalf("ListProto: %v", err)
+	}
+	want := &structpb.ListValue{Values: []*structpb.Value{
+		NumberValueProto(1),
+		StringValueProto("a"),
+		NullValueProto(),
+		ListValueProto(&structpb.ListValue{Values: []*structpb.Value{BoolValueProto(true)}}),
+	}}
+	if !proto.Equal(got, want) {
+		t.Errorf("ListProto = %v, want %v", got, want)
+	}
+
+	if got, err := ListProto(nil); err != nil || len(got.GetValues()) != 0 {
+		t.Errorf("ListProto(nil) = %v, %v; want empty list", got, err)
+	}
+	if _, err := ListProto([]interface{}{1, math.Inf(1)}); err == nil || !strings.Contains(err.Error(), "not a finite number") {
+		t.Errorf("ListProto(Inf) error = %v, want not a finite number", err)
+	}
+	if _, err := ListProto([]interface{}{struct{}{}}); err == nil || !strings.Contains(err.Error(), "unsupported type") {
+		t.Errorf("ListProto(struct{}{}) error = %v, want unsupported type", err)
+	}
+}
+
+type structProtoBase struct {
+	ID string `json:"id"`
+}
+
+type structProtoConfig struct {
+	structProtoBase
+	Name     string            `json:"name"`
+	Port     int               `json:"port,omitempty"`
+	Debug    bool              `json:",omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Parent   *structProtoBase  `json:"parent"`
+	Skipped  string            `json:"-"`
+	Untagged float32
+	secret   string
+}
+
+func TestStructProto(t *testing.T) {
+	c := structProtoConfig{
+		structProtoBase: structProtoBase{ID: "x1"},
+		Name:            "server",
+		Tags:            []string{"a", "b"},
+		Labels:          map[string]string{"env": "prod"},
+		Skipped:         "skipped",
+		Untagged:        1.5,
+		secret:          "hidden",
+	}
+	want := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"id":   StringValueProto("x1"),
+		"name": StringValueProto("server"),
+		"tags": ListValueProto(&structpb.ListValue{Values: []*structpb.Value{
+			StringValueProto("a"), StringValueProto("b"),
+		}}),
+		"labels": StructValueProto(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"env": StringValueProto("prod"),
+		}}),
+		"parent":   NullValueProto(),
+		"Untagged": NumberValueProto(1.5),
+	}}
+	for _, in := range []interface{}{c, &c} {
+		got, err := StructProto(in)
+		if err != nil {
+			t.Fatalf("StructProto(%T): %v", in, err)
+		}
+		if !proto.Equal(got, want) {
+			t.Errorf("StructProto(%T) = %v, want %v", in, got, want)
+		}
+	}
+
+	// Fields with omitempty are kept when they are not empty.
+	c.Port, c.Debug, c.Parent = 8080, true, &structProtoBase{ID: "p"}
+	got, err := StructProto(c)
+	if err != nil {
+		t.Fatalf("StructProto: %v", err)
+	}
+	want.Fields["port"] = NumberValueProto(8080)
+	want.Fields["Debug"] = BoolValueProto(true)
+	want.Fields["parent"] = StructValueProto(&structpb.Struct{Fields: map[string]*structpb.Value{
+		"id": StringValueProto("p"),
+	}})
+	if !proto.Equal(got, want) {
+		t.Errorf("StructProto = %v, want %v", got, want)
+	}
+
+	for _, in := range []interface{}{
+		nil,
+		1,
+		map[string]interface{}{},
+		(*structProtoConfig)(nil),
+		struct{ F float64 }{math.NaN()},
+		struct{ F chan int }{},
+		struct{ F map[int]string }{map[int]string{1: "a"}},
+	} {
+		if got, err := StructProto(in); err == nil {
+			t.Errorf("StructProto(%#v) = %v, want error", in, got)
+		}
+	}
+}
+
+type structProtoInner struct {
+	Name  string
+	Level int
+	Both  string
+	Dup   string
+}
+
+type structProtoTagged struct {
+	Level int `json:"Level"`
+	Dup   string
+}
+
+type structProtoOuter struct {
+	structProtoInner
+	*structProtoTagged
+	Name string
+}
+
+func TestStructProtoFieldConflicts(t *testing.T) {
+	// Name is shadowed by the shallower field, Level by the tagged one at
+	// the same depth, and Dup is ambiguous so it is dropped, as in
+	// encoding/json.
+	in := structProtoOuter{
+		structProtoInner:  structProtoInner{Name: "inner", Level: 1, Both: "b", Dup: "x"},
+		structProtoTagged: &structProtoTagged{Level: 2, Dup: "y"},
+		Name:              "outer",
+	}
+	want := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"Name":  StringValueProto("outer"),
+		"Level": NumberValueProto(2),
+		"Both":  StringValueProto("b"),
+	}}
+	got, err := StructProto(in)
+	if err != nil {
+		t.Fatalf("StructProto: %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("StructProto = %v, want %v", got, want)
+	}
+
+	// Fields promoted through a nil embedded pointer are omitted; the
+	// fields they dominate stay hidden.
+	in.structProtoTagged = nil
+	delete(want.Fields, "Level")
+	got, err = StructProto(in)
+	if err != nil {
+		t.Fatalf("StructProto: %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("StructProto with nil embedded pointer = %v, want %v", got, want)
+	}
+}
+
+type structProtoText int
+
+func (x *structProtoText) MarshalText() ([]byte, error) {
+	if *x < 0 {
+		return nil, errors.New("negative")
+	}
+	return []byte(strings.Repeat("x", int(*x))), nil
+}
+
+type structProtoJSON struct{ A, B int }
+
+func (x structProtoJSON) MarshalJSON() ([]byte, error) {
+	return []byte(`{"sum":` + strings.Repeat("1", x.A+x.B) + `}`), nil
+}
+
+func TestStructProtoMarshalers(t *testing.T) {
+	in := struct {
+		When time.Time
+		Ptr  *time.Time
+		Text structProtoText
+		JSON structProtoJSON
+	}{
+		When: time.Unix(0, 0).UTC(),
+		Text: 3,
+		JSON: structProtoJSON{A: 1, B: 1},
+	}
+	want := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"When": StringValueProto("1970-01-01T00:00:00Z"),
+		"Ptr":  NullValueProto(),
+		"Text": StringValueProto("xxx"),
+		"JSON": StructValueProto(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"sum": NumberValueProto(11),
+		}}),
+	}}
+	got, err := StructProto(&in)
+	if err != nil {
+		t.Fatalf("StructProto: %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("StructProto = %v, want %v", got, want)
+	}
+
+	// A struct that marshals itself is converted from its JSON.
+	got, err = StructProto(structProtoJSON{A: 2})
+	if err != nil {
+		t.Fatalf("StructProto(structProtoJSON): %v", err)
+	}
+	if want := want.Fields["JSON"].GetStructValue(); !proto.Equal(got, want) {
+		t.Errorf("StructProto(structProtoJSON) = %v, want %v", got, want)
+	}
+
+	// Marshaler errors are returned, and a marshaler must produce an object.
+	in.Text = -1
+	if _, err := StructProto(&in); err == nil || !strings.Contains(err.Error(), "negative") {
+		t.Errorf("StructProto with failing MarshalText: error = %v, want negative", err)
+	}
+	if got, err := StructProto(time.Unix(0, 0)); err == nil {
+		t.Errorf("StructProto(time.Time) = %v, want error", got)
+	}
+}
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		in   *structpb.Value
+		want interface{}
+	}{
+		{nil, nil},
+		{&structpb.Value{}, nil},
+		{&structpb.Value{Kind: &structpb.Value_NullValue{}}, nil},
+		{numberVal(1.5), 1.5},
+		{stringVal("s"), "s"},
+		{&structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: true}}, true},
+		{listVal(), []interface{}{}},
+		{&structpb.Value{Kind: &structpb.Value_ListValue{}}, []interface{}{}},
+		{&structpb.Value{Kind: &structpb.Value_StructValue{}}, map[string]interface{}{}},
+		{listVal(nil, numberVal(1)), []interface{}{nil, 1.0}},
+		{structVal(map[string]*structpb.Value{"a": nil, "b": listVal(stringVal("c"))}), map[string]interface{}{"a": nil, "b": []interface{}{"c"}}},
+		{numberVal(math.Inf(-1)), math.Inf(-1)},
+	}
+	for _, tt := range tests {
+		if got := Value(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Value(%v) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+	if got := Value(numberVal(math.NaN())); !math.IsNaN(got.(float64)) {
+		t.Errorf("Value(NaN) = %v, want NaN", got)
+	}
+	if got := StructMap(nil); got == nil || len(got) != 0 {
+		t.Errorf("StructMap(nil) = %#v, want empty map", got)
+	}
+	if got := ListSlice(nil); got == nil || len(got) != 0 {
+		t.Errorf("ListSlice(nil) = %#v, want empty slice", got)
+	}
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	tests := []interface{}{
+		nil,
+		true,
+		-2.5,
+		"hello",
+		[]interface{}{},
+		map[string]interface{}{},
+		[]interface{}{1.0, "two", false, nil, []interface{}{3.0}},
+		map[string]interface{}{
+			"a": map[string]interface{}{"b": []interface{}{map[string]interface{}{"c": nil}}},
+			"d": 1e300,
+		},
+	}
+	for _, in := range tests {
+		v, err := ValueProto(in)
+		if err != nil {
+			t.Errorf("ValueProto(%#v) error: %v", in, err)
+			continue
+		}
+		if got := Value(v); !reflect.DeepEqual(got, in) {
+			t.Errorf("Value(ValueProto(%#v)) = %#v", in, got)
+		}
+		v2, err := ValueProto(Value(v))
+		if err != nil || !proto.Equal(v, v2) {
+			t.Errorf("ValueProto(Value(%v)) = %v, %v", v, v2, err)
+		}
+	}
+}
+
+func TestValueEqual(t *testing.T) {
+	nan := NumberValueProto(math.NaN())
+	tests := []struct {
+		a, b *structpb.Value
+		want bool
+	}{
+		{nil, nil, true},
+		{nil, &structpb.Value{}, true},
+		{nil, NullValueProto(), false},
+		{NullValueProto(), NullValueProto(), true},
+		{NumberValueProto(1), NumberValueProto(1), true},
+		{NumberValueProto(1), NumberValueProto(2), false},
+		{NumberValueProto(1), StringValueProto("1"), false},
+		{StringValueProto("1"), NumberValueProto(1), false},
+		{BoolValueProto(false), NullValueProto(), false},
+		{BoolValueProto(true), BoolValueProto(true), true},
+		{NumberValueProto(0), NumberValueProto(math.Copysign(0, -1)), true},
+		{nan, nan, false},
+		{nan, NumberValueProto(math.NaN()), false},
+		{listVal(nan), listVal(nan), false},
+		{
+			structVal(map[string]*structpb.Value{"a": numberVal(1), "b": listVal(stringVal("x"), NullValueProto())}),
+			structVal(map[string]*structpb.Value{"b": listVal(stringVal("x"), NullValueProto()), "a": numberVal(1)}),
+			true,
+		},
+		{
+			structVal(map[string]*structpb.Value{"a": numberVal(1)}),
+			structVal(map[string]*structpb.Value{"a": numberVal(1), "b": numberVal(1)}),
+			false,
+		},
+		{
+			structVal(map[string]*structpb.Value{"a": numberVal(1)}),
+			structVal(map[string]*structpb.Value{"b": numberVal(1)}),
+			false,
+		},
+		{listVal(numberVal(1), numberVal(2)), listVal(numberVal(2), numberVal(1)), false},
+		{listVal(numberVal(1)), listVal(numberVal(1), numberVal(1)), false},
+		{listVal(), ListValueProto(nil), true},
+		{structVal(nil), StructValueProto(&structpb.Struct{}), true},
+		{listVal(), structVal(nil), false},
+	}
+	for _, tt := range tests {
+		if got := ValueEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("ValueEqual(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := ValueEqual(tt.b, tt.a); got != tt.want {
+			t.Errorf("ValueEqual(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestMergeStruct(t *testing.T) {
+	structVal := func(fields map[string]*structpb.Value) *structpb.Value {
+		return StructValueProto(&structpb.Struct{Fields: fields})
+	}
+	listVal := func(vs ...*structpb.Value) *structpb.Value {
+		return ListValueProto(&structpb.ListValue{Values: vs})
+	}
+	dst := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"name": stringVal("server"),
+		"port": numberVal(80),
+		"tls": structVal(map[string]*structpb.Value{
+			"enabled": BoolValueProto(false),
+			"cert":    stringVal("a.pem"),
+		}),
+		"tags":    listVal(stringVal("a"), stringVal("b")),
+		"limits":  structVal(map[string]*structpb.Value{"cpu": numberVal(1)}),
+		"backend": stringVal("local"),
+	}}
+	src := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"port": numberVal(443),
+		"tls": structVal(map[string]*structpb.Value{
+			"enabled": BoolValueProto(true),
+			"cert":    NullValueProto(),
+		}),
+		"tags":   listVal(stringVal("c")),
+		"limits": stringVal("none"),
+		"backend": structVal(map[string]*structpb.Value{
+			"addr": stringVal("db:5432"),
+			"user": NullValueProto(),
+		}),
+		"name":    NullValueProto(),
+		"missing": NullValueProto(),
+		"new":     listVal(numberVal(1)),
+	}}
+	want := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"port": numberVal(443),
+		"tls": structVal(map[string]*structpb.Value{
+			"enabled": BoolValueProto(true),
+		}),
+		"tags":    listVal(stringVal("c")),
+		"limits":  stringVal("none"),
+		"backend": structVal(map[string]*structpb.Value{"addr": stringVal("db:5432")}),
+		"new":     listVal(numberVal(1)),
+	}}
+	srcCopy := proto.Clone(src)
+	MergeStruct(dst, src)
+	if !proto.Equal(dst, want) {
+		t.Errorf("MergeStruct = %v, want %v", dst, want)
+	}
+	if !proto.Equal(src, srcCopy) {
+		t.Errorf("MergeStruct modified src: %v", src)
+	}
+
+	// dst does not alias src.
+	src.Fields["tags"].GetListValue().Values[0] = stringVal("changed")
+	src.Fields["backend"].GetStructValue().Fields["addr"] = stringVal("changed")
+	if !proto.Equal(dst, want) {
+		t.Errorf("changing src changed dst to %v", dst)
+	}
+
+	// Merging into an empty Struct copies src without its nulls.
+	empty := new(structpb.Struct)
+	MergeStruct(empty, &structpb.Struct{Fields: map[string]*structpb.Value{
+		"a": numberVal(1),
+		"b": NullValueProto(),
+	}})
+	if want := (&structpb.Struct{Fields: map[string]*structpb.Value{"a": numberVal(1)}}); !proto.Equal(empty, want) {
+		t.Errorf("MergeStruct into empty Struct = %v, want %v", empty, want)
+	}
+	MergeStruct(empty, nil)
+	if len(empty.Fields) != 1 {
+		t.Errorf("MergeStruct with nil src = %v, want unchanged", empty)
+	}
+
+	// A nil dst panics even when src is empty, rather than only when
+	// there is something to merge.
+	for _, src := range []*structpb.Struct{nil, {}, {Fields: map[string]*structpb.Value{"a": numberVal(1)}}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("MergeStruct(nil, %v) did not panic", src)
+				}
+			}()
+			MergeStruct(nil, src)
+		}()
+	}
+}
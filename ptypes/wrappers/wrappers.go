@@ -0,0 +1,63 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package wrappers
+
+// This file provides constructors for the wrapper messages, in the style
+// of the proto.Bool and proto.Int32 helpers. The generated GetValue
+// methods return the zero value for a nil wrapper.
+
+// Double returns a DoubleValue holding v.
+func Double(v float64) *DoubleValue { return &DoubleValue{Value: v} }
+
+// Float returns a FloatValue holding v.
+func Float(v float32) *FloatValue { return &FloatValue{Value: v} }
+
+// Int64 returns an Int64Value holding v.
+func Int64(v int64) *Int64Value { return &Int64Value{Value: v} }
+
+// UInt64 returns a UInt64Value holding v.
+func UInt64(v uint64) *UInt64Value { return &UInt64Value{Value: v} }
+
+// Int32 returns an Int32Value holding v.
+func Int32(v int32) *Int32Value { return &Int32Value{Value: v} }
+
+// UInt32 returns a UInt32Value holding v.
+func UInt32(v uint32) *UInt32Value { return &UInt32Value{Value: v} }
+
+// Bool returns a BoolValue holding v.
+func Bool(v bool) *BoolValue { return &BoolValue{Value: v} }
+
+// String returns a StringValue holding v.
+func String(v string) *StringValue { return &StringValue{Value: v} }
+
+// Bytes returns a BytesValue holding v.
+func Bytes(v []byte) *BytesValue { return &BytesValue{Value: v} }
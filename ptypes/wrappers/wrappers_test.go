@@ -0,0 +1,94 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 The Go Authors.  All rights reserved.
+// https://github.com/golang/protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package wrappers_test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+func TestConstructors(t *testing.T) {
+	tests := []struct {
+		got  proto.Message
+		want proto.Message
+		json string
+	}{
+		{wrappers.Double(1.5), &wrappers.DoubleValue{Value: 1.5}, `1.5`},
+		{wrappers.Float(-2), &wrappers.FloatValue{Value: -2}, `-2`},
+		{wrappers.Int64(-3), &wrappers.Int64Value{Value: -3}, `"-3"`},
+		{wrappers.UInt64(4), &wrappers.UInt64Value{Value: 4}, `"4"`},
+		{wrappers.Int32(-5), &wrappers.Int32Value{Value: -5}, `-5`},
+		{wrappers.UInt32(6), &wrappers.UInt32Value{Value: 6}, `6`},
+		{wrappers.Bool(true), &wrappers.BoolValue{Value: true}, `true`},
+		{wrappers.String("s"), &wrappers.StringValue{Value: "s"}, `"s"`},
+		{wrappers.Bytes([]byte("hi")), &wrappers.BytesValue{Value: []byte("hi")}, `"aGk="`},
+		{wrappers.Int32(0), &wrappers.Int32Value{}, `0`},
+	}
+	var m jsonpb.Marshaler
+	for _, tt := range tests {
+		if !proto.Equal(tt.got, tt.want) {
+			t.Errorf("got %v, want %v", tt.got, tt.want)
+		}
+		js, err := m.MarshalToString(tt.got)
+		if err != nil {
+			t.Errorf("MarshalToString(%v): %v", tt.got, err)
+			continue
+		}
+		if js != tt.json {
+			t.Errorf("MarshalToString(%v) = %s, want %s", tt.got, js, tt.json)
+		}
+	}
+}
+
+func TestNilGetValue(t *testing.T) {
+	if v := (*wrappers.DoubleValue)(nil).GetValue(); v != 0 {
+		t.Errorf("nil DoubleValue: GetValue() = %v, want 0", v)
+	}
+	if v := (*wrappers.Int64Value)(nil).GetValue(); v != 0 {
+		t.Errorf("nil Int64Value: GetValue() = %v, want 0", v)
+	}
+	if v := (*wrappers.UInt32Value)(nil).GetValue(); v != 0 {
+		t.Errorf("nil UInt32Value: GetValue() = %v, want 0", v)
+	}
+	if v := (*wrappers.BoolValue)(nil).GetValue(); v {
+		t.Errorf("nil BoolValue: GetValue() = %v, want false", v)
+	}
+	if v := (*wrappers.StringValue)(nil).GetValue(); v != "" {
+		t.Errorf("nil StringValue: GetValue() = %q, want empty", v)
+	}
+	if v := (*wrappers.BytesValue)(nil).GetValue(); v != nil {
+		t.Errorf("nil BytesValue: GetValue() = %q, want nil", v)
+	}
+}